@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BasicAuth holds the HTTP Basic credentials an endpoint requires.
+type BasicAuth struct {
+	Username string
+	Password string
+	// Realm is sent in the WWW-Authenticate challenge, "fakes" by
+	// default.
+	Realm string
+}
+
+// RequireBasicAuth makes the endpoint reject calls that don't carry the
+// given Basic credentials with a 401 and a WWW-Authenticate challenge.
+// Rejected calls are still recorded, and the credentials each call
+// presented can be read with RecordedRequest.BasicAuth.
+func (e *Endpoint) RequireBasicAuth(username, password string) *Endpoint {
+	e.BasicAuth = &BasicAuth{Username: username, Password: password}
+	return e
+}
+
+func (a *BasicAuth) allows(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(a.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(a.Password)) == 1
+}
+
+func (a *BasicAuth) reject(c *gin.Context) {
+	realm := a.Realm
+	if realm == "" {
+		realm = "fakes"
+	}
+	c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+	c.Status(http.StatusUnauthorized)
+}
+
+// APIKey holds the API key an endpoint requires, sent in a header, a
+// query parameter or, if both are set, either.
+type APIKey struct {
+	// Header defaults to "X-Api-Key" unless Query is set.
+	Header string
+	Query  string
+	Value  string
+
+	// StatusCode and Response make up the rejection, a 401 without a
+	// body by default.
+	StatusCode int
+	Response   string
+}
+
+// RequireAPIKey makes the endpoint reject calls that don't carry key.
+// Rejected calls are still recorded.
+func (e *Endpoint) RequireAPIKey(key APIKey) *Endpoint {
+	e.APIKey = &key
+	return e
+}
+
+func (k *APIKey) allows(r *http.Request) bool {
+	header := k.Header
+	if header == "" && k.Query == "" {
+		header = "X-Api-Key"
+	}
+	if header != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(k.Value)) == 1 {
+		return true
+	}
+	return k.Query != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get(k.Query)), []byte(k.Value)) == 1
+}
+
+func (k *APIKey) status() int {
+	if k.StatusCode == 0 {
+		return http.StatusUnauthorized
+	}
+	return k.StatusCode
+}
+
+func (k *APIKey) reject(c *gin.Context) {
+	if k.Response == "" {
+		c.Status(k.status())
+		return
+	}
+	c.String(k.status(), k.Response)
+}
+
+// BasicAuth returns the Basic credentials the request presented, if
+// any.
+func (r RecordedRequest) BasicAuth() (username, password string, ok bool) {
+	req := http.Request{Header: r.Header}
+	return req.BasicAuth()
+}
@@ -0,0 +1,91 @@
+package fake
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRejectedCallsLeaveScenarioAlone(t *testing.T) {
+	f := New()
+	f.AddEndpoint((&Endpoint{
+		Path:          "/order",
+		Method:        http.MethodGet,
+		Response:      "started",
+		Scenario:      "order",
+		RequiredState: ScenarioStarted,
+		NewState:      "done",
+	}).RequireBasicAuth("user", "pass"))
+	f.AddEndpoint(&Endpoint{
+		Path:          "/order",
+		Method:        http.MethodGet,
+		Response:      "done",
+		Scenario:      "order",
+		RequiredState: "done",
+		Optional:      true,
+	})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	get := func(auth bool) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, f.BaseURL()+"/order", nil)
+		if auth {
+			req.SetBasicAuth("user", "pass")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, _ := get(false); status != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated call got %d, want 401", status)
+	}
+	if state := f.ScenarioState("order"); state != ScenarioStarted {
+		t.Fatalf("rejected call moved the scenario to %q", state)
+	}
+	if status, body := get(true); status != http.StatusOK || body != "started" {
+		t.Fatalf("authenticated call got %d %q, want 200 \"started\"", status, body)
+	}
+	if state := f.ScenarioState("order"); state != "done" {
+		t.Fatalf("scenario is %q after an accepted call, want done", state)
+	}
+	if calls := f.CallCount("/order", http.MethodGet); calls != 2 {
+		t.Fatalf("CallCount = %d, want the rejected call recorded too", calls)
+	}
+}
+
+func TestRejectedCallsDontUseUpFailFirst(t *testing.T) {
+	f := New()
+	f.AddEndpoint((&Endpoint{
+		Path:      "/flaky",
+		Method:    http.MethodGet,
+		Response:  "ok",
+		FailFirst: 1,
+	}).RequireAPIKey(APIKey{Value: "secret"}))
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	statuses := []int{}
+	for _, key := range []string{"", "secret", "secret"} {
+		req, _ := http.NewRequest(http.MethodGet, f.BaseURL()+"/flaky", nil)
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	want := []int{http.StatusUnauthorized, http.StatusInternalServerError, http.StatusOK}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, want)
+		}
+	}
+}
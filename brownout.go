@@ -0,0 +1,69 @@
+package fake
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BrownOut models an upstream brown-out: from the first call it sees,
+// every call is answered with a 503 and a Retry-After header until
+// Duration has passed, after which the upstream recovers fully. Used in
+// a ChaosPolicy, the whole service browns out and recovers together.
+type BrownOut struct {
+	Duration time.Duration
+	// RetryAfter is the delay advertised in the Retry-After header,
+	// which defaults to the time left until the upstream recovers.
+	RetryAfter time.Duration
+
+	mutex sync.Mutex
+	start time.Time
+}
+
+// WithBrownOut browns the whole service out for d from the first call
+// it receives, see BrownOut.
+func WithBrownOut(d time.Duration) Option {
+	return func(f *FakeService) {
+		if f.chaos == nil {
+			f.chaos = &ChaosPolicy{}
+		}
+		f.chaos.BrownOut = &BrownOut{Duration: d}
+	}
+}
+
+// remaining starts the brown-out if need be and returns how much of it
+// is left at now, which is zero or less once it is over.
+func (b *BrownOut) remaining(now time.Time) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.start.IsZero() {
+		b.start = now
+	}
+	return b.start.Add(b.Duration).Sub(now)
+}
+
+func (b *BrownOut) reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.start = time.Time{}
+}
+
+// rejectBrownOut responds with a 503 telling the client when to retry.
+func rejectBrownOut(c *gin.Context, b *BrownOut, remaining time.Duration) {
+	retryAfter := b.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = remaining
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", fmt.Sprint(seconds))
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service unavailable"})
+}
@@ -0,0 +1,158 @@
+package fake
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Callback makes an endpoint call back to the client after it
+// responds, as async APIs that acknowledge with a 202 and report the
+// result later do. URL and Body are templates rendered with the
+// TemplateData of the call that triggered the callback, so both can be
+// taken from the request, for instance {{.JSON.callback_url}}.
+type Callback struct {
+	URL  string
+	Body string
+	// Method defaults to POST.
+	Method string
+	Header http.Header
+	// Delay is how long after responding to call back. The response is
+	// sent before the callback even without one, but a client may not
+	// have read it by the time the callback arrives, so give clients
+	// that must see their response first a Delay.
+	Delay time.Duration
+	// Retries is how many more times a callback that fails, with an
+	// error or a status other than 2xx, is attempted, RetryDelay apart.
+	Retries    int
+	RetryDelay time.Duration
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+
+	mutex    sync.Mutex
+	attempts []CallbackAttempt
+	pending  sync.WaitGroup
+}
+
+// CallbackAttempt is an attempt to deliver a callback. Err is set if
+// the callback couldn't be made or no response was received.
+type CallbackAttempt struct {
+	// Call is the call to the endpoint that triggered the callback, and
+	// Attempt counts from 1 for each.
+	Call         int
+	Attempt      int
+	URL          string
+	Body         []byte
+	StatusCode   int
+	ResponseBody []byte
+	Err          error
+	Time         time.Time
+}
+
+// Succeeded reports whether the attempt was answered with a 2xx.
+func (a CallbackAttempt) Succeeded() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Attempts returns every attempt made to deliver a callback, in the
+// order they were made.
+func (cb *Callback) Attempts() []CallbackAttempt {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return append([]CallbackAttempt(nil), cb.attempts...)
+}
+
+// Wait blocks until every callback triggered so far has been delivered
+// or has run out of retries.
+func (cb *Callback) Wait() {
+	cb.pending.Wait()
+}
+
+func (cb *Callback) reset() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.attempts = nil
+}
+
+// triggerCallback renders the callback for the call being answered by c and
+// delivers it in the background, giving up once the service closes.
+func (f *FakeService) triggerCallback(cb *Callback, c *gin.Context, call int, body []byte) {
+	data := newTemplateData(call, f.State, c.Request, body)
+	url, err := renderTemplate(cb.URL, data)
+	if err == nil {
+		var payload string
+		if payload, err = renderTemplate(cb.Body, data); err == nil {
+			body = []byte(payload)
+		}
+	}
+	if err != nil {
+		cb.record(CallbackAttempt{Call: call, Attempt: 1, Err: fmt.Errorf("failed to render callback: %w", err), Time: time.Now()})
+		return
+	}
+
+	cb.pending.Add(1)
+	go func() {
+		defer cb.pending.Done()
+		delay := cb.Delay
+		for attempt := 1; attempt <= cb.Retries+1; attempt++ {
+			select {
+			case <-time.After(delay):
+			case <-f.closing:
+				return
+			}
+			result := cb.deliver(url, body)
+			result.Call = call
+			result.Attempt = attempt
+			cb.record(result)
+			if result.Succeeded() {
+				return
+			}
+			delay = cb.RetryDelay
+		}
+	}()
+}
+
+func (cb *Callback) deliver(url string, body []byte) CallbackAttempt {
+	attempt := CallbackAttempt{URL: url, Body: body, Time: time.Now()}
+	method := cb.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, url, strings.NewReader(string(body)))
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	for name, values := range cb.Header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" && len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := cb.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	defer resp.Body.Close()
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseBody, attempt.Err = io.ReadAll(resp.Body)
+	return attempt
+}
+
+func (cb *Callback) record(attempt CallbackAttempt) {
+	fmt.Printf("callback %s attempt %d - HTTP %d\n", attempt.URL, attempt.Attempt, attempt.StatusCode)
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.attempts = append(cb.attempts, attempt)
+}
@@ -0,0 +1,59 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const callIndexKey = "fakes.callIndex"
+
+// CallIndex returns which call (1-indexed) to its endpoint the request
+// being handled is, for use within custom Handlers.
+func CallIndex(c *gin.Context) int {
+	return c.GetInt(callIndexKey)
+}
+
+// CallHandlers dispatches to a different handler depending on which
+// call to the endpoint is being handled, e.g.
+//
+//	Handler: fake.OnCall(1, first).OnCall(2, retry).Otherwise(rest)
+type CallHandlers struct {
+	handlers  map[int]gin.HandlerFunc
+	otherwise gin.HandlerFunc
+}
+
+// OnCall starts a set of CallHandlers that serves the nth call with h.
+func OnCall(n int, h gin.HandlerFunc) *CallHandlers {
+	return (&CallHandlers{handlers: map[int]gin.HandlerFunc{}}).OnCall(n, h)
+}
+
+// OnCall serves the nth call with h.
+func (h *CallHandlers) OnCall(n int, handler gin.HandlerFunc) *CallHandlers {
+	h.handlers[n] = handler
+	return h
+}
+
+// Otherwise serves any call without a specific handler with handler
+// and returns the resulting handler, ready to be used as an Endpoint's
+// Handler.
+func (h *CallHandlers) Otherwise(handler gin.HandlerFunc) gin.HandlerFunc {
+	h.otherwise = handler
+	return h.Handle
+}
+
+// Handle serves the request with the handler registered for its call.
+// Calls without a handler receive a 500.
+func (h *CallHandlers) Handle(c *gin.Context) {
+	call := CallIndex(c)
+	if handler, ok := h.handlers[call]; ok {
+		handler(c)
+		return
+	}
+	if h.otherwise != nil {
+		h.otherwise(c)
+		return
+	}
+	c.String(http.StatusInternalServerError, fmt.Sprintf("no handler registered for call %d", call))
+}
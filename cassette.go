@@ -0,0 +1,426 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cassette records the exchanges with a real upstream so they can be
+// replayed without it, see WithCassette.
+type cassette struct {
+	path     string
+	upstream string
+	client   *http.Client
+	match    CassetteMatch
+
+	// scrubbedHeaders, scrubbedFields and bodyScrubbers remove secrets
+	// from exchanges before they are written, see WithScrubbedHeaders,
+	// WithScrubbedJSONFields and WithScrubbedBody.
+	scrubbedHeaders []string
+	scrubbedFields  map[string]bool
+	bodyScrubbers   []bodyScrubber
+
+	// recording is set when the cassette is being recorded rather than
+	// replayed, and interactions holds what has been recorded.
+	recording    bool
+	interactions []cassetteInteraction
+	mutex        sync.Mutex
+}
+
+type cassetteFile struct {
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+type cassetteResponse struct {
+	StatusCode   int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// cassetteHopHeaders only apply to a single connection and so aren't
+// forwarded to the upstream. Accept-Encoding is left to the client so
+// that recorded bodies are decoded.
+var cassetteHopHeaders = []string{
+	"Accept-Encoding",
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// WithCassette records and replays exchanges with a real upstream, such
+// as https://api.example.com, in a VCR style cassette file. If the
+// cassette doesn't exist, or the tests are run with FAKES_UPDATE=1,
+// requests no endpoint matches are proxied to upstream and the exchanges
+// written to the cassette once the test passes. Otherwise the cassette is
+// replayed, without any network access, by an Optional endpoint for
+// each recorded exchange, matched by method, path and query unless
+// WithCassetteMatching says otherwise. Authorization, Proxy-Authorization
+// and Cookie request headers are always scrubbed from the cassette.
+func WithCassette(path, upstream string, opts ...CassetteOption) Option {
+	return func(f *FakeService) {
+		c := &cassette{
+			path:     path,
+			upstream: strings.TrimSuffix(upstream, "/"),
+			client: &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			},
+			scrubbedFields: map[string]bool{},
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+		f.cassette = c
+	}
+}
+
+// CassetteOption configures how a cassette is matched and scrubbed.
+type CassetteOption func(*cassette)
+
+// CassetteMatch decides which recorded exchange replays a request.
+type CassetteMatch int
+
+const (
+	// CassetteMatchQuery matches the method, path and query, the
+	// default.
+	CassetteMatchQuery CassetteMatch = iota
+	// CassetteMatchStrict also matches the body, compared as JSON if
+	// both are JSON.
+	CassetteMatchStrict
+	// CassetteMatchLoose matches only the method and path, for APIs
+	// whose queries hold timestamps, nonces or pagination cursors.
+	CassetteMatchLoose
+)
+
+// WithCassetteMatching sets how requests are matched to recorded
+// exchanges when the cassette is replayed.
+func WithCassetteMatching(match CassetteMatch) CassetteOption {
+	return func(c *cassette) {
+		c.match = match
+	}
+}
+
+// WithScrubbedHeaders replaces the values of the named request and
+// response headers with "[SCRUBBED]" before the cassette is written.
+func WithScrubbedHeaders(names ...string) CassetteOption {
+	return func(c *cassette) {
+		c.scrubbedHeaders = append(c.scrubbedHeaders, names...)
+	}
+}
+
+// WithScrubbedJSONFields replaces the values of the named fields,
+// wherever they appear in JSON request and response bodies, with
+// "[SCRUBBED]" before the cassette is written.
+func WithScrubbedJSONFields(names ...string) CassetteOption {
+	return func(c *cassette) {
+		for _, name := range names {
+			c.scrubbedFields[name] = true
+		}
+	}
+}
+
+// WithScrubbedBody replaces every match of the regular expression
+// pattern in request and response bodies with replacement, which may
+// refer to submatches as regexp.ReplaceAllString does, before the
+// cassette is written. It panics if pattern doesn't compile.
+func WithScrubbedBody(pattern, replacement string) CassetteOption {
+	re := regexp.MustCompile(pattern)
+	return func(c *cassette) {
+		c.bodyScrubbers = append(c.bodyScrubbers, bodyScrubber{re: re, replacement: replacement})
+	}
+}
+
+type bodyScrubber struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// scrubbed is what secrets are replaced with.
+const scrubbed = "[SCRUBBED]"
+
+// cassetteScrubbedHeaders are request headers that are never written
+// to a cassette.
+var cassetteScrubbedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// load registers the cassette's endpoints, or starts recording it if it
+// has yet to be recorded.
+func (c *cassette) load(f *FakeService) error {
+	data, err := os.ReadFile(c.path)
+	if updateSnapshots() || errors.Is(err, fs.ErrNotExist) {
+		c.recording = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse cassette %s: %w", c.path, err)
+	}
+
+	var endpoints []*Endpoint
+	repeats := map[string][]*Endpoint{}
+	for i, interaction := range file.Interactions {
+		e, key, err := c.endpoint(interaction)
+		if err != nil {
+			return fmt.Errorf("cassette %s: interaction %d: %w", c.path, i+1, err)
+		}
+		repeats[key] = append(repeats[key], e)
+		endpoints = append(endpoints, e)
+	}
+	sequenceReplays("Cassette", repeats)
+	for _, e := range endpoints {
+		f.AddEndpoint(e)
+	}
+	return nil
+}
+
+// endpoint returns the endpoint replaying an interaction, along with a
+// key shared by the interactions it can't be told apart from.
+func (c *cassette) endpoint(i cassetteInteraction) (*Endpoint, string, error) {
+	u, err := url.Parse(i.Request.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid URL: %w", err)
+	}
+	e := newReplayEndpoint(i.Request.Method, u, i.Response.StatusCode)
+	body, err := decodeCassetteBody(i.Response.Body, i.Response.BodyEncoding)
+	if err != nil {
+		return nil, "", err
+	}
+	e.Response = string(body)
+	e.ResponseHeaders = i.Response.Header
+
+	key := e.Method + " " + u.Path
+	switch c.match {
+	case CassetteMatchLoose:
+		e.Matcher = nil
+	case CassetteMatchStrict:
+		want, err := decodeCassetteBody(i.Request.Body, i.Request.BodyEncoding)
+		if err != nil {
+			return nil, "", err
+		}
+		matchesQuery := e.Matcher
+		e.Matcher = func(r *http.Request) bool {
+			got, _ := io.ReadAll(r.Body)
+			return matchesQuery(r) && cassetteBodiesMatch(want, c.scrubBody(got))
+		}
+		key += "?" + u.Query().Encode() + " " + string(want)
+	default:
+		key += "?" + u.Query().Encode()
+	}
+	return e, key, nil
+}
+
+// cassetteBodiesMatch compares request bodies, as JSON if both are.
+func cassetteBodiesMatch(want, got []byte) bool {
+	var wantJSON, gotJSON any
+	if json.Unmarshal(want, &wantJSON) == nil && json.Unmarshal(got, &gotJSON) == nil {
+		return reflect.DeepEqual(wantJSON, gotJSON)
+	}
+	return bytes.Equal(want, got)
+}
+
+// scrubHeader returns a copy of header with secrets scrubbed.
+func (c *cassette) scrubHeader(header http.Header, always []string) http.Header {
+	header = header.Clone()
+	for _, names := range [][]string{always, c.scrubbedHeaders} {
+		for _, name := range names {
+			if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+				header.Set(name, scrubbed)
+			}
+		}
+	}
+	return header
+}
+
+// scrubBody returns body with secrets scrubbed.
+func (c *cassette) scrubBody(body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	if len(c.scrubbedFields) > 0 {
+		var decoded any
+		if json.Unmarshal(body, &decoded) == nil {
+			if scrubbedBody, err := json.Marshal(c.scrubJSON(decoded)); err == nil {
+				body = scrubbedBody
+			}
+		}
+	}
+	for _, s := range c.bodyScrubbers {
+		body = s.re.ReplaceAll(body, []byte(s.replacement))
+	}
+	return body
+}
+
+func (c *cassette) scrubJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if c.scrubbedFields[key] {
+				v[key] = scrubbed
+			} else {
+				v[key] = c.scrubJSON(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = c.scrubJSON(item)
+		}
+	}
+	return value
+}
+
+// record proxies a request to the upstream, recording the exchange.
+func (c *cassette) record(ctx *gin.Context) {
+	r := ctx.Request
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, c.upstream+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		ctx.String(http.StatusBadGateway, "failed to build upstream request: %s", err)
+		return
+	}
+	req.Header = r.Header.Clone()
+	for _, name := range cassetteHopHeaders {
+		req.Header.Del(name)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		fmt.Printf("%s: %s - failed to reach %s: %s\n", r.Method, r.URL, c.upstream, err)
+		ctx.String(http.StatusBadGateway, "failed to reach upstream: %s", err)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ctx.String(http.StatusBadGateway, "failed to read upstream response: %s", err)
+		return
+	}
+	fmt.Printf("%s: %s - HTTP %d, recorded from %s\n", r.Method, r.URL, resp.StatusCode, c.upstream)
+
+	header := http.Header{}
+	for name, values := range resp.Header {
+		if !replaySkippedHeaders[name] {
+			header[name] = values
+		}
+	}
+	for name, values := range header {
+		ctx.Writer.Header()[name] = values
+	}
+	ctx.Status(resp.StatusCode)
+	ctx.Writer.Write(respBody)
+
+	interaction := cassetteInteraction{
+		Request: cassetteRequest{
+			Method: r.Method,
+			URL:    r.URL.RequestURI(),
+			Header: c.scrubHeader(req.Header, cassetteScrubbedHeaders),
+		},
+		Response: cassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     c.scrubHeader(header, nil),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeCassetteBody(c.scrubBody(body))
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeCassetteBody(c.scrubBody(respBody))
+
+	c.mutex.Lock()
+	c.interactions = append(c.interactions, interaction)
+	c.mutex.Unlock()
+}
+
+// write saves the recorded exchanges to the cassette.
+func (c *cassette) write() error {
+	if !c.recording {
+		return nil
+	}
+	c.mutex.Lock()
+	file := cassetteFile{Interactions: append([]cassetteInteraction{}, c.interactions...)}
+	c.mutex.Unlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// writeCassetteT writes the cassette if one is being recorded and the
+// test passed.
+func (f *FakeService) writeCassetteT(t testing.TB) {
+	t.Helper()
+	if f.cassette == nil || t.Failed() {
+		return
+	}
+	if err := f.cassette.write(); err != nil {
+		t.Errorf("failed to write cassette: %s", err.Error())
+	}
+}
+
+// encodeCassetteBody stores a body as text, or as base64 if it isn't.
+func encodeCassetteBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %w", err)
+		}
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("unknown body encoding %q", encoding)
+}
@@ -0,0 +1,157 @@
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCassetteRecordThenReplay(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name":%q}`, r.URL.Query().Get("name"))
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassettes", "pets.json")
+
+	get := func(f *FakeService) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, f.BaseURL()+"/pets?name=rex", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Cookie", "session=secret-cookie")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body %s", resp.StatusCode, body)
+		}
+		return string(body)
+	}
+
+	recording := New(WithCassette(path, upstream.URL))
+	recording.Run(t)
+	recorded := get(recording)
+	recording.TidyUp(t)
+	if recorded != `{"name":"rex"}` {
+		t.Fatalf("recorded body = %s", recorded)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "secret-cookie"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("cassette holds %q:\n%s", secret, data)
+		}
+	}
+
+	upstream.Close()
+	replaying := New(WithCassette(path, upstream.URL))
+	replaying.Run(t)
+	defer replaying.TidyUp(t)
+	if replayed := get(replaying); replayed != recorded {
+		t.Errorf("replayed body = %s, want %s", replayed, recorded)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times, want 1", n)
+	}
+}
+
+func TestCassetteMatching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pets.json")
+	cassette := `{"interactions": [
+		{"request": {"method": "GET", "url": "/pets?page=1"}, "response": {"status": 200, "body": "page one"}},
+		{"request": {"method": "POST", "url": "/pets", "body": "{\"name\":\"rex\",\"age\":3}"}, "response": {"status": 201, "body": "created"}}
+	]}`
+	if err := os.WriteFile(path, []byte(cassette), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		match  CassetteMatch
+		method string
+		url    string
+		body   string
+		want   int
+	}{
+		{"query matches the query", CassetteMatchQuery, http.MethodGet, "/pets?page=1", "", http.StatusOK},
+		{"query rejects another query", CassetteMatchQuery, http.MethodGet, "/pets?page=2", "", http.StatusNotFound},
+		{"query ignores the body", CassetteMatchQuery, http.MethodPost, "/pets", `{"name":"fido"}`, http.StatusCreated},
+		{"strict matches equal JSON", CassetteMatchStrict, http.MethodPost, "/pets", `{"age":3, "name":"rex"}`, http.StatusCreated},
+		{"strict rejects another body", CassetteMatchStrict, http.MethodPost, "/pets", `{"name":"fido"}`, http.StatusNotFound},
+		{"strict rejects another query", CassetteMatchStrict, http.MethodGet, "/pets?page=2", "", http.StatusNotFound},
+		{"loose ignores the query", CassetteMatchLoose, http.MethodGet, "/pets?page=2", "", http.StatusOK},
+		{"loose ignores the body", CassetteMatchLoose, http.MethodPost, "/pets", `{"name":"fido"}`, http.StatusCreated},
+		{"loose still matches the path", CassetteMatchLoose, http.MethodGet, "/owners", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(WithCassette(path, "http://upstream.invalid", WithCassetteMatching(tt.match)))
+			f.Run(t)
+			defer f.TidyUp(t)
+			req, _ := http.NewRequest(tt.method, f.BaseURL()+tt.url, bytes.NewBufferString(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCassetteScrubbing(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Session-Token", "secret-session")
+		io.WriteString(w, `{"token":"secret-response-token","account":"acct-1234"}`)
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "login.json")
+
+	f := New(WithCassette(path, upstream.URL,
+		WithScrubbedHeaders("X-Api-Key", "X-Session-Token"),
+		WithScrubbedJSONFields("password", "token"),
+		WithScrubbedBody(`acct-[0-9]+`, "acct-0000"),
+	))
+	f.Run(t)
+	req, _ := http.NewRequest(http.MethodPost, f.BaseURL()+"/login", bytes.NewBufferString(`{"user":"rex","password":"secret-password"}`))
+	req.Header.Set("X-Api-Key", "secret-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	f.TidyUp(t)
+
+	if !bytes.Contains(body, []byte("secret-response-token")) {
+		t.Errorf("the client's response was scrubbed too: %s", body)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-key", "secret-session", "secret-password", "secret-response-token", "acct-1234"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("cassette holds %q:\n%s", secret, data)
+		}
+	}
+	if !bytes.Contains(data, []byte(`"user\":\"rex\"`)) {
+		t.Errorf("cassette lost the unscrubbed fields:\n%s", data)
+	}
+}
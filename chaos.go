@@ -0,0 +1,378 @@
+package fake
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lockedSource makes a rand.Source safe for use by concurrent handlers.
+type lockedSource struct {
+	mutex sync.Mutex
+	src   rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.src.Seed(seed)
+}
+
+// WithChaosSeed seeds the random numbers behind every chaos decision,
+// so a chaotic test run can be reproduced from the seed it reports on
+// failure. Runs only repeat exactly if requests arrive in the same order.
+func WithChaosSeed(seed int64) Option {
+	return func(f *FakeService) {
+		f.chaosSeed = seed
+		f.rng = rand.New(&lockedSource{src: rand.NewSource(seed)})
+	}
+}
+
+// WithChaosSource makes chaos decisions with numbers from src. It takes
+// care of locking, so src need not be safe for concurrent use.
+func WithChaosSource(src rand.Source) Option {
+	return func(f *FakeService) {
+		f.rng = rand.New(&lockedSource{src: src})
+	}
+}
+
+// ChaosSeed returns the seed used for chaos decisions, which is random
+// unless set with WithChaosSeed. It is meaningless if WithChaosSource
+// was used.
+func (f *FakeService) ChaosSeed() int64 {
+	return f.chaosSeed
+}
+
+// ChaosLatency randomly slows responses down, to exercise client
+// timeouts and hedging alongside failure injection.
+type ChaosLatency struct {
+	// Percent is the chance, from 0 to 100, that a call is delayed.
+	Percent int
+	// Min and Max bound the delay, which is picked uniformly between
+	// them unless a Distribution is set.
+	Min time.Duration
+	Max time.Duration
+	// Distribution, if set, picks the delay instead of Min and Max.
+	// A non-zero Max still caps the delay, which keeps long-tailed
+	// distributions from stalling a test indefinitely.
+	Distribution LatencyDistribution
+}
+
+// delay returns how long to delay the current call, if at all.
+func (l *ChaosLatency) delay(rng *rand.Rand) time.Duration {
+	if l.Percent <= 0 || rng.Intn(100) >= l.Percent {
+		return 0
+	}
+	if l.Distribution == nil {
+		return Uniform(l.Min, l.Max).Sample(rng)
+	}
+	d := l.Distribution.Sample(rng)
+	if l.Max > 0 && d > l.Max {
+		d = l.Max
+	}
+	return d
+}
+
+// LatencyDistribution samples delays for ChaosLatency.
+type LatencyDistribution interface {
+	Sample(rng *rand.Rand) time.Duration
+}
+
+type uniformLatency struct {
+	min, max time.Duration
+}
+
+// Uniform picks delays uniformly between min and max.
+func Uniform(min, max time.Duration) LatencyDistribution {
+	return uniformLatency{min: min, max: max}
+}
+
+func (u uniformLatency) Sample(rng *rand.Rand) time.Duration {
+	if u.max <= u.min {
+		return u.min
+	}
+	return u.min + time.Duration(rng.Int63n(int64(u.max-u.min)))
+}
+
+type normalLatency struct {
+	mean, stddev time.Duration
+}
+
+// Normal picks delays from a normal distribution, never below zero.
+func Normal(mean, stddev time.Duration) LatencyDistribution {
+	return normalLatency{mean: mean, stddev: stddev}
+}
+
+func (n normalLatency) Sample(rng *rand.Rand) time.Duration {
+	d := time.Duration(rng.NormFloat64()*float64(n.stddev)) + n.mean
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+type paretoLatency struct {
+	scale time.Duration
+	shape float64
+}
+
+// Pareto picks delays from a Pareto distribution with the given scale
+// (the minimum delay) and shape, for reproducing long-tail latency.
+// Smaller shapes give heavier tails.
+func Pareto(scale time.Duration, shape float64) LatencyDistribution {
+	return paretoLatency{scale: scale, shape: shape}
+}
+
+func (p paretoLatency) Sample(rng *rand.Rand) time.Duration {
+	if p.shape <= 0 {
+		return p.scale
+	}
+	// Inverse transform sampling, using 1-U so we never divide by zero.
+	u := 1 - rng.Float64()
+	d := float64(p.scale) / math.Pow(u, 1/p.shape)
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// chance reports whether an event with the given percentage chance,
+// from 0 to 100, should happen.
+func chance(rng *rand.Rand, percent int) bool {
+	return percent > 0 && rng.Intn(100) < percent
+}
+
+// resetConnection abruptly closes the client's connection. Over
+// HTTP/1.x the connection is hijacked and closed with SO_LINGER set to
+// zero so the client sees a TCP reset rather than a clean close.
+func resetConnection(c *gin.Context) {
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		// Connections that can't be hijacked, such as HTTP/2 streams,
+		// are aborted by net/http instead.
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// hang blocks until the client gives up on the request, max elapses
+// (if non-zero) or the service shuts down. Nothing is written, so the
+// client never receives a response.
+func (f *FakeService) hang(c *gin.Context, max time.Duration) {
+	var timeout <-chan time.Time
+	if max > 0 {
+		timer := time.NewTimer(max)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-c.Request.Context().Done():
+	case <-timeout:
+	case <-f.closing:
+	}
+	// Abort rather than let gin write a response once we return.
+	panic(http.ErrAbortHandler)
+}
+
+// ChaosPolicy applies failure and latency injection to every endpoint
+// of a FakeService, see WithChaos. Any chaos setting made on an
+// endpoint itself takes precedence over the policy, and endpoints with
+// NoChaos set are left alone.
+type ChaosPolicy struct {
+	FailureRatePercent     int
+	FailureHandler         gin.HandlerFunc
+	Latency                *ChaosLatency
+	ConnectionResetPercent int
+	HangPercent            int
+	HangMax                time.Duration
+
+	// MaxFailureCount caps the faults injected into each endpoint, as
+	// Endpoint.MaxFailureCount does. 0 means there is no limit.
+	MaxFailureCount int
+
+	// BrownOut, if set, browns out every endpoint together.
+	BrownOut *BrownOut
+
+	// Methods overrides the policy for requests with a given method,
+	// keyed by upper case method names such as http.MethodPost, as
+	// real upstreams tend to degrade on writes first.
+	Methods map[string]ChaosPolicy
+}
+
+// WithChaos applies policy across all of the service's endpoints.
+func WithChaos(policy ChaosPolicy) Option {
+	return func(f *FakeService) {
+		f.chaos = &policy
+	}
+}
+
+// WithFailureHandler sets how chaos failures are answered for
+// endpoints without a FailureHandler of their own, in place of the
+// default 500 with a JSON error body.
+func WithFailureHandler(h gin.HandlerFunc) Option {
+	return func(f *FakeService) {
+		f.failureHandler = h
+	}
+}
+
+// defaultFailureHandler answers a chaos failure with a 500 and a JSON
+// error body, which most clients can at least parse.
+func defaultFailureHandler(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "injected failure"})
+}
+
+// chaosFor merges the service's chaos policy with the endpoint's own
+// settings, which win wherever they are set. Settings for the
+// request's method win over those for the endpoint as a whole.
+func (f *FakeService) chaosFor(e *Endpoint, method string) ChaosPolicy {
+	var policy ChaosPolicy
+	if f.chaos != nil && !e.NoChaos {
+		policy = f.chaos.overlay(f.chaos.Methods[method])
+	}
+	policy = policy.overlay(ChaosPolicy{
+		FailureRatePercent:     e.FailureRatePercent,
+		FailureHandler:         e.FailureHandler,
+		Latency:                e.ChaosLatency,
+		ConnectionResetPercent: e.ConnectionResetPercent,
+		HangPercent:            e.HangPercent,
+		HangMax:                e.HangMax,
+		MaxFailureCount:        e.MaxFailureCount,
+		BrownOut:               e.BrownOut,
+	})
+	policy = policy.overlay(e.MethodChaos[method])
+	if percent, ok := f.chaosControl.rate(e.route()); ok {
+		policy.FailureRatePercent = percent
+	}
+	return policy
+}
+
+// overlay returns p with every setting made in o taking its place.
+func (p ChaosPolicy) overlay(o ChaosPolicy) ChaosPolicy {
+	if o.FailureRatePercent != 0 {
+		p.FailureRatePercent = o.FailureRatePercent
+	}
+	if o.FailureHandler != nil {
+		p.FailureHandler = o.FailureHandler
+	}
+	if o.Latency != nil {
+		p.Latency = o.Latency
+	}
+	if o.ConnectionResetPercent != 0 {
+		p.ConnectionResetPercent = o.ConnectionResetPercent
+	}
+	if o.HangPercent != 0 {
+		p.HangPercent = o.HangPercent
+		p.HangMax = o.HangMax
+	}
+	if o.MaxFailureCount != 0 {
+		p.MaxFailureCount = o.MaxFailureCount
+	}
+	if o.BrownOut != nil {
+		p.BrownOut = o.BrownOut
+	}
+	return p
+}
+
+// applyChaos injects any latency and faults due for this call, and
+// reports whether a fault has already dealt with the request.
+func (f *FakeService) applyChaos(e *Endpoint, c *gin.Context) bool {
+	if !f.chaosControl.enabled() {
+		return false
+	}
+	policy := f.chaosFor(e, c.Request.Method)
+
+	if policy.BrownOut != nil {
+		if remaining := policy.BrownOut.remaining(time.Now()); remaining > 0 {
+			fmt.Printf("%s: %s - chaos brown-out\n", c.Request.Method, c.Request.URL)
+			f.recordChaos(e, c, FaultBrownOut, 0)
+			rejectBrownOut(c, policy.BrownOut, remaining)
+			return true
+		}
+	}
+
+	if policy.Latency != nil {
+		if d := policy.Latency.delay(f.rng); d > 0 {
+			fmt.Printf("%s: %s - chaos latency of %s\n", c.Request.Method, c.Request.URL, d)
+			f.recordChaos(e, c, FaultLatency, d)
+			sleep(c.Request.Context(), d)
+		}
+	}
+	if chance(f.rng, policy.ConnectionResetPercent) && f.takeFailure(e, policy.MaxFailureCount) {
+		fmt.Printf("%s: %s - chaos connection reset\n", c.Request.Method, c.Request.URL)
+		f.recordChaos(e, c, FaultConnectionReset, 0)
+		resetConnection(c)
+		return true
+	}
+	if chance(f.rng, policy.HangPercent) && f.takeFailure(e, policy.MaxFailureCount) {
+		fmt.Printf("%s: %s - chaos hang\n", c.Request.Method, c.Request.URL)
+		f.recordChaos(e, c, FaultHang, 0)
+		f.hang(c, policy.HangMax)
+		return true
+	}
+	if e.scheduledFailure(CallIndex(c)) ||
+		chance(f.rng, policy.FailureRatePercent) && f.takeFailure(e, policy.MaxFailureCount) {
+		fmt.Printf("%s: %s - chaos failure\n", c.Request.Method, c.Request.URL)
+		f.recordChaos(e, c, FaultFailure, 0)
+		if policy.FailureHandler != nil {
+			policy.FailureHandler(c)
+		} else {
+			f.failureHandler(c)
+		}
+		return true
+	}
+	return false
+}
+
+// WithFailureBudget caps the faults chaos injects across all of the
+// service's endpoints at n, so a test with chaos on several endpoints
+// can still count on its final attempts succeeding. Like
+// MaxFailureCount, calls in FailOnCalls aren't counted.
+func WithFailureBudget(n int) Option {
+	return func(f *FakeService) {
+		f.failureBudget = n
+	}
+}
+
+// takeFailure reports whether another fault may be injected into e
+// without going over either its MaxFailureCount, given as max, or the
+// service's failure budget, counting it against both if so.
+func (f *FakeService) takeFailure(e *Endpoint, max int) bool {
+	if !takeUpTo(&e.failures, max) {
+		return false
+	}
+	if !takeUpTo(&f.failures, f.failureBudget) {
+		e.failures.Add(-1)
+		return false
+	}
+	return true
+}
+
+// takeUpTo increments count unless it has already reached max, and
+// reports whether it did. A max of 0 or less means there is no limit.
+func takeUpTo(count *atomic.Int64, max int) bool {
+	for {
+		n := count.Load()
+		if max > 0 && n >= int64(max) {
+			return false
+		}
+		if count.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
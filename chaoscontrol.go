@@ -0,0 +1,67 @@
+package fake
+
+import "sync"
+
+// ChaosControl changes a running service's chaos from within a test,
+// e.g. to start healthy, degrade the upstream mid-test and then let it
+// recover, driving a client's circuit breaker through each state.
+type ChaosControl struct {
+	mutex    sync.RWMutex
+	disabled bool
+	rates    map[string]int
+}
+
+func newChaosControl() *ChaosControl {
+	return &ChaosControl{rates: map[string]int{}}
+}
+
+// Chaos returns the service's ChaosControl.
+func (f *FakeService) Chaos() *ChaosControl {
+	return f.chaosControl
+}
+
+// Enable turns chaos back on after Disable.
+func (c *ChaosControl) Enable() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.disabled = false
+}
+
+// Disable stops all chaos, including FailOnCalls, LatencyRamp,
+// BytesPerSecond throttling and idle connection drops, until Enable is
+// called. Calls still count towards each endpoint's call index.
+func (c *ChaosControl) Disable() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.disabled = true
+}
+
+// SetRate sets the FailureRatePercent of every endpoint at path,
+// taking precedence over both the endpoints' and the ChaosPolicy's own
+// settings. Endpoints registered with a PathPattern are named by that
+// pattern instead. A rate of 0 makes the endpoints healthy again.
+func (c *ChaosControl) SetRate(path string, percent int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rates[path] = percent
+}
+
+func (c *ChaosControl) enabled() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return !c.disabled
+}
+
+func (c *ChaosControl) rate(path string) (int, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	percent, ok := c.rates[path]
+	return percent, ok
+}
+
+func (c *ChaosControl) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.disabled = false
+	c.rates = map[string]int{}
+}
@@ -0,0 +1,49 @@
+package fake
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChaosControlSetRateOnPathPattern(t *testing.T) {
+	f := New()
+	f.AddEndpoint(&Endpoint{PathPattern: "/pets/[0-9]+", Method: http.MethodGet, Response: "ok", Optional: true})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	f.Chaos().SetRate("/pets/[0-9]+", 100)
+	resp, err := http.Get(f.BaseURL() + "/pets/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+}
+
+func TestChaosControlDisableStopsLatencyRampAndThrottling(t *testing.T) {
+	f := New()
+	f.AddEndpoint(&Endpoint{
+		Path:           "/slow",
+		Method:         http.MethodGet,
+		Response:       "a response well over one byte long",
+		LatencyRamp:    &LatencyRamp{Start: time.Second},
+		BytesPerSecond: 1,
+		Optional:       true,
+	})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	f.Chaos().Disable()
+	start := time.Now()
+	resp, err := http.Get(f.BaseURL() + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("disabled chaos still took %s", elapsed)
+	}
+}
@@ -0,0 +1,80 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChaosFault is a kind of fault chaos can inject into a call.
+type ChaosFault string
+
+const (
+	FaultLatency         ChaosFault = "latency"
+	FaultConnectionReset ChaosFault = "connection reset"
+	FaultHang            ChaosFault = "hang"
+	FaultFailure         ChaosFault = "failure"
+	FaultCorrupt         ChaosFault = "corrupt"
+	FaultTruncate        ChaosFault = "truncate"
+	FaultHeaders         ChaosFault = "headers"
+	FaultBrownOut        ChaosFault = "brown-out"
+)
+
+// ChaosEvent records a fault chaos injected into a call, so a failing
+// chaotic test can tell injected faults apart from real bugs.
+type ChaosEvent struct {
+	Method string
+	URL    string
+	// Path is the path the endpoint was registered with and Call which
+	// call to it (1-indexed) the fault was injected into.
+	Path  string
+	Call  int
+	Fault ChaosFault
+	// Delay is how long the call was held up, for FaultLatency.
+	Delay time.Duration
+	Time  time.Time
+}
+
+// recordChaos adds a fault injected into the call being handled by c
+// to the service's chaos report.
+func (f *FakeService) recordChaos(e *Endpoint, c *gin.Context, fault ChaosFault, delay time.Duration) {
+	f.chaosEventsMutex.Lock()
+	defer f.chaosEventsMutex.Unlock()
+	f.chaosEvents = append(f.chaosEvents, ChaosEvent{
+		Method: c.Request.Method,
+		URL:    c.Request.URL.String(),
+		Path:   e.route(),
+		Call:   CallIndex(c),
+		Fault:  fault,
+		Delay:  delay,
+		Time:   time.Now(),
+	})
+}
+
+// ChaosReport returns every fault chaos has injected, in the order
+// they happened. It is also printed by TidyUp when the test fails.
+func (f *FakeService) ChaosReport() []ChaosEvent {
+	f.chaosEventsMutex.Lock()
+	defer f.chaosEventsMutex.Unlock()
+	return append([]ChaosEvent(nil), f.chaosEvents...)
+}
+
+// chaosReport formats the chaos seed and report for a failing test.
+func (f *FakeService) chaosReport() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FakeService chaos seed: %d", f.chaosSeed)
+	events := f.ChaosReport()
+	if len(events) == 0 {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nFakeService injected %d chaos fault(s):", len(events))
+	for _, ev := range events {
+		fmt.Fprintf(&b, "\n  %s %s (call %d to %s): %s", ev.Method, ev.URL, ev.Call, ev.Path, ev.Fault)
+		if ev.Delay > 0 {
+			fmt.Fprintf(&b, " of %s", ev.Delay)
+		}
+	}
+	return b.String()
+}
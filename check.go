@@ -0,0 +1,174 @@
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EndpointCheck checks the calls made to a route, returning an error
+// describing any mismatch. It backs the Verify API and can be used
+// directly where testify isn't available, such as in TestMain.
+type EndpointCheck struct {
+	f      *FakeService
+	path   string
+	method string
+}
+
+// Check checks calls made to path with any method.
+func (f *FakeService) Check(path string) *EndpointCheck {
+	return f.CheckRoute("", path)
+}
+
+// CheckRoute checks calls made to path with the given method.
+func (f *FakeService) CheckRoute(method, path string) *EndpointCheck {
+	return &EndpointCheck{f: f, path: path, method: method}
+}
+
+func (c *EndpointCheck) String() string {
+	if c.method == "" {
+		return c.path
+	}
+	return fmt.Sprintf("%s %s", c.method, c.path)
+}
+
+// CalledTimes checks the route was called exactly n times.
+func (c *EndpointCheck) CalledTimes(n int) error {
+	if calls := c.f.CallCount(c.path, c.method); calls != n {
+		return fmt.Errorf("expected %s to be called %d times but it was called %d times", c, n, calls)
+	}
+	return nil
+}
+
+// CalledOnce checks the route was called exactly once.
+func (c *EndpointCheck) CalledOnce() error {
+	return c.CalledTimes(1)
+}
+
+// NeverCalled checks the route was not called at all.
+func (c *EndpointCheck) NeverCalled() error {
+	if calls := c.f.CallCount(c.path, c.method); calls != 0 {
+		return fmt.Errorf("expected %s to never be called but it was called %d times", c, calls)
+	}
+	return nil
+}
+
+// CalledAtLeast checks the route was called n or more times.
+func (c *EndpointCheck) CalledAtLeast(n int) error {
+	if calls := c.f.CallCount(c.path, c.method); calls < n {
+		return fmt.Errorf("expected %s to be called at least %d times but it was called %d times", c, n, calls)
+	}
+	return nil
+}
+
+// LastJSONBody checks the body of the most recent call to the route is
+// JSON equal to expected, describing every difference if not.
+func (c *EndpointCheck) LastJSONBody(expected string) error {
+	requests := c.f.requestsFor(c.path, c.method)
+	if len(requests) == 0 {
+		return fmt.Errorf("expected %s to receive a JSON body but it was never called", c)
+	}
+	last := requests[len(requests)-1]
+
+	diffs, err := jsonDiff([]byte(expected), last.Body)
+	if err != nil {
+		return fmt.Errorf("could not compare the JSON body sent to %s: %w", c, err)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("JSON body sent to %s did not match:\n%s", c, formatJSONDiff(diffs))
+	}
+	return nil
+}
+
+// CheckOrder checks that the given paths were called in order, see
+// VerifyOrder.
+func (f *FakeService) CheckOrder(paths ...string) error {
+	order := f.CallOrder()
+	next := 0
+	for _, path := range order {
+		if next < len(paths) && path == paths[next] {
+			next++
+		}
+	}
+	if next == len(paths) {
+		return nil
+	}
+	return fmt.Errorf("endpoints were not called in the expected order\nexpected: %s\nfirst missing call: %s\nactual: %s",
+		strings.Join(paths, " -> "), paths[next], strings.Join(order, " -> "))
+}
+
+// WaitForE is like WaitFor but returns an error on timeout.
+func (f *FakeService) WaitForE(path string, n int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		calls := f.CallCount(path, "")
+		if calls >= n {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s to be called %d times, it was called %d times", timeout, path, n, calls)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// checkCalls checks every endpoint was called within its MinCalls and
+// MaxCalls bounds, returning an error for each that wasn't.
+func (f *FakeService) checkCalls() []error {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var errs []error
+	for _, e := range f.Endpoints {
+		calls := e.CallCount()
+		minCalls := e.MinCalls
+		if minCalls == 0 {
+			minCalls = 1
+		}
+		if e.Optional {
+			minCalls = 0
+		}
+		if minCalls == 1 && calls == 0 {
+			errs = append(errs, fmt.Errorf("endpoint %s has not been called within this test", e.route()))
+		} else if calls < minCalls {
+			errs = append(errs, fmt.Errorf("endpoint %s was called %d times, expected at least %d", e.route(), calls, minCalls))
+		}
+		if e.MaxCalls > 0 && calls > e.MaxCalls {
+			errs = append(errs, fmt.Errorf("endpoint %s was called %d times, expected at most %d", e.route(), calls, e.MaxCalls))
+		}
+	}
+	return errs
+}
+
+// TidyUpE shuts the service down and returns an error describing any
+// endpoint called outside of its expected bounds or requests that don't
+// match their snapshot, writing the cassette and Pact contract if there
+// are none (see WithSnapshots, WithCassette and WithPactOutput).
+// Unlike TidyUp it doesn't need a test, so it can be used from TestMain
+// or benchmarks.
+func (f *FakeService) TidyUpE() error {
+	defer f.close()
+	if unmatched := f.UnmatchedRequests(); len(unmatched) > 0 {
+		fmt.Println(f.unmatchedReport(unmatched))
+	}
+	err := errors.Join(f.checkCalls()...)
+	if err != nil {
+		fmt.Println(f.chaosReport())
+		return err
+	}
+	if f.snapshotDir != "" {
+		if f.t == nil {
+			return fmt.Errorf("request snapshots are named after the test, so need the service to be Run")
+		}
+		if err := f.checkSnapshots(f.t.Name()); err != nil {
+			return err
+		}
+	}
+	if f.cassette != nil {
+		if err := f.cassette.write(); err != nil {
+			return err
+		}
+	}
+	return f.writePact()
+}
@@ -0,0 +1,221 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceConfig is the layout of a config file read by FromConfig.
+type serviceConfig struct {
+	Port      string           `yaml:"port"`
+	TLS       bool             `yaml:"tls"`
+	HTTP2     bool             `yaml:"http2"`
+	ChaosSeed int64            `yaml:"chaosSeed"`
+	Chaos     *chaosConfig     `yaml:"chaos"`
+	Endpoints []endpointConfig `yaml:"endpoints"`
+}
+
+type endpointConfig struct {
+	Path     string            `yaml:"path"`
+	Method   string            `yaml:"method"`
+	Host     string            `yaml:"host"`
+	Status   int               `yaml:"status"`
+	Headers  map[string]string `yaml:"headers"`
+	Body     string            `yaml:"body"`
+	BodyFile string            `yaml:"bodyFile"`
+	JSON     any               `yaml:"json"`
+	Template string            `yaml:"template"`
+	Delay    time.Duration     `yaml:"delay"`
+
+	Scenario      string `yaml:"scenario"`
+	RequiredState string `yaml:"requiredState"`
+	NewState      string `yaml:"newState"`
+
+	MinCalls  int  `yaml:"minCalls"`
+	MaxCalls  int  `yaml:"maxCalls"`
+	Optional  bool `yaml:"optional"`
+	FailFirst int  `yaml:"failFirst"`
+
+	Chaos *chaosConfig `yaml:"chaos"`
+}
+
+type chaosConfig struct {
+	FailureRatePercent     int                 `yaml:"failureRatePercent"`
+	ConnectionResetPercent int                 `yaml:"connectionResetPercent"`
+	HangPercent            int                 `yaml:"hangPercent"`
+	HangMax                time.Duration       `yaml:"hangMax"`
+	MaxFailureCount        int                 `yaml:"maxFailureCount"`
+	FailOnCalls            []int               `yaml:"failOnCalls"`
+	Latency                *chaosLatencyConfig `yaml:"latency"`
+}
+
+type chaosLatencyConfig struct {
+	Percent int           `yaml:"percent"`
+	Min     time.Duration `yaml:"min"`
+	Max     time.Duration `yaml:"max"`
+}
+
+// FromConfig builds a FakeService from a YAML or JSON file describing
+// its endpoints, so stubs can be written and reviewed without touching
+// test code, e.g.
+//
+//	port: "8080"
+//	chaos:
+//	  latency: {percent: 10, min: 50ms, max: 200ms}
+//	endpoints:
+//	  - path: /users/:id
+//	    method: GET
+//	    status: 200
+//	    headers: {Content-Type: application/json}
+//	    bodyFile: responses/user.json
+//	    delay: 100ms
+//	    chaos: {failureRatePercent: 5}
+//
+// A response is given by one of body, bodyFile (relative to the config
+// file), json (encoded as JSON) or template (see ResponseTemplate).
+// Any opts are applied after the settings in the file.
+func FromConfig(path string, opts ...Option) (*FakeService, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var config serviceConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	var options []Option
+	if config.Port != "" {
+		options = append(options, WithPort(config.Port))
+	}
+	if config.TLS {
+		options = append(options, WithTLS())
+	}
+	if config.HTTP2 {
+		options = append(options, WithHTTP2())
+	}
+	if config.ChaosSeed != 0 {
+		options = append(options, WithChaosSeed(config.ChaosSeed))
+	}
+	if config.Chaos != nil {
+		if len(config.Chaos.FailOnCalls) > 0 {
+			return nil, fmt.Errorf("%s: failOnCalls can only be set on an endpoint", path)
+		}
+		options = append(options, WithChaos(config.Chaos.policy()))
+	}
+
+	var endpoints []*Endpoint
+	for i, ec := range config.Endpoints {
+		e, err := ec.endpoint(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: endpoint %d (%s): %w", path, i+1, ec.Path, err)
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	f := New(append(options, opts...)...)
+	for _, e := range endpoints {
+		f.AddEndpoint(e)
+	}
+	return f, nil
+}
+
+func (c endpointConfig) endpoint(dir string) (*Endpoint, error) {
+	if c.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	e := &Endpoint{
+		Path:             c.Path,
+		Method:           strings.ToUpper(c.Method),
+		Host:             c.Host,
+		StatusCode:       c.Status,
+		Response:         c.Body,
+		ResponseTemplate: c.Template,
+		Scenario:         c.Scenario,
+		RequiredState:    c.RequiredState,
+		NewState:         c.NewState,
+		MinCalls:         c.MinCalls,
+		MaxCalls:         c.MaxCalls,
+		Optional:         c.Optional,
+		FailFirst:        c.FailFirst,
+	}
+
+	responses := 0
+	for _, set := range []bool{c.Body != "", c.BodyFile != "", c.JSON != nil, c.Template != ""} {
+		if set {
+			responses++
+		}
+	}
+	if responses > 1 {
+		return nil, fmt.Errorf("only one of body, bodyFile, json and template may be set")
+	}
+	if c.BodyFile != "" {
+		file := c.BodyFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bodyFile: %w", err)
+		}
+		e.Response = string(body)
+	}
+	if c.JSON != nil {
+		body, err := json.Marshal(c.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode json: %w", err)
+		}
+		e.Response = string(body)
+		e.ResponseHeaders = http.Header{"Content-Type": {"application/json"}}
+	}
+
+	if len(c.Headers) > 0 {
+		if e.ResponseHeaders == nil {
+			e.ResponseHeaders = http.Header{}
+		}
+		for name, value := range c.Headers {
+			e.ResponseHeaders.Set(name, value)
+		}
+	}
+	if c.Delay > 0 {
+		e.LatencyRamp = &LatencyRamp{Start: c.Delay}
+	}
+
+	if c.Chaos != nil {
+		policy := c.Chaos.policy()
+		e.FailureRatePercent = policy.FailureRatePercent
+		e.ConnectionResetPercent = policy.ConnectionResetPercent
+		e.HangPercent = policy.HangPercent
+		e.HangMax = policy.HangMax
+		e.MaxFailureCount = policy.MaxFailureCount
+		e.ChaosLatency = policy.Latency
+		e.FailOnCalls = c.Chaos.FailOnCalls
+	}
+	return e, nil
+}
+
+func (c *chaosConfig) policy() ChaosPolicy {
+	policy := ChaosPolicy{
+		FailureRatePercent:     c.FailureRatePercent,
+		ConnectionResetPercent: c.ConnectionResetPercent,
+		HangPercent:            c.HangPercent,
+		HangMax:                c.HangMax,
+		MaxFailureCount:        c.MaxFailureCount,
+	}
+	if c.Latency != nil {
+		policy.Latency = &ChaosLatency{Percent: c.Latency.Percent, Min: c.Latency.Min, Max: c.Latency.Max}
+	}
+	return policy
+}
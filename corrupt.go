@@ -0,0 +1,59 @@
+package fake
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// CorruptionMode is a way of mangling a response body.
+type CorruptionMode int
+
+const (
+	// CorruptAny picks one of the other modes at random for each call.
+	CorruptAny CorruptionMode = iota
+	// CorruptBitFlip flips a random bit in the body.
+	CorruptBitFlip
+	// CorruptInvalidUTF8 inserts a byte sequence that isn't valid UTF-8.
+	CorruptInvalidUTF8
+	// CorruptBrokenJSON removes the body's final closing brace or
+	// bracket, leaving JSON that can't be decoded.
+	CorruptBrokenJSON
+)
+
+// ChaosCorrupt mangles response bodies to exercise a client's decode
+// error handling. It applies to Response and ResponseTemplate bodies.
+type ChaosCorrupt struct {
+	// Percent is the chance, from 0 to 100, that a body is corrupted.
+	Percent int
+	Mode    CorruptionMode
+}
+
+func (c *ChaosCorrupt) apply(rng *rand.Rand, body string) (string, bool) {
+	if body == "" || !chance(rng, c.Percent) {
+		return body, false
+	}
+	mode := c.Mode
+	if mode == CorruptAny {
+		mode = CorruptionMode(1 + rng.Intn(3))
+	}
+	return mode.corrupt(rng, body), true
+}
+
+func (m CorruptionMode) corrupt(rng *rand.Rand, body string) string {
+	b := []byte(body)
+	switch m {
+	case CorruptBitFlip:
+		i := rng.Intn(len(b))
+		b[i] ^= 1 << uint(rng.Intn(8))
+		return string(b)
+	case CorruptInvalidUTF8:
+		i := rng.Intn(len(b) + 1)
+		return string(b[:i]) + "\xff\xfe" + string(b[i:])
+	case CorruptBrokenJSON:
+		if i := strings.LastIndexAny(body, "}]"); i >= 0 {
+			return body[:i] + body[i+1:]
+		}
+		return body + "{"
+	}
+	return body
+}
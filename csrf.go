@@ -0,0 +1,110 @@
+package fake
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRF fakes CSRF protection using double-submit tokens. A GET to
+// TokenPath issues a token, set as a cookie and returned in the
+// response, and endpoints added with RequireCSRF reject state-changing
+// calls with a 403 unless they send the cookie back along with the same
+// token in a header or form field.
+type CSRF struct {
+	// TokenPath defaults to "/csrf". It answers with a form holding the
+	// token in a hidden field when HTML is accepted, and JSON otherwise.
+	TokenPath string
+	// CookieName defaults to "csrf_token", HeaderName to "X-CSRF-Token"
+	// and FieldName to "csrf_token".
+	CookieName string
+	HeaderName string
+	FieldName  string
+
+	mutex  sync.Mutex
+	issued map[string]bool
+}
+
+// AddCSRF registers the endpoint issuing tokens for c. It is Optional,
+// as tests checking rejections may never fetch a token.
+func (f *FakeService) AddCSRF(c *CSRF) {
+	if c.TokenPath == "" {
+		c.TokenPath = "/csrf"
+	}
+	if c.CookieName == "" {
+		c.CookieName = "csrf_token"
+	}
+	if c.HeaderName == "" {
+		c.HeaderName = "X-CSRF-Token"
+	}
+	if c.FieldName == "" {
+		c.FieldName = "csrf_token"
+	}
+	f.AddEndpoint(&Endpoint{Path: c.TokenPath, Method: http.MethodGet, Handler: c.issue, Optional: true})
+}
+
+// RequireCSRF makes the endpoint reject state-changing calls, those
+// other than GET, HEAD, OPTIONS and TRACE, without a matching token
+// issued by c. Rejected calls are still recorded.
+func (e *Endpoint) RequireCSRF(c *CSRF) *Endpoint {
+	e.CSRF = c
+	return e
+}
+
+func (c *CSRF) issue(ctx *gin.Context) {
+	token := randomToken()
+	c.mutex.Lock()
+	if c.issued == nil {
+		c.issued = map[string]bool{}
+	}
+	c.issued[token] = true
+	c.mutex.Unlock()
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     c.CookieName,
+		Value:    token,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	})
+	ctx.Header(c.HeaderName, token)
+	if strings.Contains(ctx.GetHeader("Accept"), "text/html") {
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(fmt.Sprintf(
+			"<form method=\"post\"><input type=\"hidden\" name=%q value=%q></form>\n",
+			html.EscapeString(c.FieldName), token)))
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]string{c.FieldName: token})
+}
+
+// allows reports whether r may go ahead, because it doesn't change
+// state or it carries a token c issued in both its cookie and a header
+// or form field.
+func (c *CSRF) allows(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	cookie, err := r.Cookie(c.CookieName)
+	if err != nil {
+		return false
+	}
+	c.mutex.Lock()
+	issued := c.issued[cookie.Value]
+	c.mutex.Unlock()
+	if !issued {
+		return false
+	}
+
+	token := r.Header.Get(c.HeaderName)
+	if token == "" {
+		body, _ := readBody(r)
+		token = r.PostFormValue(c.FieldName)
+		rewindBody(r, body)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
@@ -0,0 +1,270 @@
+package fake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// curlFlagsWithValue are the curl options taking a value that FromCurl
+// understands or safely ignores.
+var curlFlagsWithValue = map[string]string{
+	"-X": "request", "--request": "request",
+	"-H": "header", "--header": "header",
+	"-d": "data", "--data": "data", "--data-raw": "data", "--data-binary": "data", "--data-ascii": "data",
+	"--data-urlencode": "data-urlencode",
+	"--json":           "json",
+	"-u":               "user", "--user": "user",
+	"-A": "user-agent", "--user-agent": "user-agent",
+	"-e": "referer", "--referer": "referer",
+	"-b": "cookie", "--cookie": "cookie",
+	"--url": "url",
+	"-o":    "", "--output": "", "-m": "", "--max-time": "", "--connect-timeout": "",
+	"--retry": "", "-w": "", "--write-out": "", "--cacert": "", "--cert": "", "-E": "",
+	"--key": "", "-x": "", "--proxy": "", "--resolve": "", "--limit-rate": "",
+}
+
+// curlFlags are the curl options without a value that FromCurl
+// understands or safely ignores.
+var curlFlags = map[string]string{
+	"-G": "get", "--get": "get",
+	"-I": "head", "--head": "head",
+	"-s": "", "--silent": "", "-S": "", "--show-error": "", "-L": "", "--location": "",
+	"-k": "", "--insecure": "", "-v": "", "--verbose": "", "-i": "", "--include": "",
+	"-f": "", "--fail": "", "--compressed": "", "-N": "", "--no-buffer": "",
+	"--http1.1": "", "--http2": "", "-#": "", "--progress-bar": "",
+}
+
+// FromCurl returns an endpoint answering the request a curl command
+// makes with response, so stubs can be declared straight from the
+// commands other teams share to describe their APIs. The endpoint
+// matches the command's method, path and query, whatever the host, and
+// requires the headers it sets with -H, -u, -A, -e and -b and the body
+// it sends, compared as JSON when it is JSON. Data sent with -G is
+// matched as the query instead. Options that only affect how curl runs,
+// such as -s or -L, are ignored, and unknown options are an error.
+func FromCurl(command, response string) (*Endpoint, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid curl command: %w", err)
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var (
+		method, rawURL string
+		data           []string
+		get, head      bool
+	)
+	headers := http.Header{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL != "" {
+				return nil, fmt.Errorf("curl command has more than one URL")
+			}
+			rawURL = arg
+			continue
+		}
+		name, value, inline := strings.Cut(arg, "=")
+		if !strings.HasPrefix(arg, "--") {
+			name, value, inline = arg[:2], arg[2:], len(arg) > 2
+		}
+		if option, ok := curlFlags[name]; ok && !inline {
+			get = get || option == "get"
+			head = head || option == "head"
+			continue
+		}
+		option, ok := curlFlagsWithValue[name]
+		if !ok {
+			if flags, ok := combinedCurlFlags(arg); ok {
+				get = get || flags["get"]
+				head = head || flags["head"]
+				continue
+			}
+			return nil, fmt.Errorf("unsupported curl option %s", name)
+		}
+		if !inline {
+			if i++; i == len(args) {
+				return nil, fmt.Errorf("curl option %s needs a value", name)
+			}
+			value = args[i]
+		}
+		switch option {
+		case "request":
+			method = strings.ToUpper(value)
+		case "header":
+			name, value, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid curl header %q", value)
+			}
+			headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		case "data":
+			if strings.HasPrefix(value, "@") {
+				return nil, fmt.Errorf("curl data read from a file isn't supported")
+			}
+			data = append(data, value)
+		case "data-urlencode":
+			if name, content, ok := strings.Cut(value, "="); ok {
+				value = name + "=" + url.QueryEscape(content)
+			} else {
+				value = url.QueryEscape(value)
+			}
+			data = append(data, value)
+		case "json":
+			data = append(data, value)
+			headers.Set("Content-Type", "application/json")
+			headers.Set("Accept", "application/json")
+		case "user":
+			headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "user-agent":
+			headers.Set("User-Agent", value)
+		case "referer":
+			headers.Set("Referer", value)
+		case "cookie":
+			headers.Add("Cookie", value)
+		case "url":
+			rawURL = value
+		}
+	}
+	if rawURL == "" {
+		return nil, fmt.Errorf("curl command has no URL")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid curl URL: %w", err)
+	}
+
+	body := strings.Join(data, "&")
+	if get && body != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += body
+		body = ""
+	}
+	if method == "" {
+		switch {
+		case head:
+			method = http.MethodHead
+		case body != "":
+			method = http.MethodPost
+		default:
+			method = http.MethodGet
+		}
+	}
+
+	e := newReplayEndpoint(method, u, http.StatusOK)
+	e.Optional = false
+	e.Response = response
+	matchesQuery := e.Matcher
+	e.Matcher = func(r *http.Request) bool {
+		if !matchesQuery(r) {
+			return false
+		}
+		for name, values := range headers {
+			for _, value := range values {
+				if !containsString(r.Header.Values(name), value) {
+					return false
+				}
+			}
+		}
+		if body == "" {
+			return true
+		}
+		got, err := io.ReadAll(r.Body)
+		return err == nil && curlBodyMatches(body, string(got))
+	}
+	return e, nil
+}
+
+// combinedCurlFlags splits options run together, such as -sSL, if they
+// are all known options without a value.
+func combinedCurlFlags(arg string) (map[string]bool, bool) {
+	if strings.HasPrefix(arg, "--") {
+		return nil, false
+	}
+	flags := map[string]bool{}
+	for _, ch := range arg[1:] {
+		option, ok := curlFlags["-"+string(ch)]
+		if !ok {
+			return nil, false
+		}
+		flags[option] = true
+	}
+	return flags, true
+}
+
+// curlBodyMatches compares a request body with the one a curl command
+// sends, as JSON if both are JSON.
+func curlBodyMatches(want, got string) bool {
+	var wantJSON, gotJSON any
+	if json.Unmarshal([]byte(want), &wantJSON) == nil && json.Unmarshal([]byte(got), &gotJSON) == nil {
+		return jsonMatches(wantJSON, gotJSON, false, false)
+	}
+	return want == got
+}
+
+// splitShellWords splits a command line into words as a POSIX shell
+// would, honouring quotes, backslash escapes and line continuations.
+func splitShellWords(command string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, ch := range command {
+		switch {
+		case escaped:
+			escaped = false
+			if ch == '\n' {
+				continue
+			}
+			if quote == '"' && !strings.ContainsRune("$`\"\\", ch) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(ch)
+			inWord = true
+		case ch == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				word.WriteRune(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(ch)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
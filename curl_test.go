@@ -0,0 +1,37 @@
+package fake
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{command: `curl https://example.com`, want: []string{"curl", "https://example.com"}},
+		{command: "  curl \t -X  POST\n", want: []string{"curl", "-X", "POST"}},
+		{command: `curl -d '{"a": 1}'`, want: []string{"curl", "-d", `{"a": 1}`}},
+		{command: `curl -H "X-Name: a \"b\" \$c \d"`, want: []string{"curl", "-H", `X-Name: a "b" $c \d`}},
+		{command: `curl -d 'it'\''s'`, want: []string{"curl", "-d", "it's"}},
+		{command: `curl 'a \n b'`, want: []string{"curl", `a \n b`}},
+		{command: "curl \\\n  -X GET", want: []string{"curl", "-X", "GET"}},
+		{command: `curl a\ b`, want: []string{"curl", "a b"}},
+		{command: `curl ''`, want: []string{"curl", ""}},
+		{command: `curl -d "unterminated`, wantErr: true},
+		{command: `curl -d 'unterminated`, wantErr: true},
+		{command: `curl \`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitShellWords(tt.command)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitShellWords(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("splitShellWords(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
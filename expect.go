@@ -0,0 +1,105 @@
+package fake
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// ExpectFunc is a request assertion that can be used as an
+// Endpoint's ExpectationT.
+type ExpectFunc func(testing.TB, *http.Request)
+
+// ExpectAll combines several expectations, running each in turn.
+func ExpectAll(expectations ...ExpectFunc) ExpectFunc {
+	return func(tb testing.TB, r *http.Request) {
+		tb.Helper()
+		for _, expect := range expectations {
+			expect(tb, r)
+		}
+	}
+}
+
+// ExpectHeader asserts the request carries the header with the given value.
+func ExpectHeader(name, value string) ExpectFunc {
+	return func(tb testing.TB, r *http.Request) {
+		tb.Helper()
+		if got := r.Header.Get(name); got != value {
+			tb.Errorf("%s %s: expected header %s to be %q, got %q", r.Method, r.URL, name, value, got)
+		}
+	}
+}
+
+// ExpectQuery asserts the request's query string has the parameter with
+// the given value.
+func ExpectQuery(name, value string) ExpectFunc {
+	return func(tb testing.TB, r *http.Request) {
+		tb.Helper()
+		query := r.URL.Query()
+		if !query.Has(name) {
+			tb.Errorf("%s %s: expected query parameter %s to be %q but it was missing", r.Method, r.URL, name, value)
+			return
+		}
+		if got := query.Get(name); got != value {
+			tb.Errorf("%s %s: expected query parameter %s to be %q, got %q", r.Method, r.URL, name, value, got)
+		}
+	}
+}
+
+// ExpectMethod asserts the request was made with the given method.
+func ExpectMethod(method string) ExpectFunc {
+	return func(tb testing.TB, r *http.Request) {
+		tb.Helper()
+		if r.Method != method {
+			tb.Errorf("%s %s: expected method %s", r.Method, r.URL, method)
+		}
+	}
+}
+
+// ExpectJSONBody asserts the request body is JSON equal to v. v may be a
+// string or []byte holding JSON, or any value that marshals to JSON.
+// Failures describe each difference rather than printing both bodies.
+func ExpectJSONBody(v any) ExpectFunc {
+	return func(tb testing.TB, r *http.Request) {
+		tb.Helper()
+		var expected []byte
+		switch v := v.(type) {
+		case string:
+			expected = []byte(v)
+		case []byte:
+			expected = v
+		default:
+			var err error
+			if expected, err = json.Marshal(v); err != nil {
+				tb.Errorf("failed to marshal expected JSON body: %s", err.Error())
+				return
+			}
+		}
+
+		body, err := readBody(r)
+		if err != nil {
+			tb.Errorf("%s %s: failed to read body: %s", r.Method, r.URL, err.Error())
+			return
+		}
+		diffs, err := jsonDiff(expected, body)
+		if err != nil {
+			tb.Errorf("%s %s: %s", r.Method, r.URL, err.Error())
+			return
+		}
+		if len(diffs) > 0 {
+			tb.Errorf("%s %s: JSON body did not match:\n%s", r.Method, r.URL, formatJSONDiff(diffs))
+		}
+	}
+}
+
+// readBody reads the request body and rewinds it, so expectations can
+// be combined without consuming the body for one another.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	rewindBody(r, body)
+	return body, err
+}
@@ -0,0 +1,50 @@
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// errExpectationFailNow is panicked by serverTB to unwind an
+// expectation that called FailNow, Fatal or Fatalf.
+var errExpectationFailNow = errors.New("expectation failed")
+
+// serverTB wraps the owning test so expectations running on the
+// server's goroutines can report failures. FailNow may only be called
+// from the test's own goroutine, so here it marks the test as failed
+// and unwinds the expectation instead.
+type serverTB struct {
+	testing.TB
+}
+
+func (s serverTB) FailNow() {
+	s.TB.Fail()
+	panic(errExpectationFailNow)
+}
+
+func (s serverTB) Fatal(args ...any) {
+	s.TB.Error(args...)
+	panic(errExpectationFailNow)
+}
+
+func (s serverTB) Fatalf(format string, args ...any) {
+	s.TB.Errorf(format, args...)
+	panic(errExpectationFailNow)
+}
+
+// runExpectationT runs the endpoint's ExpectationT against the test
+// that started the service, recovering any panic it raises.
+func (f *FakeService) runExpectationT(e *Endpoint, r *http.Request) {
+	if f.t == nil {
+		fmt.Printf("%s: %s - skipping ExpectationT as the service was not started with Run\n", r.Method, r.URL)
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil && p != errExpectationFailNow {
+			f.t.Errorf("expectation for %s %s panicked: %v", r.Method, r.URL, p)
+		}
+	}()
+	e.ExpectationT(serverTB{TB: f.t}, r)
+}
@@ -1,12 +1,20 @@
 package fake
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -18,15 +26,241 @@ type Endpoint struct {
 	StatusCode  int
 	Expectation func(*http.Request)
 
-	calls int
-	mutex sync.Mutex
+	// Host, if set, restricts the endpoint to requests for that host,
+	// for faking third-party APIs with the service as a forward proxy,
+	// see ProxyURL. Without a port it matches the host on any port.
+	Host string
+
+	// PathPattern, if set, is a regular expression the whole request
+	// path must match, for paths a route can't express. It takes the
+	// place of Path, and endpoints with a pattern are only tried once
+	// no route matches the request.
+	PathPattern string
+
+	// Matcher, if set, must also accept a request for the endpoint to
+	// match it. It may read the request body, which is rewound after.
+	Matcher func(*http.Request) bool
+
+	// ExpectationT is like Expectation but is handed the test that
+	// started the service, so assertions can fail it directly. Fatal
+	// failures stop the expectation without stopping the server. See
+	// ExpectAll and the other Expect helpers for common assertions.
+	ExpectationT ExpectFunc
+
+	// Method restricts the endpoint to a single HTTP method. If left
+	// empty the endpoint will respond to any method.
+	Method string
+
+	// ResponseTemplate, if set, is rendered with text/template against
+	// TemplateData to produce the response in place of Response, e.g.
+	// `{"attempt": {{ .CallCount }}}`.
+	ResponseTemplate string
+
+	// ResponseHeaders are set on the response before Response,
+	// ResponseTemplate or ResponseBody is written. They don't apply to
+	// responses written by a Handler.
+	ResponseHeaders http.Header
+
+	// ResponseBody, if set, is streamed back as the response body in
+	// place of Response. A reader can only be consumed once, so the
+	// first call streams it and later calls replay what was read.
+	ResponseBody io.Reader
+
+	// Scenario names a scenario this endpoint takes part in. When
+	// RequiredState is set the endpoint only matches while the scenario
+	// is in that state, and when NewState is set a matching call moves
+	// the scenario into it. Every scenario starts in ScenarioStarted.
+	Scenario      string
+	RequiredState string
+	NewState      string
+
+	// FailFirst fails the first N calls to the endpoint with
+	// FailFirstStatusCode (500 by default) before responding normally.
+	// Calls rejected for missing credentials don't count.
+	FailFirst           int
+	FailFirstStatusCode int
+
+	// Handler, if set, is responsible for writing the response and
+	// takes the place of Response and StatusCode.
+	Handler gin.HandlerFunc
+
+	// MinCalls and MaxCalls bound how many times the endpoint must be
+	// called, enforced by TidyUp. MinCalls defaults to 1 and a MaxCalls
+	// of 0 means there is no upper limit.
+	MinCalls int
+	MaxCalls int
+
+	// Optional endpoints may go uncalled without failing TidyUp, for
+	// shared fakes with routes only some tests exercise.
+	Optional bool
+
+	// LatencyRamp, if set, delays each response by an amount that
+	// increases with every call to this endpoint.
+	LatencyRamp *LatencyRamp
+
+	// FailureRatePercent is the chance, from 0 to 100, that a call
+	// fails. Failed calls are answered by FailureHandler, or by the
+	// service's default failure handler (see WithFailureHandler) if it
+	// isn't set.
+	FailureRatePercent int
+	FailureHandler     gin.HandlerFunc
+
+	// FailOnCalls lists the calls (1-indexed) that always fail, in the
+	// same way as FailureRatePercent, so recovery from a flaky upstream
+	// can be tested deterministically. See CallRange.
+	FailOnCalls []int
+
+	// MaxFailureCount caps how many faults (failures, connection resets
+	// and hangs) chaos injects into this endpoint, counted from the
+	// start of the test or the last Reset, after which it behaves
+	// normally. 0 inherits the ChaosPolicy's limit, if any, and a
+	// negative value means the endpoint never stops failing. Calls in
+	// FailOnCalls don't count towards the limit.
+	MaxFailureCount int
+
+	// BrownOut, if set, fails every call with a 503 for a while from
+	// the first call, then lets the endpoint recover.
+	BrownOut *BrownOut
+
+	// MethodChaos overrides the endpoint's chaos settings for requests
+	// with a given method, keyed by upper case method names, e.g. to
+	// fail only POSTs to an endpoint that serves several methods.
+	MethodChaos map[string]ChaosPolicy
+
+	// ChaosLatency, if set, randomly delays responses.
+	ChaosLatency *ChaosLatency
+
+	// ConnectionResetPercent is the chance, from 0 to 100, that the
+	// connection is reset instead of a response being sent.
+	ConnectionResetPercent int
+
+	// HangPercent is the chance, from 0 to 100, that the endpoint
+	// accepts a call and then never responds, holding the request until
+	// the client gives up, HangMax elapses (if set) or the service is
+	// tidied up.
+	HangPercent int
+	HangMax     time.Duration
+
+	// NoChaos exempts the endpoint from the service's ChaosPolicy. Its
+	// own chaos settings still apply.
+	NoChaos bool
+
+	// ChaosTruncate, if set, randomly cuts responses short.
+	ChaosTruncate *ChaosTruncate
+
+	// ChaosCorrupt, if set, randomly mangles response bodies.
+	ChaosCorrupt *ChaosCorrupt
+
+	// ChaosHeaders, if set, randomly drops or duplicates response
+	// headers.
+	ChaosHeaders *ChaosHeaders
+
+	// RateLimit, if set, rejects calls with a 429 once the limit for
+	// the current window has been used up.
+	RateLimit *RateLimit
+
+	// BytesPerSecond, if set, throttles the response body to the given
+	// rate, for testing large-download timeouts and progress reporting.
+	BytesPerSecond int
+
+	// BasicAuth, if set, rejects calls without the right Basic
+	// credentials with a 401, see RequireBasicAuth.
+	BasicAuth *BasicAuth
+
+	// APIKey, if set, rejects calls without the right API key, see
+	// RequireAPIKey.
+	APIKey *APIKey
+
+	// WebhookSignature, if set, rejects calls whose body isn't signed
+	// as it describes, see RequireWebhookSignature.
+	WebhookSignature *WebhookSignature
+
+	// Session, if set, rejects calls without a live session cookie, see
+	// RequireSession.
+	Session *CookieSession
+
+	// CSRF, if set, rejects state-changing calls without a matching CSRF
+	// token, see RequireCSRF.
+	CSRF *CSRF
+
+	// Callback, if set, calls back to the client after the endpoint
+	// responds, see Callback.
+	Callback *Callback
+
+	recorder recorder
+
+	// stream is set for endpoints whose Handler reads the request body
+	// as it arrives, such as gRPC reflection streams. Their bodies are
+	// neither buffered nor recorded, so they can't have expectations.
+	stream bool
+
+	// failures counts the faults chaos has injected, see MaxFailureCount.
+	failures atomic.Int64
+
+	// admitted counts the calls that got past the endpoint's
+	// credential checks, see FailFirst.
+	admitted atomic.Int64
+
+	// pathPattern is PathPattern, compiled by AddEndpoint.
+	pathPattern *regexp.Regexp
+
+	// rank orders WireMock mappings by priority, lowest first, across
+	// routes and PathPatterns alike. It is zero for other endpoints,
+	// whose routes always take precedence over patterns.
+	rank int
+
+	// bodyMutex serialises reads of ResponseBody, which is kept in body
+	// for later calls once bodyRead is set.
+	bodyMutex sync.Mutex
+	body      []byte
+	bodyRead  bool
+}
+
+// writeResponseBody streams ResponseBody to w the first time it is
+// written, keeping a copy to replay on later calls.
+func (e *Endpoint) writeResponseBody(w io.Writer) error {
+	e.bodyMutex.Lock()
+	defer e.bodyMutex.Unlock()
+
+	if e.bodyRead {
+		_, err := w.Write(e.body)
+		return err
+	}
+	var body bytes.Buffer
+	_, err := io.Copy(w, io.TeeReader(e.ResponseBody, &body))
+	if err != nil {
+		// The client may have gone away mid-body, but later calls
+		// should still get the whole of it.
+		io.Copy(&body, e.ResponseBody)
+	}
+	e.body, e.bodyRead = body.Bytes(), true
+	return err
 }
 
-func (e *Endpoint) recordCall() {
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
+// CallCount returns the number of times the endpoint has been called.
+func (e *Endpoint) CallCount() int {
+	return e.recorder.count()
+}
 
-	e.calls++
+// Requests returns a copy of every request the endpoint has received,
+// oldest first.
+func (e *Endpoint) Requests() []RecordedRequest {
+	return e.recorder.requests()
+}
+
+func (e *Endpoint) reset() {
+	e.recorder.reset()
+	e.failures.Store(0)
+	e.admitted.Store(0)
+	if e.BrownOut != nil {
+		e.BrownOut.reset()
+	}
+	if e.RateLimit != nil {
+		e.RateLimit.reset()
+	}
+	if e.Callback != nil {
+		e.Callback.reset()
+	}
 }
 
 type FakeService struct {
@@ -34,47 +268,489 @@ type FakeService struct {
 	router     *gin.Engine
 	testserver *httptest.Server
 	Endpoints  []*Endpoint
+
+	// State is shared between custom Handlers and the test, so
+	// handlers can record what happened for the test to inspect.
+	State *StateStore
+
+	// routes holds the endpoints registered against each path, in
+	// the order they were added, so several stubs can share a route.
+	routes    map[string][]*Endpoint
+	patterns  []*Endpoint
+	mutex     sync.RWMutex
+	scenarios *scenarios
+	strict    bool
+
+	snapshotDir string
+	pactOutput  *pactOutput
+	cassette    *cassette
+	health      *Health
+	chaos       *ChaosPolicy
+	chaosSeed   int64
+	rng         *rand.Rand
+
+	// failureBudget caps the faults chaos injects across every
+	// endpoint, see WithFailureBudget, and failures counts them.
+	failureBudget int
+	failures      atomic.Int64
+
+	chaosControl   *ChaosControl
+	failureHandler gin.HandlerFunc
+
+	// closing is closed when the service is shutting down.
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// t is the test the service was started by, used to report
+	// failures that happen on the server's goroutines.
+	t testing.TB
+
+	// order records the path of every endpoint called, in the order
+	// the calls were received, across all endpoints.
+	order      []string
+	orderMutex sync.Mutex
+
+	unmatchedRequests []UnmatchedRequest
+	unmatchedMutex    sync.Mutex
+
+	// tls serves the service over HTTPS, see WithTLS, http2 allows
+	// HTTP/2, see WithHTTP2, http3 also serves HTTP/3 with quic, see
+	// WithHTTP3, and requireClientCert insists on client certificates
+	// issued by clientCA, see RequireClientCert.
+	tls               bool
+	http2             bool
+	http3             bool
+	quic              *http3Server
+	requireClientCert bool
+	clientCA          *certAuthority
+	clientCAMutex     sync.Mutex
+
+	// validators check every request against OpenAPI documents, see
+	// WithRequestValidation.
+	validators []*openAPIValidator
+
+	// mitm intercepts HTTPS sent through the service as a proxy, see
+	// WithProxyMITM.
+	mitm *mitmProxy
+
+	chaosEvents      []ChaosEvent
+	chaosEventsMutex sync.Mutex
+
+	// wireMockRank is the rank given to the last WireMock mapping added.
+	wireMockRank int
 }
 
 func NewFakeHTTP(port string) *FakeService {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	return &FakeService{
-		port:       port,
-		router:     router,
-		testserver: httptest.NewUnstartedServer(router),
-	}
+	return New(WithPort(port))
 }
 
 func (f *FakeService) AddEndpoint(e *Endpoint) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
 	f.Endpoints = append(f.Endpoints, e)
-	f.router.Any(e.Path, func(c *gin.Context) {
-		// If there are specific expectations attached
-		// to a given endpoint, run through these expectations now.
-		if e.Expectation != nil {
-			e.Expectation(c.Request)
+	if e.PathPattern != "" {
+		e.pathPattern = regexp.MustCompile("^(?:" + e.PathPattern + ")$")
+		f.patterns = append(f.patterns, e)
+		return
+	}
+	if _, ok := f.routes[e.Path]; !ok {
+		f.router.Any(e.Path, f.dispatch(e.Path))
+	}
+	f.routes[e.Path] = append(f.routes[e.Path], e)
+}
+
+// CallCount returns the total number of calls made to the endpoints
+// registered against path with the given method. An empty method
+// counts calls to every endpoint registered against path.
+func (f *FakeService) CallCount(path, method string) int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	total := 0
+	for _, e := range f.routes[path] {
+		if method == "" || strings.EqualFold(e.Method, method) {
+			total += e.CallCount()
+		}
+	}
+	return total
+}
+
+// requestsFor returns the requests received by the endpoints registered
+// against path with the given method, oldest first.
+func (f *FakeService) requestsFor(path, method string) []RecordedRequest {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var requests []RecordedRequest
+	for _, e := range f.routes[path] {
+		if method == "" || strings.EqualFold(e.Method, method) {
+			requests = append(requests, e.Requests()...)
+		}
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].Time.Before(requests[j].Time)
+	})
+	return requests
+}
+
+// Reset clears call counts, recorded requests, scenario states, runtime
+// chaos changes, the chaos report and the shared State so a running
+// FakeService can be reused across subtests without one test's traffic
+// leaking into the next.
+func (f *FakeService) Reset() {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	for _, e := range f.Endpoints {
+		e.reset()
+	}
+	f.scenarios.reset()
+	f.State.Clear()
+	f.failures.Store(0)
+	f.chaosControl.reset()
+	if f.chaos != nil && f.chaos.BrownOut != nil {
+		f.chaos.BrownOut.reset()
+	}
+
+	f.orderMutex.Lock()
+	f.order = nil
+	f.orderMutex.Unlock()
+
+	f.unmatchedMutex.Lock()
+	f.unmatchedRequests = nil
+	f.unmatchedMutex.Unlock()
+
+	f.chaosEventsMutex.Lock()
+	f.chaosEvents = nil
+	f.chaosEventsMutex.Unlock()
+}
+
+// dispatch returns the gin handler for a given path which picks the
+// first registered endpoint that matches the incoming request.
+func (f *FakeService) dispatch(path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		e := f.match(path, c.Request)
+		if e == nil {
+			f.unmatched(c)
+			return
+		}
+		f.serve(e, c)
+	}
+}
+
+// serve handles a request with the endpoint it matched, recording the
+// response.
+func (f *FakeService) serve(e *Endpoint, c *gin.Context) {
+	start := time.Now()
+	capture := &responseCapture{ResponseWriter: c.Writer}
+	c.Writer = capture
+	f.handle(e, c)
+	e.recorder.recordResponse(c.GetInt(callIndexKey), capture.recorded(), time.Since(start))
+}
+
+func (f *FakeService) match(path string, r *http.Request) *Endpoint {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	for _, e := range f.routes[path] {
+		if f.accepts(e, r) {
+			return f.outranking(e, r)
+		}
+	}
+	return nil
+}
+
+// outranking returns the endpoint with a PathPattern that matches the
+// request and ranks above e, or e if there is none.
+func (f *FakeService) outranking(e *Endpoint, r *http.Request) *Endpoint {
+	if e.rank == 0 {
+		return e
+	}
+	for _, p := range f.patterns {
+		if p.rank != 0 && p.rank < e.rank && p.pathPattern.MatchString(r.URL.Path) && f.accepts(p, r) {
+			return p
+		}
+	}
+	return e
+}
+
+// matchPattern returns the first endpoint with a PathPattern that
+// matches the request, for requests no route matched.
+func (f *FakeService) matchPattern(r *http.Request) *Endpoint {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	for _, e := range f.patterns {
+		if e.pathPattern.MatchString(r.URL.Path) && f.accepts(e, r) {
+			return e
+		}
+	}
+	return nil
+}
+
+// accepts reports whether a request on the endpoint's path matches the
+// rest of the endpoint.
+func (f *FakeService) accepts(e *Endpoint, r *http.Request) bool {
+	if e.Method != "" && !strings.EqualFold(e.Method, r.Method) {
+		return false
+	}
+	if !e.matchesHost(r.Host) {
+		return false
+	}
+	if !e.matches(r) {
+		return false
+	}
+	return f.scenarios.matches(e)
+}
+
+// matches runs the endpoint's Matcher, if any, rewinding the request
+// body so it can be read again.
+func (e *Endpoint) matches(r *http.Request) bool {
+	if e.Matcher == nil {
+		return true
+	}
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	rewindBody(r, body)
+	defer rewindBody(r, body)
+	return e.Matcher(r)
+}
+
+// route describes the requests the endpoint is registered against, its
+// PathPattern if it has one and its Path otherwise.
+func (e *Endpoint) route() string {
+	if e.PathPattern != "" {
+		return e.PathPattern
+	}
+	return e.Path
+}
+
+// rejectUnauthorized answers calls without the credentials, signature,
+// session or CSRF token the endpoint requires, reporting whether it did.
+func rejectUnauthorized(e *Endpoint, c *gin.Context) bool {
+	if e.BasicAuth != nil && !e.BasicAuth.allows(c.Request) {
+		fmt.Printf("%s: %s - HTTP 401 wrong or missing credentials\n", c.Request.Method, c.Request.URL)
+		e.BasicAuth.reject(c)
+		return true
+	}
+	if e.APIKey != nil && !e.APIKey.allows(c.Request) {
+		fmt.Printf("%s: %s - HTTP %d wrong or missing API key\n", c.Request.Method, c.Request.URL, e.APIKey.status())
+		e.APIKey.reject(c)
+		return true
+	}
+	if e.WebhookSignature != nil && !e.WebhookSignature.Verify(c.Request) {
+		fmt.Printf("%s: %s - HTTP 401 invalid webhook signature\n", c.Request.Method, c.Request.URL)
+		c.Status(http.StatusUnauthorized)
+		return true
+	}
+	if e.Session != nil {
+		if _, ok := e.Session.Session(c.Request); !ok {
+			fmt.Printf("%s: %s - HTTP 401 no session\n", c.Request.Method, c.Request.URL)
+			c.Status(http.StatusUnauthorized)
+			return true
 		}
+	}
+	if e.CSRF != nil && !e.CSRF.allows(c.Request) {
+		fmt.Printf("%s: %s - HTTP 403 missing or mismatched CSRF token\n", c.Request.Method, c.Request.URL)
+		c.Status(http.StatusForbidden)
+		return true
+	}
+	return false
+}
+
+func (f *FakeService) handle(e *Endpoint, c *gin.Context) {
+	var recorded RecordedRequest
+	if e.stream {
+		recorded = recordRequestHeader(c.Request)
+	} else {
+		recorded = recordRequest(c.Request)
+	}
+
+	// If there are specific expectations attached
+	// to a given endpoint, run through these expectations now.
+	if e.Expectation != nil {
+		e.Expectation(c.Request)
+		rewindBody(c.Request, recorded.Body)
+	}
+	if e.ExpectationT != nil {
+		f.runExpectationT(e, c.Request)
+		rewindBody(c.Request, recorded.Body)
+	}
+
+	call := e.recorder.record(recorded)
+	f.recordOrder(e)
+	c.Set(callIndexKey, call)
+
+	// Calls without the credentials the endpoint requires are recorded
+	// but otherwise leave it untouched, so they don't move scenarios on
+	// or use up FailFirst.
+	if rejectUnauthorized(e, c) {
+		return
+	}
+	admitted := int(e.admitted.Add(1))
+	f.scenarios.transition(e, c.Request)
+
+	if e.LatencyRamp != nil && f.chaosControl.enabled() {
+		sleep(c.Request.Context(), e.LatencyRamp.delayFor(call))
+	}
+	if f.applyChaos(e, c) {
+		return
+	}
+
+	if e.ChaosHeaders != nil && f.chaosControl.enabled() && e.ChaosHeaders.applies(f.rng) {
+		fmt.Printf("%s: %s - chaos corrupted headers\n", c.Request.Method, c.Request.URL)
+		f.recordChaos(e, c, FaultHeaders, 0)
+		c.Writer = &headerCorruptingWriter{ResponseWriter: c.Writer, headers: e.ChaosHeaders}
+	}
+	if e.BytesPerSecond > 0 && f.chaosControl.enabled() {
+		c.Writer = &pacedWriter{
+			ResponseWriter: c.Writer,
+			ctx:            c.Request.Context(),
+			bytesPerSecond: e.BytesPerSecond,
+		}
+	}
 
-		status := e.StatusCode
+	if e.RateLimit != nil {
+		allowed, remaining, reset := e.RateLimit.take(time.Now())
+		if !allowed {
+			fmt.Printf("%s: %s - HTTP 429 rate limited\n", c.Request.Method, c.Request.URL)
+			rejectRateLimited(c, e.RateLimit, reset)
+			return
+		}
+		setRateLimitHeaders(c, e.RateLimit, remaining, reset, false)
+	}
+
+	if admitted <= e.FailFirst {
+		status := e.FailFirstStatusCode
 		if status == 0 {
-			status = http.StatusOK
+			status = http.StatusInternalServerError
 		}
-		fmt.Printf("%s: %s - HTTP %d\n%s", c.Request.Method, c.Request.URL, status, e.Response)
-		e.recordCall()
+		fmt.Printf("%s: %s - HTTP %d (failing call %d of %d)\n", c.Request.Method, c.Request.URL, status, admitted, e.FailFirst)
+		c.Status(status)
+		return
+	}
 
-		c.String(status, e.Response)
-	})
+	if e.Callback != nil {
+		defer func() {
+			// Send the response on before the callback is scheduled, as
+			// the handler has returned but the server may still buffer
+			// what it wrote.
+			c.Writer.Flush()
+			f.triggerCallback(e.Callback, c, call, recorded.Body)
+		}()
+	}
+
+	if e.Handler != nil {
+		e.Handler(c)
+		fmt.Printf("%s: %s - HTTP %d\n", c.Request.Method, c.Request.URL, c.Writer.Status())
+		return
+	}
+
+	for name, values := range e.ResponseHeaders {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+
+	status := e.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := e.Response
+	if e.ResponseTemplate != "" {
+		var err error
+		response, err = renderTemplate(e.ResponseTemplate, newTemplateData(call, f.State, c.Request, recorded.Body))
+		if err != nil {
+			fmt.Printf("%s: %s - failed to render response template: %s\n", c.Request.Method, c.Request.URL, err.Error())
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if e.ChaosCorrupt != nil && f.chaosControl.enabled() {
+		var corrupted bool
+		if response, corrupted = e.ChaosCorrupt.apply(f.rng, response); corrupted {
+			fmt.Printf("%s: %s - chaos corrupted response\n", c.Request.Method, c.Request.URL)
+			f.recordChaos(e, c, FaultCorrupt, 0)
+		}
+	}
+	fmt.Printf("%s: %s - HTTP %d\n%s", c.Request.Method, c.Request.URL, status, response)
+
+	if e.ResponseBody != nil && bodyAllowedForStatus(status) {
+		c.Status(status)
+		if err := e.writeResponseBody(c.Writer); err != nil {
+			fmt.Printf("failed to write response body: %s\n", err.Error())
+		}
+		return
+	}
+
+	// Bodiless responses (204, 304 etc.) or endpoints without a
+	// response shouldn't advertise a Content-Type they never send.
+	if !bodyAllowedForStatus(status) || response == "" {
+		c.Status(status)
+		return
+	}
+	if e.ChaosTruncate != nil && f.chaosControl.enabled() && e.ChaosTruncate.applies(f.rng) {
+		fmt.Printf("%s: %s - chaos truncated response\n", c.Request.Method, c.Request.URL)
+		f.recordChaos(e, c, FaultTruncate, 0)
+		writeTruncated(c, status, response, e.ChaosTruncate)
+		return
+	}
+	c.String(status, response)
+}
+
+// bodyAllowedForStatus reports whether a given response status code
+// permits a body, as described in RFC 9110.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
 }
 
-func (f *FakeService) TidyUp(t *testing.T) {
+func (f *FakeService) TidyUp(t testing.TB) {
+	t.Helper()
 	t.Logf("FakeService tidyup - port:%s", f.port)
-	for _, e := range f.Endpoints {
-		assert.GreaterOrEqual(t, e.calls, 1, "endpoint %s has not been called within this test")
+	for _, err := range f.checkCalls() {
+		assert.Fail(t, err.Error())
+	}
+	f.reportUnmatched(t)
+	f.verifySnapshots(t)
+	f.writePactT(t)
+	f.writeCassetteT(t)
+	if t.Failed() {
+		t.Log(f.chaosReport())
 	}
+	f.close()
+}
+
+// close shuts the test server down, first releasing any handlers that
+// are deliberately hanging so that they don't hold the shutdown up.
+func (f *FakeService) close() {
+	f.closeOnce.Do(func() {
+		close(f.closing)
+	})
 	f.testserver.Close()
+	if f.quic != nil {
+		f.quic.close()
+	}
+	if f.mitm != nil {
+		f.mitm.close()
+	}
 }
 
-func (f *FakeService) Run(t *testing.T) {
+func (f *FakeService) Run(t testing.TB) {
+	t.Helper()
+	f.t = t
 	t.Logf("Fake Service Starting Up on port: %s", f.port)
 	l, err := net.Listen("tcp", fmt.Sprintf(":%s", f.port))
 	if err != nil {
@@ -86,8 +762,47 @@ func (f *FakeService) Run(t *testing.T) {
 		t.Errorf(fmt.Sprintf("Failed to close the testserver listener: %s", err.Error()))
 		return
 	}
+	if _, port, err := net.SplitHostPort(l.Addr().String()); err == nil {
+		f.port = port
+	}
 	f.testserver.Listener = l
-	f.testserver.Start()
+	if f.cassette != nil {
+		if err := f.cassette.load(f); err != nil {
+			t.Errorf("Failed to load the cassette: %s", err.Error())
+			return
+		}
+	}
+	if len(f.validators) > 0 {
+		f.testserver.Config.Handler = f.validateRequests(f.testserver.Config.Handler)
+	}
+	handler := f.testserver.Config.Handler
+	if f.http2 {
+		f.configureHTTP2()
+	}
+	if f.mitm != nil {
+		if err := f.mitm.start(handler); err != nil {
+			t.Errorf("Failed to start the proxy: %s", err.Error())
+			return
+		}
+		f.testserver.Config.Handler = f.mitm.wrap(f.testserver.Config.Handler)
+	}
+	if f.tls {
+		config, err := f.serverTLSConfig()
+		if err != nil {
+			t.Errorf("Failed to configure TLS: %s", err.Error())
+			return
+		}
+		if f.http3 {
+			if err := f.startHTTP3(f.testserver.Config.Handler, config); err != nil {
+				t.Errorf("Failed to start HTTP/3: %s", err.Error())
+				return
+			}
+		}
+		f.testserver.TLS = config
+		f.testserver.StartTLS()
+	} else {
+		f.testserver.Start()
+	}
 	t.Log("Fake Service Successfully Started")
 
 }
@@ -0,0 +1,51 @@
+package fake
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseBodyIsReplayed(t *testing.T) {
+	f := New()
+	f.AddEndpoint(&Endpoint{
+		Path:         "/report",
+		Method:       http.MethodGet,
+		ResponseBody: strings.NewReader("quarterly figures"),
+	})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	for call := 1; call <= 3; call++ {
+		resp, err := http.Get(f.BaseURL() + "/report")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "quarterly figures" {
+			t.Errorf("call %d got %q, want the whole body", call, body)
+		}
+	}
+}
+
+func TestRunAndTidyUpWithBenchmark(t *testing.T) {
+	var failed, called bool
+	testing.Benchmark(func(b *testing.B) {
+		f := New()
+		f.AddEndpoint(&Endpoint{Path: "/ping", Method: http.MethodGet, Response: "pong"})
+		f.Run(b)
+		resp, err := http.Get(f.BaseURL() + "/ping")
+		if err != nil {
+			b.Fatal(err)
+		}
+		resp.Body.Close()
+		f.TidyUp(b)
+		called = true
+		failed = failed || b.Failed()
+	})
+	if !called || failed {
+		t.Errorf("benchmark using the fake called = %v, failed = %v", called, failed)
+	}
+}
@@ -0,0 +1,223 @@
+// Package fakesmatchers provides Gomega matchers over the traffic
+// recorded by a FakeService, for suites written with Ginkgo, e.g.
+//
+//	Expect(fakeServer).To(HaveReceived(Request(WithPath("/x"), WithJSONBody(`{"a":1}`))))
+package fakesmatchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	fake "github.com/elliotforbes/fakes"
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+)
+
+// RequestMatcher matches a single recorded request.
+type RequestMatcher struct {
+	checks       []func(fake.RecordedRequest) (bool, error)
+	descriptions []string
+}
+
+// RequestOption adds a condition to a RequestMatcher.
+type RequestOption func(*RequestMatcher)
+
+// Request builds a RequestMatcher matching requests that satisfy every
+// option. With no options it matches any request.
+func Request(opts ...RequestOption) *RequestMatcher {
+	m := &RequestMatcher{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *RequestMatcher) add(description string, check func(fake.RecordedRequest) (bool, error)) {
+	m.descriptions = append(m.descriptions, description)
+	m.checks = append(m.checks, check)
+}
+
+func (m *RequestMatcher) String() string {
+	if len(m.descriptions) == 0 {
+		return "any request"
+	}
+	return "a request " + strings.Join(m.descriptions, ", ")
+}
+
+// Matches reports whether r satisfies every condition.
+func (m *RequestMatcher) Matches(r fake.RecordedRequest) (bool, error) {
+	for _, check := range m.checks {
+		ok, err := check(r)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// WithMethod matches requests made with method.
+func WithMethod(method string) RequestOption {
+	return func(m *RequestMatcher) {
+		m.add("with method "+method, func(r fake.RecordedRequest) (bool, error) {
+			return strings.EqualFold(r.Method, method), nil
+		})
+	}
+}
+
+// WithPath matches requests made to exactly path.
+func WithPath(path string) RequestOption {
+	return func(m *RequestMatcher) {
+		m.add("with path "+path, func(r fake.RecordedRequest) (bool, error) {
+			return r.URL.Path == path, nil
+		})
+	}
+}
+
+// WithPathPrefix matches requests whose path starts with prefix.
+func WithPathPrefix(prefix string) RequestOption {
+	return func(m *RequestMatcher) {
+		m.add("with path prefix "+prefix, func(r fake.RecordedRequest) (bool, error) {
+			return strings.HasPrefix(r.URL.Path, prefix), nil
+		})
+	}
+}
+
+// WithHeader matches requests carrying the header with value.
+func WithHeader(name, value string) RequestOption {
+	return func(m *RequestMatcher) {
+		m.add(fmt.Sprintf("with header %s: %s", name, value), func(r fake.RecordedRequest) (bool, error) {
+			return r.Header.Get(name) == value, nil
+		})
+	}
+}
+
+// WithQuery matches requests with the query parameter set to value.
+func WithQuery(name, value string) RequestOption {
+	return func(m *RequestMatcher) {
+		m.add(fmt.Sprintf("with query %s=%s", name, value), func(r fake.RecordedRequest) (bool, error) {
+			return r.URL.Query().Get(name) == value, nil
+		})
+	}
+}
+
+// WithBody matches requests whose body, as a string, satisfies matcher.
+func WithBody(matcher types.GomegaMatcher) RequestOption {
+	return func(m *RequestMatcher) {
+		m.add(fmt.Sprintf("with body matching %v", matcher), func(r fake.RecordedRequest) (bool, error) {
+			return matcher.Match(string(r.Body))
+		})
+	}
+}
+
+// WithJSONBody matches requests whose body is JSON equal to expected,
+// which may be a JSON string or []byte, or any value that marshals to
+// JSON.
+func WithJSONBody(expected any) RequestOption {
+	return func(m *RequestMatcher) {
+		var doc string
+		switch v := expected.(type) {
+		case string:
+			doc = v
+		case []byte:
+			doc = string(v)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				m.add("with JSON body", func(fake.RecordedRequest) (bool, error) {
+					return false, fmt.Errorf("failed to marshal expected JSON body: %w", err)
+				})
+				return
+			}
+			doc = string(b)
+		}
+		matcher := gomega.MatchJSON(doc)
+		m.add("with JSON body "+doc, func(r fake.RecordedRequest) (bool, error) {
+			if len(r.Body) == 0 {
+				return false, nil
+			}
+			// Bodies that aren't JSON simply don't match, rather than
+			// failing the whole search through the journal.
+			ok, err := matcher.Match(string(r.Body))
+			return ok && err == nil, nil
+		})
+	}
+}
+
+// HaveReceived succeeds if any recorded request matches req. The actual
+// value may be a *fake.FakeService, a *fake.Endpoint or a slice of
+// fake.RecordedRequest.
+func HaveReceived(req *RequestMatcher) types.GomegaMatcher {
+	return &receivedMatcher{request: req, times: -1}
+}
+
+// HaveReceivedTimes succeeds if exactly n recorded requests match req.
+func HaveReceivedTimes(n int, req *RequestMatcher) types.GomegaMatcher {
+	return &receivedMatcher{request: req, times: n}
+}
+
+type receivedMatcher struct {
+	request *RequestMatcher
+	// times is the exact number of matches required, or -1 for any.
+	times int
+
+	matched  int
+	received []fake.RecordedRequest
+}
+
+func (m *receivedMatcher) Match(actual any) (bool, error) {
+	switch a := actual.(type) {
+	case *fake.FakeService:
+		m.received = a.Journal()
+	case *fake.Endpoint:
+		m.received = a.Requests()
+	case []fake.RecordedRequest:
+		m.received = a
+	default:
+		return false, fmt.Errorf("HaveReceived expects a *fake.FakeService, *fake.Endpoint or []fake.RecordedRequest, got %T", actual)
+	}
+
+	m.matched = 0
+	for _, r := range m.received {
+		ok, err := m.request.Matches(r)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			m.matched++
+		}
+	}
+	if m.times < 0 {
+		return m.matched > 0, nil
+	}
+	return m.matched == m.times, nil
+}
+
+func (m *receivedMatcher) FailureMessage(any) string {
+	if m.times < 0 {
+		return fmt.Sprintf("Expected to have received %s\n%s", m.request, m.describeReceived())
+	}
+	return fmt.Sprintf("Expected to have received %s %d times but it was received %d times\n%s", m.request, m.times, m.matched, m.describeReceived())
+}
+
+func (m *receivedMatcher) NegatedFailureMessage(any) string {
+	if m.times < 0 {
+		return fmt.Sprintf("Expected not to have received %s but it was received %d times", m.request, m.matched)
+	}
+	return fmt.Sprintf("Expected not to have received %s %d times", m.request, m.times)
+}
+
+func (m *receivedMatcher) describeReceived() string {
+	if len(m.received) == 0 {
+		return "No requests were received"
+	}
+	var b strings.Builder
+	b.WriteString("Received:")
+	for _, r := range m.received {
+		fmt.Fprintf(&b, "\n  %s %s", r.Method, r.URL)
+		if len(r.Body) > 0 {
+			fmt.Fprintf(&b, " %s", r.Body)
+		}
+	}
+	return b.String()
+}
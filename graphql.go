@@ -0,0 +1,209 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQL fakes a GraphQL API, where every operation goes to the same
+// path. Requests are answered by the first of Operations that matches
+// them, and operations without a stub get a GraphQL error response,
+// unless a Schema is given to mock them from.
+type GraphQL struct {
+	// Path defaults to "/graphql".
+	Path       string
+	Operations []*GraphQLOperation
+
+	// Schema, if set, answers operations without a stub with mock data
+	// of the types it declares: 42 for an Int, "Hello World" for a
+	// String, the first value of an enum, two items for a list and so
+	// on.
+	Schema *GraphQLSchema
+	// Mocks overrides the mock data for a field, keyed by "Type.field",
+	// or for every value of a type, keyed by its name. Values may be
+	// func() any to generate a fresh one each time.
+	Mocks map[string]any
+
+	mutex    sync.Mutex
+	requests []GraphQLRequest
+}
+
+// GraphQLOperation stubs the response to a GraphQL operation. Every
+// field that is set must match for the stub to be used.
+type GraphQLOperation struct {
+	// OperationName is matched against the request's operationName,
+	// or the name given in the query if the client didn't send one.
+	OperationName string
+	// QueryContains must appear somewhere in the query.
+	QueryContains string
+	// Variables must all be sent with equal values, though the request
+	// may send others too.
+	Variables map[string]any
+
+	// Data is returned as the response's data, and Errors as its
+	// errors, so partial results can be returned by setting both.
+	Data   any
+	Errors []GraphQLError
+	// StatusCode defaults to 200, as GraphQL errors are usually
+	// reported alongside a successful status.
+	StatusCode int
+}
+
+// GraphQLError is an entry in a GraphQL response's errors.
+type GraphQLError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLRequest is an operation received by a GraphQL fake.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// AddGraphQL registers the endpoint serving g, accepting operations
+// POSTed as JSON or sent as query parameters of a GET.
+func (f *FakeService) AddGraphQL(g *GraphQL) {
+	if g.Path == "" {
+		g.Path = "/graphql"
+	}
+	f.AddEndpoint(&Endpoint{
+		Path:    g.Path,
+		Method:  http.MethodPost,
+		Handler: g.serve,
+	})
+	f.AddEndpoint(&Endpoint{
+		Path:     g.Path,
+		Method:   http.MethodGet,
+		Handler:  g.serve,
+		Optional: true,
+	})
+}
+
+// Requests returns every operation received, oldest first.
+func (g *GraphQL) Requests() []GraphQLRequest {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]GraphQLRequest(nil), g.requests...)
+}
+
+// CallCount returns how many times the named operation was received.
+func (g *GraphQL) CallCount(operationName string) int {
+	count := 0
+	for _, r := range g.Requests() {
+		if r.OperationName == operationName {
+			count++
+		}
+	}
+	return count
+}
+
+func (g *GraphQL) serve(c *gin.Context) {
+	req, err := readGraphQLRequest(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, graphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}})
+		return
+	}
+
+	g.mutex.Lock()
+	g.requests = append(g.requests, req)
+	g.mutex.Unlock()
+
+	op := g.match(req)
+	if op == nil && g.Schema != nil {
+		c.JSON(http.StatusOK, mockGraphQL(g.Schema, g.Mocks, req))
+		return
+	}
+	if op == nil {
+		c.JSON(http.StatusOK, graphQLResponse{Errors: []GraphQLError{{
+			Message: fmt.Sprintf("no stub for GraphQL operation %q", req.OperationName),
+		}}})
+		return
+	}
+	status := op.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c.JSON(status, graphQLResponse{Data: op.Data, Errors: op.Errors})
+}
+
+func (g *GraphQL) match(req GraphQLRequest) *GraphQLOperation {
+	for _, op := range g.Operations {
+		if op.matches(req) {
+			return op
+		}
+	}
+	return nil
+}
+
+func (op *GraphQLOperation) matches(req GraphQLRequest) bool {
+	if op.OperationName != "" && op.OperationName != req.OperationName {
+		return false
+	}
+	if op.QueryContains != "" && !strings.Contains(req.Query, op.QueryContains) {
+		return false
+	}
+	if len(op.Variables) == 0 {
+		return true
+	}
+	// Round trip the stub's variables through JSON so that, for
+	// instance, ints compare equal to the float64s decoded from the
+	// request.
+	data, err := json.Marshal(op.Variables)
+	if err != nil {
+		return false
+	}
+	var expected map[string]any
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return false
+	}
+	for name, value := range expected {
+		if !reflect.DeepEqual(req.Variables[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// operationNamePattern finds the name of the first named operation in
+// a query.
+var operationNamePattern = regexp.MustCompile(`\b(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+func readGraphQLRequest(r *http.Request) (GraphQLRequest, error) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if vars := q.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				return req, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid GraphQL request: %w", err)
+	}
+	if req.Query == "" {
+		return req, fmt.Errorf("GraphQL request has no query")
+	}
+	if req.OperationName == "" {
+		if m := operationNamePattern.FindStringSubmatch(req.Query); m != nil {
+			req.OperationName = m[1]
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,755 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GraphQLSchema is a GraphQL schema parsed from SDL, used to answer
+// operations that have no stub with mock data of the right shape.
+type GraphQLSchema struct {
+	query        string
+	mutation     string
+	subscription string
+	types        map[string]*graphQLType
+	// order holds the type names in the order they were defined, so
+	// interfaces and unions are mocked by the same type every time.
+	order []string
+}
+
+type graphQLType struct {
+	kind       string
+	name       string
+	fields     map[string]*graphQLTypeRef
+	interfaces []string
+	members    []string
+	values     []string
+}
+
+// graphQLTypeRef is a field's type: a named type, or a list of elem.
+// Mock data is never null, so whether a type is non-null is ignored.
+type graphQLTypeRef struct {
+	name string
+	elem *graphQLTypeRef
+}
+
+// LoadGraphQLSchema reads a GraphQL schema from an SDL file.
+func LoadGraphQLSchema(path string) (*GraphQLSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GraphQL schema: %w", err)
+	}
+	return ParseGraphQLSchema(string(data))
+}
+
+// ParseGraphQLSchema parses a GraphQL schema written in SDL. Type,
+// interface, union, enum, scalar and input definitions are understood,
+// along with extensions of them, and directives are ignored.
+func ParseGraphQLSchema(sdl string) (schema *GraphQLSchema, err error) {
+	p, err := newGraphQLParser(sdl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GraphQL schema: %w", err)
+	}
+	defer p.recover(&err, "failed to parse GraphQL schema")
+
+	schema = &GraphQLSchema{types: map[string]*graphQLType{}}
+	for !p.at(graphQLEOF, "") {
+		p.definition(schema)
+	}
+	if schema.query == "" {
+		schema.query = "Query"
+	}
+	if schema.mutation == "" {
+		schema.mutation = "Mutation"
+	}
+	if schema.subscription == "" {
+		schema.subscription = "Subscription"
+	}
+	return schema, nil
+}
+
+func (schema *GraphQLSchema) typeNamed(kind, name string) *graphQLType {
+	t, ok := schema.types[name]
+	if !ok {
+		t = &graphQLType{kind: kind, name: name, fields: map[string]*graphQLTypeRef{}}
+		schema.types[name] = t
+		schema.order = append(schema.order, name)
+	}
+	return t
+}
+
+// implements reports whether the object type named typeName satisfies
+// a type condition, by being it, implementing it or belonging to it.
+func (schema *GraphQLSchema) implements(typeName, condition string) bool {
+	if condition == "" || condition == typeName {
+		return true
+	}
+	t, ok := schema.types[condition]
+	if !ok {
+		return false
+	}
+	for _, member := range t.members {
+		if member == typeName {
+			return true
+		}
+	}
+	if object, ok := schema.types[typeName]; ok {
+		for _, name := range object.interfaces {
+			if name == condition {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// concreteType returns the object type mocked in place of an interface
+// or union, the first defined that belongs to it.
+func (schema *GraphQLSchema) concreteType(t *graphQLType) string {
+	if t.kind == "union" && len(t.members) > 0 {
+		return t.members[0]
+	}
+	for _, name := range schema.order {
+		if other := schema.types[name]; other.kind == "type" && schema.implements(name, t.name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// graphQLDefinitionKeywords start a definition in SDL.
+var graphQLDefinitionKeywords = map[string]bool{
+	"schema": true, "extend": true, "type": true, "interface": true, "union": true,
+	"enum": true, "scalar": true, "input": true, "directive": true,
+}
+
+func (p *graphQLParser) definition(schema *GraphQLSchema) {
+	p.description()
+	keyword := p.name()
+	if keyword == "extend" {
+		keyword = p.name()
+	}
+	switch keyword {
+	case "schema":
+		p.directives()
+		p.expect("{")
+		for !p.accept("}") {
+			op := p.name()
+			p.expect(":")
+			name := p.name()
+			switch op {
+			case "query":
+				schema.query = name
+			case "mutation":
+				schema.mutation = name
+			case "subscription":
+				schema.subscription = name
+			}
+		}
+	case "type", "interface", "input":
+		kind := keyword
+		t := schema.typeNamed(kind, p.name())
+		if p.acceptName("implements") {
+			for p.accept("&") || (p.at(graphQLName, "") && !graphQLDefinitionKeywords[p.peek().value]) {
+				if p.at(graphQLName, "") {
+					t.interfaces = append(t.interfaces, p.name())
+				}
+			}
+		}
+		p.directives()
+		if p.accept("{") {
+			for !p.accept("}") {
+				p.description()
+				field := p.name()
+				if p.at(graphQLPunctuator, "(") {
+					p.skipBalanced("(", ")")
+				}
+				p.expect(":")
+				t.fields[field] = p.typeRef()
+				if p.accept("=") {
+					p.skipValue()
+				}
+				p.directives()
+			}
+		}
+	case "enum":
+		t := schema.typeNamed("enum", p.name())
+		p.directives()
+		if p.accept("{") {
+			for !p.accept("}") {
+				p.description()
+				t.values = append(t.values, p.name())
+				p.directives()
+			}
+		}
+	case "union":
+		t := schema.typeNamed("union", p.name())
+		p.directives()
+		if p.accept("=") {
+			p.accept("|")
+			t.members = append(t.members, p.name())
+			for p.accept("|") {
+				t.members = append(t.members, p.name())
+			}
+		}
+	case "scalar":
+		schema.typeNamed("scalar", p.name())
+		p.directives()
+	case "directive":
+		p.expect("@")
+		p.name()
+		if p.at(graphQLPunctuator, "(") {
+			p.skipBalanced("(", ")")
+		}
+		p.acceptName("repeatable")
+		p.expectName("on")
+		p.accept("|")
+		p.name()
+		for p.accept("|") {
+			p.name()
+		}
+	default:
+		p.fail("unexpected %q", keyword)
+	}
+}
+
+func (p *graphQLParser) typeRef() *graphQLTypeRef {
+	var ref *graphQLTypeRef
+	if p.accept("[") {
+		ref = &graphQLTypeRef{elem: p.typeRef()}
+		p.expect("]")
+	} else {
+		ref = &graphQLTypeRef{name: p.name()}
+	}
+	p.accept("!")
+	return ref
+}
+
+// graphQLDocument is a parsed GraphQL query document.
+type graphQLDocument struct {
+	operations []graphQLOperationDefinition
+	fragments  map[string]graphQLFragment
+}
+
+type graphQLOperationDefinition struct {
+	kind       string
+	name       string
+	selections []graphQLSelection
+}
+
+type graphQLFragment struct {
+	on         string
+	selections []graphQLSelection
+}
+
+// graphQLSelection is a field, or a fragment spread naming fragment, or
+// an inline fragment when inline is set, applying if on is satisfied.
+type graphQLSelection struct {
+	alias      string
+	name       string
+	fragment   string
+	inline     bool
+	on         string
+	selections []graphQLSelection
+}
+
+func (s graphQLSelection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+func parseGraphQLDocument(query string) (doc *graphQLDocument, err error) {
+	p, err := newGraphQLParser(query)
+	if err != nil {
+		return nil, err
+	}
+	defer p.recover(&err, "invalid query")
+
+	doc = &graphQLDocument{fragments: map[string]graphQLFragment{}}
+	for !p.at(graphQLEOF, "") {
+		if p.at(graphQLPunctuator, "{") {
+			doc.operations = append(doc.operations, graphQLOperationDefinition{kind: "query", selections: p.selectionSet()})
+			continue
+		}
+		switch keyword := p.name(); keyword {
+		case "query", "mutation", "subscription":
+			op := graphQLOperationDefinition{kind: keyword}
+			if p.at(graphQLName, "") {
+				op.name = p.name()
+			}
+			if p.at(graphQLPunctuator, "(") {
+				p.skipBalanced("(", ")")
+			}
+			p.directives()
+			op.selections = p.selectionSet()
+			doc.operations = append(doc.operations, op)
+		case "fragment":
+			name := p.name()
+			p.expectName("on")
+			fragment := graphQLFragment{on: p.name()}
+			p.directives()
+			fragment.selections = p.selectionSet()
+			doc.fragments[name] = fragment
+		default:
+			p.fail("unexpected %q", keyword)
+		}
+	}
+	return doc, nil
+}
+
+func (p *graphQLParser) selectionSet() []graphQLSelection {
+	var selections []graphQLSelection
+	p.expect("{")
+	for !p.accept("}") {
+		if p.accept("...") {
+			if p.at(graphQLName, "") && p.peek().value != "on" {
+				selections = append(selections, graphQLSelection{fragment: p.name()})
+				p.directives()
+				continue
+			}
+			inline := graphQLSelection{inline: true}
+			if p.acceptName("on") {
+				inline.on = p.name()
+			}
+			p.directives()
+			inline.selections = p.selectionSet()
+			selections = append(selections, inline)
+			continue
+		}
+		field := graphQLSelection{name: p.name()}
+		if p.accept(":") {
+			field.alias, field.name = field.name, p.name()
+		}
+		if p.at(graphQLPunctuator, "(") {
+			p.skipBalanced("(", ")")
+		}
+		p.directives()
+		if p.at(graphQLPunctuator, "{") {
+			field.selections = p.selectionSet()
+		}
+		selections = append(selections, field)
+	}
+	return selections
+}
+
+// graphQLMocker builds mock data for an operation from a schema.
+type graphQLMocker struct {
+	schema *GraphQLSchema
+	doc    *graphQLDocument
+	mocks  map[string]any
+	ids    int
+	errors []GraphQLError
+}
+
+// mockGraphQL answers a request with mock data generated from schema,
+// overridden by mocks.
+func mockGraphQL(schema *GraphQLSchema, mocks map[string]any, req GraphQLRequest) graphQLResponse {
+	doc, err := parseGraphQLDocument(req.Query)
+	if err != nil {
+		return graphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+	}
+	var op *graphQLOperationDefinition
+	for i := range doc.operations {
+		if req.OperationName == "" || doc.operations[i].name == req.OperationName {
+			op = &doc.operations[i]
+			break
+		}
+	}
+	if op == nil {
+		return graphQLResponse{Errors: []GraphQLError{{Message: fmt.Sprintf("unknown operation %q", req.OperationName)}}}
+	}
+
+	root := map[string]string{"query": schema.query, "mutation": schema.mutation, "subscription": schema.subscription}[op.kind]
+	if _, ok := schema.types[root]; !ok {
+		return graphQLResponse{Errors: []GraphQLError{{Message: fmt.Sprintf("schema does not support %s operations", op.kind)}}}
+	}
+	m := &graphQLMocker{schema: schema, doc: doc, mocks: mocks}
+	data := m.object(root, op.selections, nil)
+	return graphQLResponse{Data: data, Errors: m.errors}
+}
+
+// object mocks an object of the named type with the selected fields.
+func (m *graphQLMocker) object(typeName string, selections []graphQLSelection, path []any) *graphQLObject {
+	t := m.schema.types[typeName]
+	object := &graphQLObject{values: map[string]any{}}
+	for _, field := range m.collectFields(typeName, selections) {
+		key := field.responseKey()
+		fieldPath := append(append([]any(nil), path...), key)
+		if field.name == "__typename" {
+			object.set(key, typeName)
+			continue
+		}
+		ref, ok := t.fields[field.name]
+		if !ok {
+			m.errors = append(m.errors, GraphQLError{
+				Message: fmt.Sprintf("Cannot query field %q on type %q.", field.name, typeName),
+				Path:    fieldPath,
+			})
+			continue
+		}
+		if mock, ok := m.mock(typeName + "." + field.name); ok {
+			object.set(key, mock)
+			continue
+		}
+		object.set(key, m.value(ref, field.selections, fieldPath))
+	}
+	return object
+}
+
+// collectFields flattens the selections that apply to the named type,
+// merging the selections of fields selected more than once.
+func (m *graphQLMocker) collectFields(typeName string, selections []graphQLSelection) []graphQLSelection {
+	var fields []graphQLSelection
+	index := map[string]int{}
+	var collect func([]graphQLSelection, map[string]bool)
+	collect = func(selections []graphQLSelection, visited map[string]bool) {
+		for _, s := range selections {
+			switch {
+			case s.fragment != "":
+				fragment, ok := m.doc.fragments[s.fragment]
+				if ok && !visited[s.fragment] && m.schema.implements(typeName, fragment.on) {
+					visited[s.fragment] = true
+					collect(fragment.selections, visited)
+				}
+			case s.inline:
+				if m.schema.implements(typeName, s.on) {
+					collect(s.selections, visited)
+				}
+			default:
+				if i, ok := index[s.responseKey()]; ok {
+					fields[i].selections = append(fields[i].selections, s.selections...)
+					continue
+				}
+				index[s.responseKey()] = len(fields)
+				fields = append(fields, s)
+			}
+		}
+	}
+	collect(selections, map[string]bool{})
+	return fields
+}
+
+// graphQLListLength is how many items mocked lists hold.
+const graphQLListLength = 2
+
+func (m *graphQLMocker) value(ref *graphQLTypeRef, selections []graphQLSelection, path []any) any {
+	if ref.elem != nil {
+		items := make([]any, graphQLListLength)
+		for i := range items {
+			items[i] = m.value(ref.elem, selections, append(append([]any(nil), path...), i))
+		}
+		return items
+	}
+	if mock, ok := m.mock(ref.name); ok {
+		return mock
+	}
+	switch ref.name {
+	case "Int":
+		return 42
+	case "Float":
+		return 4.2
+	case "String":
+		return "Hello World"
+	case "Boolean":
+		return true
+	case "ID":
+		m.ids++
+		return strconv.Itoa(m.ids)
+	}
+	t, ok := m.schema.types[ref.name]
+	if !ok {
+		return nil
+	}
+	switch t.kind {
+	case "scalar":
+		return t.name
+	case "enum":
+		if len(t.values) > 0 {
+			return t.values[0]
+		}
+	case "type":
+		return m.object(t.name, selections, path)
+	case "interface", "union":
+		if concrete := m.schema.concreteType(t); concrete != "" {
+			return m.object(concrete, selections, path)
+		}
+	}
+	return nil
+}
+
+// mock returns the override for a field ("Type.field") or type, calling
+// it if it is a function.
+func (m *graphQLMocker) mock(key string) (any, bool) {
+	mock, ok := m.mocks[key]
+	if !ok {
+		return nil, false
+	}
+	if fn, ok := mock.(func() any); ok {
+		return fn(), true
+	}
+	return mock, true
+}
+
+// graphQLObject is a response object, which keeps its fields in the
+// order they were selected as GraphQL requires.
+type graphQLObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *graphQLObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *graphQLObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type graphQLTokenKind int
+
+const (
+	graphQLEOF graphQLTokenKind = iota
+	graphQLName
+	graphQLPunctuator
+	graphQLString
+	graphQLNumber
+)
+
+type graphQLToken struct {
+	kind  graphQLTokenKind
+	value string
+}
+
+// graphQLParser parses GraphQL SDL and query documents. Errors panic
+// with a graphQLError, which recover turns back into an error.
+type graphQLParser struct {
+	tokens []graphQLToken
+	pos    int
+}
+
+type graphQLError struct{ err error }
+
+func newGraphQLParser(source string) (*graphQLParser, error) {
+	tokens, err := lexGraphQL(source)
+	if err != nil {
+		return nil, err
+	}
+	return &graphQLParser{tokens: tokens}, nil
+}
+
+func (p *graphQLParser) recover(err *error, context string) {
+	if r := recover(); r != nil {
+		e, ok := r.(graphQLError)
+		if !ok {
+			panic(r)
+		}
+		*err = fmt.Errorf("%s: %w", context, e.err)
+	}
+}
+
+func (p *graphQLParser) fail(format string, args ...any) {
+	panic(graphQLError{fmt.Errorf(format, args...)})
+}
+
+func (p *graphQLParser) peek() graphQLToken {
+	return p.tokens[p.pos]
+}
+
+func (p *graphQLParser) next() graphQLToken {
+	token := p.tokens[p.pos]
+	if token.kind != graphQLEOF {
+		p.pos++
+	}
+	return token
+}
+
+// at reports whether the next token is of the given kind and, unless
+// value is empty, has that value.
+func (p *graphQLParser) at(kind graphQLTokenKind, value string) bool {
+	token := p.peek()
+	return token.kind == kind && (value == "" || token.value == value)
+}
+
+func (p *graphQLParser) accept(punctuator string) bool {
+	if p.at(graphQLPunctuator, punctuator) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *graphQLParser) acceptName(name string) bool {
+	if p.at(graphQLName, name) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *graphQLParser) expect(punctuator string) {
+	if !p.accept(punctuator) {
+		p.fail("expected %q, found %q", punctuator, p.peek().value)
+	}
+}
+
+func (p *graphQLParser) expectName(name string) {
+	if !p.acceptName(name) {
+		p.fail("expected %q, found %q", name, p.peek().value)
+	}
+}
+
+func (p *graphQLParser) name() string {
+	if !p.at(graphQLName, "") {
+		p.fail("expected a name, found %q", p.peek().value)
+	}
+	return p.next().value
+}
+
+// description skips a description string, if there is one.
+func (p *graphQLParser) description() {
+	if p.at(graphQLString, "") {
+		p.next()
+	}
+}
+
+// directives skips any directives.
+func (p *graphQLParser) directives() {
+	for p.accept("@") {
+		p.name()
+		if p.at(graphQLPunctuator, "(") {
+			p.skipBalanced("(", ")")
+		}
+	}
+}
+
+// skipBalanced skips from an open punctuator to its matching close.
+func (p *graphQLParser) skipBalanced(open, close string) {
+	p.expect(open)
+	for depth := 1; depth > 0; {
+		token := p.next()
+		switch {
+		case token.kind == graphQLEOF:
+			p.fail("expected %q", close)
+		case token.kind == graphQLPunctuator && token.value == open:
+			depth++
+		case token.kind == graphQLPunctuator && token.value == close:
+			depth--
+		}
+	}
+}
+
+// skipValue skips a literal or variable.
+func (p *graphQLParser) skipValue() {
+	switch {
+	case p.accept("$"):
+		p.name()
+	case p.at(graphQLPunctuator, "["):
+		p.skipBalanced("[", "]")
+	case p.at(graphQLPunctuator, "{"):
+		p.skipBalanced("{", "}")
+	case p.at(graphQLName, ""), p.at(graphQLString, ""), p.at(graphQLNumber, ""):
+		p.next()
+	default:
+		p.fail("expected a value, found %q", p.peek().value)
+	}
+}
+
+func lexGraphQL(source string) ([]graphQLToken, error) {
+	var tokens []graphQLToken
+	for i := 0; i < len(source); {
+		ch := source[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+		case strings.HasPrefix(source[i:], "\ufeff"):
+			i += len("\ufeff")
+		case ch == '#':
+			for i < len(source) && source[i] != '\n' && source[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "..."):
+			tokens = append(tokens, graphQLToken{graphQLPunctuator, "..."})
+			i += 3
+		case strings.ContainsRune("!$&()/:=@[]{|}", rune(ch)):
+			tokens = append(tokens, graphQLToken{graphQLPunctuator, string(ch)})
+			i++
+		case strings.HasPrefix(source[i:], `"""`):
+			end := i + 3
+			for end < len(source) && !strings.HasPrefix(source[end:], `"""`) {
+				if strings.HasPrefix(source[end:], `\"""`) {
+					end += 4
+					continue
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, fmt.Errorf("unterminated block string")
+			}
+			tokens = append(tokens, graphQLToken{graphQLString, source[i+3 : end]})
+			i = end + 3
+		case ch == '"':
+			end := i + 1
+			for end < len(source) && source[end] != '"' && source[end] != '\n' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) || source[end] != '"' {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, graphQLToken{graphQLString, source[i+1 : end]})
+			i = end + 1
+		case ch == '-' || isDigit(ch):
+			end := i + 1
+			for end < len(source) && (isDigit(source[end]) || strings.ContainsRune(".eE+-", rune(source[end]))) {
+				end++
+			}
+			tokens = append(tokens, graphQLToken{graphQLNumber, source[i:end]})
+			i = end
+		case ch == '_' || isLetter(ch):
+			end := i + 1
+			for end < len(source) && (source[end] == '_' || isLetter(source[end]) || isDigit(source[end])) {
+				end++
+			}
+			tokens = append(tokens, graphQLToken{graphQLName, source[i:end]})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected character %q", ch)
+		}
+	}
+	return append(tokens, graphQLToken{kind: graphQLEOF}), nil
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -0,0 +1,148 @@
+package fake
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLexGraphQL(t *testing.T) {
+	name := func(v string) graphQLToken { return graphQLToken{graphQLName, v} }
+	punct := func(v string) graphQLToken { return graphQLToken{graphQLPunctuator, v} }
+	str := func(v string) graphQLToken { return graphQLToken{graphQLString, v} }
+	num := func(v string) graphQLToken { return graphQLToken{graphQLNumber, v} }
+	eof := graphQLToken{kind: graphQLEOF}
+
+	tests := []struct {
+		source  string
+		want    []graphQLToken
+		wantErr bool
+	}{
+		{source: "", want: []graphQLToken{eof}},
+		{source: "type Query { pet(id: ID!): Pet }", want: []graphQLToken{
+			name("type"), name("Query"), punct("{"), name("pet"), punct("("), name("id"), punct(":"),
+			name("ID"), punct("!"), punct(")"), punct(":"), name("Pet"), punct("}"), eof,
+		}},
+		{source: "a, b # a comment\n c", want: []graphQLToken{name("a"), name("b"), name("c"), eof}},
+		{source: "\ufeff...on", want: []graphQLToken{punct("..."), name("on"), eof}},
+		{source: `"a \"quoted\" string"`, want: []graphQLToken{str(`a \"quoted\" string`), eof}},
+		{source: `"""block "quotes" \""" and
+lines"""`, want: []graphQLToken{str(`block "quotes" \""" and
+lines`), eof}},
+		{source: "-1 2.5e10 _private9", want: []graphQLToken{num("-1"), num("2.5e10"), name("_private9"), eof}},
+		{source: "$var @skip [1]", want: []graphQLToken{punct("$"), name("var"), punct("@"), name("skip"), punct("["), num("1"), punct("]"), eof}},
+		{source: `"unterminated`, wantErr: true},
+		{source: "\"broken\nstring\"", wantErr: true},
+		{source: `"""unterminated block`, wantErr: true},
+		{source: "a % b", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := lexGraphQL(tt.source)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("lexGraphQL(%q) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("lexGraphQL(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestParseGraphQLSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		sdl     string
+		check   func(*GraphQLSchema) bool
+		wantErr bool
+	}{
+		{
+			name: "root types default",
+			sdl:  "type Query { ok: Boolean }",
+			check: func(s *GraphQLSchema) bool {
+				return s.query == "Query" && s.mutation == "Mutation" && s.types["Query"].fields["ok"].name == "Boolean"
+			},
+		},
+		{
+			name: "schema definition names root types",
+			sdl:  "schema { query: Root mutation: Changes } type Root { ok: Boolean }",
+			check: func(s *GraphQLSchema) bool {
+				return s.query == "Root" && s.mutation == "Changes"
+			},
+		},
+		{
+			name: "lists and non-null",
+			sdl:  `type Query { "the pets" pets(first: Int = 10): [Pet!]! @deprecated(reason: "no") } type Pet { name: String }`,
+			check: func(s *GraphQLSchema) bool {
+				ref := s.types["Query"].fields["pets"]
+				return ref.elem != nil && ref.elem.name == "Pet"
+			},
+		},
+		{
+			name: "interfaces, unions and enums",
+			sdl: `interface Node { id: ID! }
+type Dog implements Node & Named { id: ID! name: String }
+interface Named { name: String }
+union Pet = | Dog | Cat
+type Cat { id: ID! }
+enum Size { SMALL LARGE }`,
+			check: func(s *GraphQLSchema) bool {
+				return slices.Equal(s.types["Dog"].interfaces, []string{"Node", "Named"}) &&
+					slices.Equal(s.types["Pet"].members, []string{"Dog", "Cat"}) &&
+					slices.Equal(s.types["Size"].values, []string{"SMALL", "LARGE"}) &&
+					s.concreteType(s.types["Node"]) == "Dog" &&
+					s.implements("Cat", "Pet")
+			},
+		},
+		{
+			name: "extensions add fields",
+			sdl:  "type Query { a: Int } extend type Query { b: Int }",
+			check: func(s *GraphQLSchema) bool {
+				return len(s.types["Query"].fields) == 2
+			},
+		},
+		{name: "missing brace", sdl: "type Query { a: Int", wantErr: true},
+		{name: "missing field type", sdl: "type Query { a }", wantErr: true},
+		{name: "unknown definition", sdl: "query { a }", wantErr: true},
+	}
+	for _, tt := range tests {
+		schema, err := ParseGraphQLSchema(tt.sdl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !tt.check(schema) {
+			t.Errorf("%s: schema parsed wrongly: %+v", tt.name, schema)
+		}
+	}
+}
+
+func TestParseGraphQLDocument(t *testing.T) {
+	tests := []struct {
+		query   string
+		keys    []string
+		wantErr bool
+	}{
+		{query: "{ pet { name } }", keys: []string{"pet"}},
+		{query: `query Pets($first: Int = 1) { all: pets(first: $first) { name } owner }`, keys: []string{"all", "owner"}},
+		{query: "{ pet { ...Fields ... on Dog { barks } } } fragment Fields on Pet { name }", keys: []string{"pet"}},
+		{query: "{ pet { name }", wantErr: true},
+		{query: "{ alias: }", wantErr: true},
+		{query: "fragment Fields { name }", wantErr: true},
+	}
+	for _, tt := range tests {
+		doc, err := parseGraphQLDocument(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGraphQLDocument(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		var keys []string
+		for _, s := range doc.operations[0].selections {
+			keys = append(keys, s.responseKey())
+		}
+		if !slices.Equal(keys, tt.keys) {
+			t.Errorf("parseGraphQLDocument(%q) selects %v, want %v", tt.query, keys, tt.keys)
+		}
+	}
+}
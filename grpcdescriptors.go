@@ -0,0 +1,91 @@
+package fake
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Descriptors holds the protobuf services and messages described by a
+// compiled FileDescriptorSet, such as one written by
+// `protoc --include_imports --descriptor_set_out`, so they can be
+// described to gRPC clients and gRPC stubs written as JSON without
+// generated Go code.
+type Descriptors struct {
+	files *protoregistry.Files
+}
+
+// LoadDescriptorSet reads a compiled FileDescriptorSet.
+func LoadDescriptorSet(path string) (*Descriptors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+	return ParseDescriptorSet(data)
+}
+
+// ParseDescriptorSet parses a serialized FileDescriptorSet.
+func ParseDescriptorSet(data []byte) (*Descriptors, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load descriptor set: %w", err)
+	}
+	return &Descriptors{files: files}, nil
+}
+
+// method finds a method by its full name, e.g. "/pkg.Service/Method".
+func (d *Descriptors) method(name string) (protoreflect.MethodDescriptor, error) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("method name %q is not of the form /pkg.Service/Method", name)
+	}
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %s is not in the descriptor set", service)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("service %s has no method %s", service, method)
+	}
+	return md, nil
+}
+
+// jsonToMessage transcodes a message from protobuf's JSON mapping to
+// its binary encoding.
+func jsonToMessage(md protoreflect.MessageDescriptor, json string) ([]byte, error) {
+	message := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal([]byte(json), message); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", md.FullName(), err)
+	}
+	return proto.Marshal(message)
+}
+
+// messageToJSON transcodes a message from its binary encoding to
+// protobuf's JSON mapping.
+func messageToJSON(md protoreflect.MessageDescriptor, data []byte) (string, error) {
+	message := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(data, message); err != nil {
+		return "", fmt.Errorf("invalid %s: %w", md.FullName(), err)
+	}
+	json, err := protojson.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(json), nil
+}
@@ -0,0 +1,320 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// grpcReflectionMethods are the streaming methods of both versions of
+// the gRPC server reflection API, as clients still use either.
+var grpcReflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// gRPC status codes used by the reflection API.
+const (
+	grpcInvalidArgument = 3
+	grpcNotFound        = 5
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+)
+
+// AddGRPCReflection serves the gRPC server reflection API from d, so
+// grpcurl and other reflection clients can list and describe the
+// services it holds. Like any native gRPC call it needs WithHTTP2.
+// Reflection calls are recorded like any other, without their bodies,
+// and the endpoints are Optional as clients only reflect when asked
+// to. It returns an error if d is nil or something is already
+// registered at the reflection paths.
+func (f *FakeService) AddGRPCReflection(d *Descriptors) error {
+	if d == nil {
+		return fmt.Errorf("gRPC reflection needs Descriptors")
+	}
+	f.mutex.RLock()
+	for _, path := range grpcReflectionMethods {
+		if len(f.routes[path]) > 0 {
+			f.mutex.RUnlock()
+			return fmt.Errorf("an endpoint is already registered at %s", path)
+		}
+	}
+	f.mutex.RUnlock()
+	for _, path := range grpcReflectionMethods {
+		f.AddEndpoint(&Endpoint{
+			Path:     path,
+			Method:   http.MethodPost,
+			Handler:  d.serveReflection,
+			Optional: true,
+			stream:   true,
+		})
+	}
+	return nil
+}
+
+// serveReflection answers a server reflection stream, one response per
+// request as each arrives, until the client closes its side.
+func (d *Descriptors) serveReflection(c *gin.Context) {
+	if contentType := c.ContentType(); contentType != "application/grpc" && !strings.HasPrefix(contentType, "application/grpc+") {
+		c.String(http.StatusUnsupportedMediaType, "unsupported content type %q", contentType)
+		return
+	}
+	c.Header("Content-Type", "application/grpc")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	for {
+		request, err := readGRPCFrame(c.Request.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			setGRPCStatus(c, grpcInternal, err.Error())
+			return
+		}
+		var out bytes.Buffer
+		writeGRPCFrame(&out, d.reflect(request))
+		c.Writer.Write(out.Bytes())
+		c.Writer.Flush()
+	}
+	setGRPCStatus(c, 0, "")
+}
+
+// setGRPCStatus sends a native gRPC call's status as HTTP/2 trailers,
+// once its messages have been written.
+func setGRPCStatus(c *gin.Context, code int, message string) {
+	c.Writer.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		c.Writer.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(message))
+	}
+}
+
+// readGRPCFrame reads the next uncompressed message from a gRPC stream,
+// returning io.EOF once the stream ends between messages.
+func readGRPCFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated frame header")
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, fmt.Errorf("compressed messages aren't supported")
+	}
+	message := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("truncated frame")
+	}
+	return message, nil
+}
+
+// writeGRPCFrame writes an uncompressed message to a gRPC stream.
+func writeGRPCFrame(w io.Writer, message []byte) {
+	var header [5]byte
+	binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+	w.Write(header[:])
+	w.Write(message)
+}
+
+// reflect answers a serialized ServerReflectionRequest with a
+// serialized ServerReflectionResponse.
+func (d *Descriptors) reflect(request []byte) []byte {
+	var host string
+	var response []byte
+	for b := request; len(b) > 0; {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			response = reflectionError(grpcInvalidArgument, "malformed reflection request")
+			break
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				response = reflectionError(grpcInvalidArgument, "malformed reflection request")
+				break
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			response = reflectionError(grpcInvalidArgument, "malformed reflection request")
+			break
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			host = string(v)
+		case 3:
+			response = d.fileByFilename(string(v))
+		case 4:
+			response = d.fileContainingSymbol(string(v))
+		case 5:
+			response = d.fileContainingExtension(v)
+		case 6:
+			response = d.allExtensionNumbers(string(v))
+		case 7:
+			response = d.listServices()
+		}
+	}
+	if response == nil {
+		response = reflectionError(grpcUnimplemented, "unsupported reflection request")
+	}
+
+	out := protowire.AppendTag(nil, 1, protowire.BytesType)
+	out = protowire.AppendString(out, host)
+	out = append(out, reflectionField(2, request)...)
+	return append(out, response...)
+}
+
+func (d *Descriptors) fileByFilename(name string) []byte {
+	fd, err := d.files.FindFileByPath(name)
+	if err != nil {
+		return reflectionError(grpcNotFound, "file %s is not in the descriptor set", name)
+	}
+	return fileDescriptorResponse(fd)
+}
+
+func (d *Descriptors) fileContainingSymbol(symbol string) []byte {
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(symbol))
+	if err != nil {
+		return reflectionError(grpcNotFound, "symbol %s is not in the descriptor set", symbol)
+	}
+	return fileDescriptorResponse(desc.ParentFile())
+}
+
+func (d *Descriptors) fileContainingExtension(request []byte) []byte {
+	var message string
+	var number int32
+	for b := request; len(b) > 0; {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return reflectionError(grpcInvalidArgument, "malformed extension request")
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(b)
+			message = v
+		case num == 2 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			number = int32(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return reflectionError(grpcInvalidArgument, "malformed extension request")
+		}
+		b = b[n:]
+	}
+	for _, xd := range d.extensionsOf(protoreflect.FullName(message)) {
+		if int32(xd.Number()) == number {
+			return fileDescriptorResponse(xd.ParentFile())
+		}
+	}
+	return reflectionError(grpcNotFound, "extension %d of %s is not in the descriptor set", number, message)
+}
+
+func (d *Descriptors) allExtensionNumbers(message string) []byte {
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(message))
+	if _, ok := desc.(protoreflect.MessageDescriptor); err != nil || !ok {
+		return reflectionError(grpcNotFound, "message %s is not in the descriptor set", message)
+	}
+	out := protowire.AppendTag(nil, 1, protowire.BytesType)
+	out = protowire.AppendString(out, message)
+	for _, xd := range d.extensionsOf(protoreflect.FullName(message)) {
+		out = protowire.AppendTag(out, 2, protowire.VarintType)
+		out = protowire.AppendVarint(out, uint64(xd.Number()))
+	}
+	return reflectionField(5, out)
+}
+
+func (d *Descriptors) listServices() []byte {
+	var names []string
+	d.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			names = append(names, string(fd.Services().Get(i).FullName()))
+		}
+		return true
+	})
+	sort.Strings(names)
+	var out []byte
+	for _, name := range names {
+		service := protowire.AppendTag(nil, 1, protowire.BytesType)
+		service = protowire.AppendString(service, name)
+		out = append(out, reflectionField(1, service)...)
+	}
+	return reflectionField(6, out)
+}
+
+// extensionsOf returns every extension of the named message, wherever
+// it is declared.
+func (d *Descriptors) extensionsOf(message protoreflect.FullName) []protoreflect.ExtensionDescriptor {
+	var found []protoreflect.ExtensionDescriptor
+	var walk func(protoreflect.ExtensionDescriptors, protoreflect.MessageDescriptors)
+	walk = func(extensions protoreflect.ExtensionDescriptors, messages protoreflect.MessageDescriptors) {
+		for i := 0; i < extensions.Len(); i++ {
+			if xd := extensions.Get(i); xd.ContainingMessage().FullName() == message {
+				found = append(found, xd)
+			}
+		}
+		for i := 0; i < messages.Len(); i++ {
+			walk(messages.Get(i).Extensions(), messages.Get(i).Messages())
+		}
+	}
+	d.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		walk(fd.Extensions(), fd.Messages())
+		return true
+	})
+	return found
+}
+
+// fileDescriptorResponse returns fd followed by the files it imports,
+// directly or not, so the client can resolve every type it names.
+func fileDescriptorResponse(fd protoreflect.FileDescriptor) []byte {
+	var out []byte
+	seen := map[string]bool{}
+	var add func(protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] || fd.IsPlaceholder() {
+			return
+		}
+		seen[fd.Path()] = true
+		data, _ := proto.Marshal(protodesc.ToFileDescriptorProto(fd))
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, data)
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+	}
+	add(fd)
+	return reflectionField(4, out)
+}
+
+func reflectionError(code int, format string, args ...any) []byte {
+	out := protowire.AppendTag(nil, 1, protowire.VarintType)
+	out = protowire.AppendVarint(out, uint64(code))
+	out = protowire.AppendTag(out, 2, protowire.BytesType)
+	out = protowire.AppendString(out, fmt.Sprintf(format, args...))
+	return reflectionField(7, out)
+}
+
+func reflectionField(num protowire.Number, message []byte) []byte {
+	out := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(out, message)
+}
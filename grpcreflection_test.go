@@ -0,0 +1,207 @@
+package fake
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func petsDescriptors(t *testing.T) *Descriptors {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		{
+			Name:    proto.String("common.proto"),
+			Package: proto.String("common"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Id"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:   proto.String("value"),
+					Number: proto.Int32(1),
+					Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				}},
+			}},
+		},
+		{
+			Name:       proto.String("pets.proto"),
+			Package:    proto.String("pets"),
+			Dependency: []string{"common.proto"},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Pets"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Get"),
+					InputType:  proto.String(".common.Id"),
+					OutputType: proto.String(".common.Id"),
+				}},
+			}},
+		},
+	}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseDescriptorSet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// reflectionResponse is the part of a ServerReflectionResponse the
+// tests look at.
+type reflectionResponse struct {
+	files     []string
+	services  []string
+	errorCode uint64
+}
+
+func parseReflectionResponse(t *testing.T, b []byte) reflectionResponse {
+	t.Helper()
+	var r reflectionResponse
+	fields := func(b []byte, each func(protowire.Number, []byte, uint64)) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatal("malformed reflection response")
+			}
+			b = b[n:]
+			if typ == protowire.VarintType {
+				v, n := protowire.ConsumeVarint(b)
+				each(num, nil, v)
+				b = b[n:]
+				continue
+			}
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				t.Fatal("malformed reflection response")
+			}
+			each(num, v, 0)
+			b = b[n:]
+		}
+	}
+	fields(b, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 4:
+			fields(v, func(_ protowire.Number, file []byte, _ uint64) {
+				var fd descriptorpb.FileDescriptorProto
+				if err := proto.Unmarshal(file, &fd); err != nil {
+					t.Fatal(err)
+				}
+				r.files = append(r.files, fd.GetName())
+			})
+		case 6:
+			fields(v, func(_ protowire.Number, service []byte, _ uint64) {
+				fields(service, func(_ protowire.Number, name []byte, _ uint64) {
+					r.services = append(r.services, string(name))
+				})
+			})
+		case 7:
+			fields(v, func(num protowire.Number, _ []byte, code uint64) {
+				if num == 1 {
+					r.errorCode = code
+				}
+			})
+		}
+	})
+	return r
+}
+
+func TestGRPCReflection(t *testing.T) {
+	f := New(WithHTTP2())
+	if err := f.AddGRPCReflection(petsDescriptors(t)); err != nil {
+		t.Fatal(err)
+	}
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	for _, method := range grpcReflectionMethods {
+		t.Run(method, func(t *testing.T) {
+			body, requests := io.Pipe()
+			req, _ := http.NewRequest(http.MethodPost, f.BaseURL()+method, body)
+			req.Header.Set("Content-Type", "application/grpc")
+			resp, err := h2cClient().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			// Each request is answered before the next is sent, as
+			// grpcurl does.
+			ask := func(field protowire.Number, value string) reflectionResponse {
+				message := protowire.AppendTag(nil, field, protowire.BytesType)
+				message = protowire.AppendString(message, value)
+				var frame bytes.Buffer
+				writeGRPCFrame(&frame, message)
+				if _, err := requests.Write(frame.Bytes()); err != nil {
+					t.Fatal(err)
+				}
+				response, err := readGRPCFrame(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return parseReflectionResponse(t, response)
+			}
+
+			tests := []struct {
+				field protowire.Number
+				value string
+				want  reflectionResponse
+			}{
+				{7, "", reflectionResponse{services: []string{"pets.Pets"}}},
+				{4, "pets.Pets", reflectionResponse{files: []string{"pets.proto", "common.proto"}}},
+				{4, "pets.Pets.Get", reflectionResponse{files: []string{"pets.proto", "common.proto"}}},
+				{3, "common.proto", reflectionResponse{files: []string{"common.proto"}}},
+				{3, "missing.proto", reflectionResponse{errorCode: grpcNotFound}},
+				{4, "pets.Missing", reflectionResponse{errorCode: grpcNotFound}},
+			}
+			for _, tt := range tests {
+				got := ask(tt.field, tt.value)
+				sort.Strings(got.services)
+				if !slices.Equal(got.files, tt.want.files) || !slices.Equal(got.services, tt.want.services) || got.errorCode != tt.want.errorCode {
+					t.Errorf("request %d %q = %+v, want %+v", tt.field, tt.value, got, tt.want)
+				}
+			}
+
+			requests.Close()
+			if _, err := io.ReadAll(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+			if status := resp.Trailer.Get("Grpc-Status"); status != "0" {
+				t.Errorf("grpc-status trailer = %q, want 0", status)
+			}
+			if calls := f.CallCount(method, http.MethodPost); calls != 1 {
+				t.Errorf("CallCount = %d, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestGRPCReflectionNeedsDescriptors(t *testing.T) {
+	f := New()
+	if err := f.AddGRPCReflection(nil); err == nil {
+		t.Fatal("expected an error for reflection without descriptors")
+	}
+	if len(f.Endpoints) != 0 {
+		t.Errorf("registered %d endpoints despite the error", len(f.Endpoints))
+	}
+}
+
+func TestGRPCReflectionRegisteredTwice(t *testing.T) {
+	f := New()
+	if err := f.AddGRPCReflection(petsDescriptors(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddGRPCReflection(petsDescriptors(t)); err == nil {
+		t.Error("expected an error for registering reflection twice")
+	}
+	if len(f.Endpoints) != len(grpcReflectionMethods) {
+		t.Errorf("registered %d endpoints, want %d", len(f.Endpoints), len(grpcReflectionMethods))
+	}
+}
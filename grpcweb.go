@@ -0,0 +1,287 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GRPCWeb fakes unary gRPC methods over the gRPC-Web wire format, in
+// both its binary and base64 text encodings, for browser-targeting
+// clients, including their cross-origin preflights. The same stubs
+// answer native gRPC clients, such as grpc-go, when the service speaks
+// HTTP/2, see WithHTTP2. Messages are serialized protobuf bytes, such
+// as those from proto.Marshal, or JSON when Descriptors are given.
+type GRPCWeb struct {
+	// Methods is keyed by full method name, e.g. "/pkg.Service/Method".
+	Methods map[string]*GRPCWebMethod
+
+	// Descriptors, if set, describe the faked services, so responses
+	// can be given as JSON and requests are recorded as JSON too.
+	Descriptors *Descriptors
+
+	mutex    sync.Mutex
+	requests []GRPCWebRequest
+}
+
+// GRPCWebMethod stubs a unary gRPC method. A non-zero Code fails the
+// call with that gRPC status code and Message, and otherwise Response
+// is returned.
+type GRPCWebMethod struct {
+	Response []byte
+	Code     int
+	Message  string
+
+	// JSON, if set, is the response message in protobuf's JSON
+	// mapping, transcoded with the GRPCWeb's Descriptors in place of
+	// Response.
+	JSON string
+
+	// input describes the method's request message, if known.
+	input protoreflect.MessageDescriptor
+}
+
+// GRPCWebRequest is a call received by a GRPCWeb fake.
+type GRPCWebRequest struct {
+	Method  string
+	Message []byte
+	// JSON is Message in protobuf's JSON mapping, if the GRPCWeb has
+	// Descriptors for the method.
+	JSON string
+	// Text reports whether the call used the grpc-web-text encoding.
+	Text bool
+	// Native reports whether the call used gRPC itself rather than
+	// gRPC-Web.
+	Native bool
+}
+
+// AddGRPCWeb registers an endpoint for each of g's methods. It returns
+// an error, registering nothing, if a method's JSON can't be transcoded
+// with g's Descriptors.
+func (f *FakeService) AddGRPCWeb(g *GRPCWeb) error {
+	names := make([]string, 0, len(g.Methods))
+	for name := range g.Methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.describe(name, g.Methods[name]); err != nil {
+			return fmt.Errorf("gRPC-Web method %s: %w", name, err)
+		}
+	}
+	for _, name := range names {
+		name, method := name, g.Methods[name]
+		f.AddEndpoint(&Endpoint{
+			Path:   name,
+			Method: http.MethodPost,
+			Handler: func(c *gin.Context) {
+				g.serve(c, name, method)
+			},
+		})
+		// Browsers preflight cross-origin gRPC-Web calls, which tests
+		// needn't expect.
+		f.AddEndpoint(&Endpoint{
+			Path:     name,
+			Method:   http.MethodOptions,
+			Handler:  grpcWebPreflight,
+			Optional: true,
+		})
+	}
+	return nil
+}
+
+// grpcWebPreflight answers a browser's CORS preflight for a gRPC-Web
+// call from any origin.
+func grpcWebPreflight(c *gin.Context) {
+	allowGRPCWebOrigin(c)
+	headers := c.GetHeader("Access-Control-Request-Headers")
+	if headers == "" {
+		headers = "content-type, x-grpc-web, x-user-agent, grpc-timeout, authorization"
+	}
+	c.Header("Access-Control-Allow-Methods", http.MethodPost)
+	c.Header("Access-Control-Allow-Headers", headers)
+	c.Header("Access-Control-Max-Age", "600")
+	c.Status(http.StatusNoContent)
+}
+
+// allowGRPCWebOrigin lets a browser on the request's origin make the
+// call and read its gRPC status.
+func allowGRPCWebOrigin(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", origin)
+	c.Header("Access-Control-Expose-Headers", "grpc-status, grpc-message")
+	c.Header("Vary", "Origin")
+}
+
+// describe looks the method up in g's Descriptors, if any, transcoding
+// its JSON response.
+func (g *GRPCWeb) describe(name string, method *GRPCWebMethod) error {
+	if g.Descriptors == nil {
+		if method.JSON != "" {
+			return fmt.Errorf("a JSON response needs Descriptors")
+		}
+		return nil
+	}
+	md, err := g.Descriptors.method(name)
+	if err != nil {
+		return err
+	}
+	method.input = md.Input()
+	if method.JSON != "" {
+		if method.Response, err = jsonToMessage(md.Output(), method.JSON); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Requests returns every call received, oldest first.
+func (g *GRPCWeb) Requests() []GRPCWebRequest {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]GRPCWebRequest(nil), g.requests...)
+}
+
+const (
+	grpcWebDataFrame    = 0x00
+	grpcWebTrailerFrame = 0x80
+)
+
+func (g *GRPCWeb) serve(c *gin.Context, name string, method *GRPCWebMethod) {
+	allowGRPCWebOrigin(c)
+	contentType := c.ContentType()
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	web := strings.HasPrefix(contentType, "application/grpc-web")
+	if !web && contentType != "application/grpc" && !strings.HasPrefix(contentType, "application/grpc+") {
+		c.String(http.StatusUnsupportedMediaType, "unsupported content type %q", contentType)
+		return
+	}
+
+	body, _ := io.ReadAll(c.Request.Body)
+	if text {
+		var err error
+		if body, err = decodeGRPCWebText(body); err != nil {
+			c.String(http.StatusBadRequest, "invalid grpc-web-text body: %s", err)
+			return
+		}
+	}
+	message, err := readGRPCWebMessage(body)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid grpc-web body: %s", err)
+		return
+	}
+
+	request := GRPCWebRequest{Method: name, Message: message, Text: text, Native: !web}
+	if method.input != nil {
+		request.JSON, _ = messageToJSON(method.input, message)
+	}
+	g.mutex.Lock()
+	g.requests = append(g.requests, request)
+	g.mutex.Unlock()
+
+	var out bytes.Buffer
+	if method.Code == 0 {
+		writeGRPCWebFrame(&out, grpcWebDataFrame, method.Response)
+	}
+	if !web {
+		writeGRPCResponse(c, out.Bytes(), method.Code, method.Message)
+		return
+	}
+	trailers := fmt.Sprintf("grpc-status:%d\r\ngrpc-message:%s\r\n", method.Code, grpcPercentEncode(method.Message))
+	writeGRPCWebFrame(&out, grpcWebTrailerFrame, []byte(trailers))
+
+	response := out.Bytes()
+	if text {
+		response = []byte(base64.StdEncoding.EncodeToString(response))
+	}
+	c.Data(http.StatusOK, contentType, response)
+}
+
+// writeGRPCResponse answers a native gRPC call with the given framed
+// messages, sending its status as HTTP/2 trailers.
+func writeGRPCResponse(c *gin.Context, frames []byte, code int, message string) {
+	c.Header("Content-Type", "application/grpc")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(frames)
+	setGRPCStatus(c, code, message)
+}
+
+// readGRPCWebMessage returns the message in the first data frame of a
+// request body.
+func readGRPCWebMessage(body []byte) ([]byte, error) {
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, fmt.Errorf("truncated frame header")
+		}
+		flag, length := body[0], binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < length {
+			return nil, fmt.Errorf("truncated frame")
+		}
+		frame := body[5 : 5+length]
+		if flag&grpcWebTrailerFrame == 0 {
+			return frame, nil
+		}
+		body = body[5+length:]
+	}
+	return nil, fmt.Errorf("no message frame")
+}
+
+func writeGRPCWebFrame(w *bytes.Buffer, flag byte, payload []byte) {
+	var header [5]byte
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	w.Write(header[:])
+	w.Write(payload)
+}
+
+// decodeGRPCWebText decodes a grpc-web-text body, which clients may
+// send as several padded base64 chunks run together.
+func decodeGRPCWebText(body []byte) ([]byte, error) {
+	var decoded []byte
+	body = bytes.TrimSpace(body)
+	for len(body) > 0 {
+		end := bytes.IndexByte(body, '=')
+		if end == -1 {
+			end = len(body)
+		} else {
+			for end < len(body) && body[end] == '=' {
+				end++
+			}
+		}
+		chunk, err := base64.StdEncoding.DecodeString(string(body[:end]))
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, chunk...)
+		body = body[end:]
+	}
+	return decoded, nil
+}
+
+// grpcPercentEncode encodes a grpc-message as the gRPC protocol
+// requires, escaping '%' and anything outside printable ASCII.
+func grpcPercentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch < 0x20 || ch > 0x7e || ch == '%' {
+			fmt.Fprintf(&b, "%%%02X", ch)
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
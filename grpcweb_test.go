@@ -0,0 +1,114 @@
+package fake
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGRPCWebAnswersNativeGRPC(t *testing.T) {
+	f := New(WithHTTP2())
+	g := &GRPCWeb{Methods: map[string]*GRPCWebMethod{
+		"/pets.Pets/Get":    {Response: []byte("rex")},
+		"/pets.Pets/Delete": {Code: 7, Message: "not allowed"},
+	}}
+	if err := f.AddGRPCWeb(g); err != nil {
+		t.Fatal(err)
+	}
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	call := func(method string) (*http.Response, []byte) {
+		var body bytes.Buffer
+		writeGRPCWebFrame(&body, grpcWebDataFrame, []byte("id"))
+		req, _ := http.NewRequest(http.MethodPost, f.BaseURL()+method, &body)
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("TE", "trailers")
+		resp, err := h2cClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return resp, out
+	}
+
+	resp, body := call("/pets.Pets/Get")
+	message, err := readGRPCWebMessage(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(message) != "rex" {
+		t.Errorf("message = %q, want rex", message)
+	}
+	if status := resp.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Errorf("grpc-status trailer = %q, want 0", status)
+	}
+
+	resp, body = call("/pets.Pets/Delete")
+	if len(body) != 0 {
+		t.Errorf("failed call sent a message: %q", body)
+	}
+	if status := resp.Trailer.Get("Grpc-Status"); status != "7" {
+		t.Errorf("grpc-status trailer = %q, want 7", status)
+	}
+	if message := resp.Trailer.Get("Grpc-Message"); message != "not allowed" {
+		t.Errorf("grpc-message trailer = %q, want \"not allowed\"", message)
+	}
+
+	requests := g.Requests()
+	if len(requests) != 2 || !requests[0].Native || string(requests[0].Message) != "id" {
+		t.Errorf("requests = %+v, want two native calls with message id", requests)
+	}
+}
+
+func TestGRPCWebAllowsCrossOriginCalls(t *testing.T) {
+	f := New()
+	if err := f.AddGRPCWeb(&GRPCWeb{Methods: map[string]*GRPCWebMethod{
+		"/pets.Pets/Get": {Response: []byte("rex")},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	req, _ := http.NewRequest(http.MethodOptions, f.BaseURL()+"/pets.Pets/Get", nil)
+	req.Header.Set("Origin", "http://app.test")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-grpc-web")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("preflight got %d, want 204", resp.StatusCode)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "http://app.test",
+		"Access-Control-Allow-Methods": http.MethodPost,
+		"Access-Control-Allow-Headers": "content-type, x-grpc-web",
+	}
+	for header, value := range want {
+		if got := resp.Header.Get(header); got != value {
+			t.Errorf("preflight %s = %q, want %q", header, got, value)
+		}
+	}
+
+	var body bytes.Buffer
+	writeGRPCWebFrame(&body, grpcWebDataFrame, []byte("id"))
+	req, _ = http.NewRequest(http.MethodPost, f.BaseURL()+"/pets.Pets/Get", &body)
+	req.Header.Set("Origin", "http://app.test")
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "http://app.test" {
+		t.Errorf("call Access-Control-Allow-Origin = %q, want http://app.test", got)
+	}
+	if got := resp.Header.Get("Access-Control-Expose-Headers"); got != "grpc-status, grpc-message" {
+		t.Errorf("call Access-Control-Expose-Headers = %q", got)
+	}
+}
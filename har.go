@@ -0,0 +1,293 @@
+package fake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harBody        `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// ExportHAR writes every request the service has received, along with
+// the responses sent, to w in HAR 1.2 format so that they can be
+// inspected with browser devtools or other HAR tooling.
+func (f *FakeService) ExportHAR(w io.Writer) error {
+	har := harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "fakes", Version: "1.0"},
+		Entries: []harEntry{},
+	}}
+	for _, r := range f.Journal() {
+		har.Log.Entries = append(har.Log.Entries, newHAREntry(r))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(har)
+}
+
+func newHAREntry(r RecordedRequest) harEntry {
+	u := *r.URL
+	u.Scheme = "http"
+	u.Host = r.Host
+
+	millis := float64(r.Duration) / float64(time.Millisecond)
+	entry := harEntry{
+		StartedDateTime: r.Time.Format(time.RFC3339Nano),
+		Time:            millis,
+		Timings:         harTimings{Wait: millis},
+		Request: harRequest{
+			Method:      r.Method,
+			URL:         u.String(),
+			HTTPVersion: r.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(r.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(r.Body),
+		},
+	}
+	for name, values := range r.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: v})
+		}
+	}
+	if len(r.Body) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: r.Header.Get("Content-Type"),
+			Text:     string(r.Body),
+		}
+	}
+
+	resp := r.Response
+	if resp == nil {
+		resp = &RecordedResponse{Header: http.Header{}}
+	}
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: r.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(resp.Header),
+		Content: harBody{
+			Size:     len(resp.Body),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     string(resp.Body),
+		},
+		HeadersSize: -1,
+		BodySize:    len(resp.Body),
+	}
+	return entry
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, harNameValue{Name: name, Value: v})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
+
+// replaySkippedHeaders are recorded response headers that describe how
+// the body was sent rather than the body itself, which is replayed
+// decoded.
+var replaySkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+}
+
+// FromHAR reads a HAR file, such as one saved from browser devtools or
+// written by ExportHAR, and returns an Optional endpoint replaying each
+// recorded response, to be registered with AddEndpoint. Endpoints match
+// the method, path and query of the recorded request, whatever its
+// host. When the same request was recorded several times the responses
+// are replayed in order, the last one repeating once they run out.
+// Entries without a response, such as aborted requests, are skipped.
+func FromHAR(path string) ([]*Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HAR file: %w", err)
+	}
+	var har harLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file: %w", err)
+	}
+
+	var endpoints []*Endpoint
+	repeats := map[string][]*Endpoint{}
+	for i, entry := range har.Log.Entries {
+		if entry.Response.Status == 0 {
+			continue
+		}
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: invalid URL: %w", i+1, err)
+		}
+		e, err := newHAREndpoint(entry, u)
+		if err != nil {
+			return nil, fmt.Errorf("HAR entry %d: %w", i+1, err)
+		}
+		key := e.Method + " " + u.Path + "?" + u.Query().Encode()
+		repeats[key] = append(repeats[key], e)
+		endpoints = append(endpoints, e)
+	}
+
+	sequenceReplays("HAR", repeats)
+	return endpoints, nil
+}
+
+// sequenceReplays makes endpoints replaying the same request several
+// times step through a scenario of their own, one state per recorded
+// response, the last repeating once they run out.
+func sequenceReplays(prefix string, repeats map[string][]*Endpoint) {
+	for key, sequence := range repeats {
+		if len(sequence) < 2 {
+			continue
+		}
+		for i, e := range sequence {
+			e.Scenario = prefix + " " + key
+			e.RequiredState = replayState(i)
+			if i < len(sequence)-1 {
+				e.NewState = replayState(i + 1)
+			}
+		}
+	}
+}
+
+// newReplayEndpoint returns an Optional endpoint matching the method,
+// path and query of a recorded request.
+func newReplayEndpoint(method string, u *url.URL, status int) *Endpoint {
+	e := &Endpoint{
+		Method:     method,
+		StatusCode: status,
+		Optional:   true,
+	}
+	setLiteralPath(e, u.Path)
+	if e.Path == "" && e.PathPattern == "" {
+		e.Path = "/"
+	}
+	query := u.Query()
+	e.Matcher = func(r *http.Request) bool {
+		return reflect.DeepEqual(r.URL.Query(), query)
+	}
+	return e
+}
+
+func replayState(i int) string {
+	if i == 0 {
+		return ScenarioStarted
+	}
+	return fmt.Sprintf("Response %d", i+1)
+}
+
+func newHAREndpoint(entry harEntry, u *url.URL) (*Endpoint, error) {
+	e := newReplayEndpoint(entry.Request.Method, u, entry.Response.Status)
+
+	content := entry.Response.Content
+	e.Response = content.Text
+	if strings.EqualFold(content.Encoding, "base64") {
+		body, err := base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 response body: %w", err)
+		}
+		e.Response = string(body)
+	}
+
+	e.ResponseHeaders = http.Header{}
+	for _, header := range entry.Response.Headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		if strings.HasPrefix(name, ":") || replaySkippedHeaders[name] {
+			continue
+		}
+		e.ResponseHeaders.Add(name, header.Value)
+	}
+	if e.ResponseHeaders.Get("Content-Type") == "" && content.MimeType != "" {
+		e.ResponseHeaders.Set("Content-Type", content.MimeType)
+	}
+	return e, nil
+}
@@ -0,0 +1,70 @@
+package fake
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChaosHeaders mangles response headers, to harden a client's handling
+// of responses that are missing headers or carry them twice.
+type ChaosHeaders struct {
+	// Percent is the chance, from 0 to 100, that a response's headers
+	// are mangled.
+	Percent int
+	// Drop lists headers to remove. Dropping Content-Length leaves the
+	// body to be delimited by the connection closing.
+	Drop []string
+	// Duplicate lists headers to send twice, when they are set.
+	Duplicate []string
+}
+
+func (h *ChaosHeaders) applies(rng *rand.Rand) bool {
+	return chance(rng, h.Percent)
+}
+
+// headerCorruptingWriter mangles the response headers just before
+// they are written.
+type headerCorruptingWriter struct {
+	gin.ResponseWriter
+	headers *ChaosHeaders
+	done    bool
+}
+
+func (w *headerCorruptingWriter) corrupt() {
+	if w.done || w.ResponseWriter.Written() {
+		return
+	}
+	w.done = true
+
+	header := w.Header()
+	for _, name := range w.headers.Drop {
+		header.Del(name)
+		if http.CanonicalHeaderKey(name) == "Content-Length" {
+			// Otherwise net/http works the length out and adds it
+			// back, or falls back to a chunked body.
+			header.Set("Transfer-Encoding", "identity")
+		}
+	}
+	for _, name := range w.headers.Duplicate {
+		for _, value := range header.Values(name) {
+			header.Add(name, value)
+		}
+	}
+}
+
+func (w *headerCorruptingWriter) WriteHeaderNow() {
+	w.corrupt()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *headerCorruptingWriter) Write(b []byte) (int, error) {
+	w.corrupt()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerCorruptingWriter) WriteString(s string) (int, error) {
+	w.corrupt()
+	return w.ResponseWriter.WriteString(s)
+}
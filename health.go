@@ -0,0 +1,96 @@
+package fake
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthStatus is the state a health endpoint reports.
+type HealthStatus string
+
+const (
+	HealthHealthy HealthStatus = "healthy"
+	// HealthDegraded is reported with a 200, as the service still
+	// works, but lets clients that read the body tell the difference.
+	HealthDegraded  HealthStatus = "degraded"
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// Health holds the states reported by the endpoints HealthEndpoints
+// registers, which can be changed mid-test.
+type Health struct {
+	mutex    sync.RWMutex
+	statuses map[string]HealthStatus
+}
+
+// healthPaths are the endpoints HealthEndpoints registers.
+var healthPaths = []string{"/healthz", "/readyz", "/livez"}
+
+// HealthEndpoints registers /healthz, /readyz and /livez, which report
+// healthy until told otherwise through Health, answering with a 200
+// and the status as JSON, or a 503 when unhealthy. Health checks may or
+// may not run during a test, so the endpoints are Optional.
+func HealthEndpoints() Option {
+	return func(f *FakeService) {
+		f.health = &Health{statuses: map[string]HealthStatus{}}
+		for _, path := range healthPaths {
+			f.health.statuses[path] = HealthHealthy
+			f.AddEndpoint(&Endpoint{
+				Path:     path,
+				Method:   http.MethodGet,
+				Handler:  f.health.serve(path),
+				Optional: true,
+			})
+		}
+	}
+}
+
+// Health returns the states reported by the health endpoints, or nil
+// unless the service was created with HealthEndpoints.
+func (f *FakeService) Health() *Health {
+	return f.health
+}
+
+// Set sets the status every health endpoint reports.
+func (h *Health) Set(status HealthStatus) {
+	for _, path := range healthPaths {
+		h.set(path, status)
+	}
+}
+
+// SetHealth sets the status /healthz reports.
+func (h *Health) SetHealth(status HealthStatus) {
+	h.set("/healthz", status)
+}
+
+// SetReadiness sets the status /readyz reports.
+func (h *Health) SetReadiness(status HealthStatus) {
+	h.set("/readyz", status)
+}
+
+// SetLiveness sets the status /livez reports.
+func (h *Health) SetLiveness(status HealthStatus) {
+	h.set("/livez", status)
+}
+
+func (h *Health) set(path string, status HealthStatus) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.statuses[path] = status
+}
+
+func (h *Health) serve(path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.mutex.RLock()
+		status := h.statuses[path]
+		h.mutex.RUnlock()
+
+		code := http.StatusOK
+		if status == HealthUnhealthy {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, map[string]HealthStatus{"status": status})
+	}
+}
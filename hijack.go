@@ -0,0 +1,33 @@
+package fake
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConnHandler scripts a raw connection taken over by Hijack. rw
+// buffers the connection and may already hold bytes the client sent
+// after the request headers, so reads should go through it.
+type ConnHandler func(conn net.Conn, rw *bufio.ReadWriter, r *http.Request)
+
+// Hijack returns a Handler that takes the connection over from the
+// HTTP server and hands it to h, for scripting protocol upgrades,
+// custom handshakes or malformed responses byte by byte. The
+// connection is closed once h returns. HTTP/2 connections can't be
+// hijacked and are answered with a 500.
+func Hijack(h ConnHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, rw, err := c.Writer.Hijack()
+		if err != nil {
+			c.String(http.StatusInternalServerError, fmt.Sprintf("failed to hijack connection: %s", err))
+			return
+		}
+		defer conn.Close()
+		h(conn, rw, c.Request)
+		rw.Flush()
+	}
+}
@@ -0,0 +1,45 @@
+package fake
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// WithHTTP2 lets the service speak HTTP/2: h2 when combined with
+// WithTLS, and cleartext h2c otherwise, alongside HTTP/1.1 either way.
+// Each RecordedRequest's Proto shows which protocol the client used,
+// and connection reset chaos resets the stream rather than the
+// connection for HTTP/2 requests.
+func WithHTTP2() Option {
+	return func(f *FakeService) {
+		f.http2 = true
+	}
+}
+
+// configureHTTP2 sets the test server up for HTTP/2 before it starts.
+func (f *FakeService) configureHTTP2() {
+	if f.tls {
+		f.testserver.EnableHTTP2 = true
+		return
+	}
+	f.testserver.Config.Handler = h2c.NewHandler(f.testserver.Config.Handler, &http2.Server{})
+}
+
+// h2cClient returns an *http.Client that speaks HTTP/2 over cleartext
+// connections, using prior knowledge rather than an upgrade.
+func h2cClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
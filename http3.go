@@ -0,0 +1,96 @@
+package fake
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3Server serves the service over QUIC, see WithHTTP3.
+type http3Server struct {
+	server *http3.Server
+	conn   net.PacketConn
+}
+
+// WithHTTP3 also serves the same endpoints over HTTP/3, on a UDP port
+// alongside the HTTPS one, and implies WithTLS. HTTP3Addr returns the
+// UDP address and HTTP3Client a client that speaks HTTP/3 to it.
+// Responses over TCP advertise the HTTP/3 endpoint with Alt-Svc when it
+// has the same port number, as it does unless that UDP port is taken.
+// HTTP/3 support is experimental: hijacking handlers and connection
+// reset chaos abort the stream rather than the connection.
+func WithHTTP3() Option {
+	return func(f *FakeService) {
+		f.tls = true
+		f.http3 = true
+	}
+}
+
+// HTTP3Addr returns the UDP address the service serves HTTP/3 on, such
+// as 127.0.0.1:8080, once it is running with WithHTTP3, and "" otherwise.
+func (f *FakeService) HTTP3Addr() string {
+	if f.quic == nil {
+		return ""
+	}
+	return f.quic.conn.LocalAddr().String()
+}
+
+// HTTP3Client returns an *http.Client that speaks HTTP/3 to the service
+// and trusts its certificate, once it is running with WithHTTP3.
+func (f *FakeService) HTTP3Client() *http.Client {
+	return &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{RootCAs: f.CertPool()},
+			Dial:            f.dialHTTP3,
+		},
+	}
+}
+
+// startHTTP3 serves handler over QUIC, preferring the UDP port with the
+// same number as the service's TCP port.
+func (f *FakeService) startHTTP3(handler http.Handler, config *tls.Config) error {
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", f.port))
+	if err != nil {
+		if conn, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			return err
+		}
+	}
+	server := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(config.Clone())}
+	f.quic = &http3Server{server: server, conn: conn}
+	go server.Serve(conn)
+
+	if _, port, _ := net.SplitHostPort(conn.LocalAddr().String()); port == f.port {
+		f.testserver.Config.Handler = advertiseHTTP3(server, f.testserver.Config.Handler)
+	}
+	return nil
+}
+
+// advertiseHTTP3 adds the Alt-Svc header pointing clients at server to
+// every response.
+func advertiseHTTP3(server *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			server.SetQuicHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dialHTTP3 connects to the service's HTTP/3 address whatever host the
+// request names, as BaseURL names localhost, which may resolve to ::1.
+func (f *FakeService) dialHTTP3(ctx context.Context, _ string, tlsConf *tls.Config, conf *quic.Config) (quic.EarlyConnection, error) {
+	if f.quic == nil {
+		return nil, fmt.Errorf("the service isn't serving HTTP/3")
+	}
+	return quic.DialAddrEarly(ctx, f.HTTP3Addr(), tlsConf, conf)
+}
+
+func (s *http3Server) close() {
+	s.server.Close()
+	s.conn.Close()
+}
@@ -0,0 +1,39 @@
+package fake
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestWithHTTP3(t *testing.T) {
+	f := New(WithHTTP3())
+	f.AddEndpoint(&Endpoint{Path: "/hello", Method: http.MethodGet, Response: "hello"})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	if f.HTTP3Addr() == "" {
+		t.Fatal("HTTP3Addr is empty")
+	}
+	resp, err := f.HTTP3Client().Get(f.BaseURL() + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 3 || string(body) != "hello" {
+		t.Fatalf("got %s %q, want HTTP/3 \"hello\"", resp.Proto, body)
+	}
+	if proto := f.Endpoints[0].Requests()[0].Proto; proto != "HTTP/3.0" {
+		t.Errorf("recorded Proto = %q, want HTTP/3.0", proto)
+	}
+
+	resp, err = f.Client().Get(f.BaseURL() + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Alt-Svc") == "" {
+		t.Error("TCP responses don't advertise HTTP/3 with Alt-Svc")
+	}
+}
@@ -0,0 +1,63 @@
+package fake
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithIdleConnectionDrops closes keep-alive connections once they have
+// sat idle for after, as load balancers do without warning, to
+// reproduce clients that reuse a stale connection and see it fail.
+func WithIdleConnectionDrops(after time.Duration) Option {
+	return func(f *FakeService) {
+		d := &idleDropper{after: after, chaos: f.chaosControl, idle: map[net.Conn]*idleConn{}}
+		f.testserver.Config.ConnState = d.connState
+	}
+}
+
+type idleConn struct {
+	timer *time.Timer
+}
+
+// idleDropper tracks the service's idle connections, closing each one
+// unless it becomes active again in time.
+type idleDropper struct {
+	after time.Duration
+	chaos *ChaosControl
+	mutex sync.Mutex
+	idle  map[net.Conn]*idleConn
+}
+
+func (d *idleDropper) connState(conn net.Conn, state http.ConnState) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if idle, ok := d.idle[conn]; ok {
+		idle.timer.Stop()
+		delete(d.idle, conn)
+	}
+	if state == http.StateIdle {
+		idle := &idleConn{}
+		idle.timer = time.AfterFunc(d.after, func() { d.drop(conn, idle) })
+		d.idle[conn] = idle
+	}
+}
+
+// drop closes conn if it is still in the idle spell idle was started
+// for, rather than having been reused in the meantime, and chaos hasn't
+// been disabled.
+func (d *idleDropper) drop(conn net.Conn, idle *idleConn) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.idle[conn] != idle {
+		return
+	}
+	delete(d.idle, conn)
+	if !d.chaos.enabled() {
+		return
+	}
+	fmt.Printf("chaos dropped idle connection from %s\n", conn.RemoteAddr())
+	conn.Close()
+}
@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AsyncJob models a long-running job API. A POST to SubmitPath accepts
+// a new job with a 202 and returns its ID, and GETs against StatusPath
+// report the job as "pending" PendingPolls times before reporting it as
+// "complete" along with the Result payload.
+type AsyncJob struct {
+	// SubmitPath defaults to "/jobs".
+	SubmitPath string
+	// StatusPath must contain an ":id" parameter and defaults to "/jobs/:id".
+	StatusPath string
+	// PendingPolls is the number of status polls answered with "pending"
+	// before the job completes.
+	PendingPolls int
+	// Result is the raw JSON returned as the "result" of a complete job.
+	Result string
+
+	mutex sync.Mutex
+	jobs  map[string]int
+	next  int
+}
+
+type asyncJobStatus struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// AddAsyncJob registers the submit and status endpoints for the job API.
+func (f *FakeService) AddAsyncJob(j *AsyncJob) {
+	if j.SubmitPath == "" {
+		j.SubmitPath = "/jobs"
+	}
+	if j.StatusPath == "" {
+		j.StatusPath = "/jobs/:id"
+	}
+	j.jobs = map[string]int{}
+
+	f.AddEndpoint(&Endpoint{
+		Path:    j.SubmitPath,
+		Method:  http.MethodPost,
+		Handler: j.submit,
+	})
+	f.AddEndpoint(&Endpoint{
+		Path:    j.StatusPath,
+		Method:  http.MethodGet,
+		Handler: j.status,
+	})
+}
+
+func (j *AsyncJob) submit(c *gin.Context) {
+	j.mutex.Lock()
+	j.next++
+	id := fmt.Sprintf("job-%d", j.next)
+	j.jobs[id] = 0
+	j.mutex.Unlock()
+
+	c.Header("Location", strings.Replace(j.StatusPath, ":id", id, 1))
+	c.JSON(http.StatusAccepted, asyncJobStatus{ID: id, Status: "pending"})
+}
+
+func (j *AsyncJob) status(c *gin.Context) {
+	id := c.Param("id")
+
+	j.mutex.Lock()
+	polls, ok := j.jobs[id]
+	if ok {
+		polls++
+		j.jobs[id] = polls
+	}
+	j.mutex.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("job %s not found", id)})
+		return
+	}
+	if polls <= j.PendingPolls {
+		c.JSON(http.StatusOK, asyncJobStatus{ID: id, Status: "pending"})
+		return
+	}
+
+	resp := asyncJobStatus{ID: id, Status: "complete"}
+	if j.Result != "" {
+		resp.Result = json.RawMessage(j.Result)
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,129 @@
+package fake
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Journal is a list of recorded requests, oldest first, that can be
+// narrowed down with Filter.
+type Journal []RecordedRequest
+
+// RequestFilter selects requests from a Journal.
+type RequestFilter func(RecordedRequest) bool
+
+// Journal returns every request the service has received, matched or
+// not, in the order they arrived.
+func (f *FakeService) Journal() Journal {
+	f.mutex.RLock()
+	var journal Journal
+	for _, e := range f.Endpoints {
+		journal = append(journal, e.Requests()...)
+	}
+	f.mutex.RUnlock()
+
+	for _, u := range f.UnmatchedRequests() {
+		journal = append(journal, u.Request)
+	}
+	sort.SliceStable(journal, func(i, j int) bool {
+		return journal[i].Time.Before(journal[j].Time)
+	})
+	return journal
+}
+
+// Filter returns the requests matching every filter.
+func (j Journal) Filter(filters ...RequestFilter) Journal {
+	var matched Journal
+	for _, r := range j {
+		if matchesAll(r, filters) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+func matchesAll(r RecordedRequest, filters []RequestFilter) bool {
+	for _, filter := range filters {
+		if !filter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Span returns the time between the first and last request.
+func (j Journal) Span() time.Duration {
+	if len(j) < 2 {
+		return 0
+	}
+	return j[len(j)-1].Time.Sub(j[0].Time)
+}
+
+// Concurrent reports whether every request in the journal was being
+// handled at the same time, i.e. each arrived before any had finished,
+// rather than being made one after another.
+func (j Journal) Concurrent() bool {
+	if len(j) < 2 {
+		return true
+	}
+	lastStart, firstEnd := j[0].Time, j[0].End()
+	for _, r := range j[1:] {
+		if r.Time.After(lastStart) {
+			lastStart = r.Time
+		}
+		if r.End().Before(firstEnd) {
+			firstEnd = r.End()
+		}
+	}
+	return lastStart.Before(firstEnd)
+}
+
+// Method selects requests made with the given method.
+func Method(method string) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return strings.EqualFold(r.Method, method)
+	}
+}
+
+// Path selects requests made to exactly path.
+func Path(path string) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return r.URL.Path == path
+	}
+}
+
+// PathPrefix selects requests whose path starts with prefix.
+func PathPrefix(prefix string) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+// Header selects requests carrying the header with the given value.
+func Header(name, value string) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return r.Header.Get(name) == value
+	}
+}
+
+// Query selects requests with the query parameter set to value.
+func Query(name, value string) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return r.URL.Query().Get(name) == value
+	}
+}
+
+// Since selects requests received at or after t.
+func Since(t time.Time) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return !r.Time.Before(t)
+	}
+}
+
+// Until selects requests received before t.
+func Until(t time.Time) RequestFilter {
+	return func(r RecordedRequest) bool {
+		return r.Time.Before(t)
+	}
+}
@@ -0,0 +1,85 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonDiff compares two JSON documents and describes every difference
+// between them: keys missing from actual, unexpected keys in actual, and
+// values that changed. An empty result means the documents are equal.
+func jsonDiff(expected, actual []byte) ([]string, error) {
+	var want, got any
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return nil, fmt.Errorf("expected value is not valid JSON: %w", err)
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		return nil, fmt.Errorf("actual value is not valid JSON: %w", err)
+	}
+	var diffs []string
+	diffJSONValues("$", want, got, &diffs)
+	return diffs, nil
+}
+
+func diffJSONValues(path string, want, got any, diffs *[]string) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(w) {
+			gv, ok := g[k]
+			if !ok {
+				*diffs = append(*diffs, fmt.Sprintf("missing key %s.%s", path, k))
+				continue
+			}
+			diffJSONValues(path+"."+k, w[k], gv, diffs)
+		}
+		for _, k := range sortedKeys(g) {
+			if _, ok := w[k]; !ok {
+				*diffs = append(*diffs, fmt.Sprintf("unexpected key %s.%s", path, k))
+			}
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		if len(w) != len(g) {
+			*diffs = append(*diffs, fmt.Sprintf("length of %s changed: expected %d, got %d", path, len(w), len(g)))
+		}
+		for i := 0; i < len(w) && i < len(g); i++ {
+			diffJSONValues(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, fmt.Sprintf("changed value at %s: expected %s, got %s", path, compactJSON(want), compactJSON(got)))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func compactJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func formatJSONDiff(diffs []string) string {
+	return "  " + strings.Join(diffs, "\n  ")
+}
@@ -0,0 +1,182 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+// JSONRPC fakes a JSON-RPC 2.0 service, dispatching calls POSTed to
+// Path by method name. Batches and notifications are handled as the
+// specification describes, and every response echoes its call's id.
+type JSONRPC struct {
+	// Path defaults to "/".
+	Path    string
+	Methods map[string]*JSONRPCMethod
+
+	mutex    sync.Mutex
+	requests []JSONRPCRequest
+}
+
+// JSONRPCMethod stubs a JSON-RPC method, answering with Handler if it
+// is set, otherwise Error if it is set, otherwise Result.
+type JSONRPCMethod struct {
+	Result  any
+	Error   *JSONRPCError
+	Handler func(params json.RawMessage) (any, *JSONRPCError)
+}
+
+// JSONRPCError is a JSON-RPC error object.
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// JSONRPCRequest is a call received by a JSON-RPC fake. ID is nil for
+// notifications.
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// AddJSONRPC registers the endpoint serving j.
+func (f *FakeService) AddJSONRPC(j *JSONRPC) {
+	if j.Path == "" {
+		j.Path = "/"
+	}
+	f.AddEndpoint(&Endpoint{
+		Path:    j.Path,
+		Method:  http.MethodPost,
+		Handler: j.serve,
+	})
+}
+
+// Requests returns every call received, oldest first, with the calls
+// in a batch in the order they were sent.
+func (j *JSONRPC) Requests() []JSONRPCRequest {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return append([]JSONRPCRequest(nil), j.requests...)
+}
+
+// CallCount returns how many times method was called.
+func (j *JSONRPC) CallCount(method string) int {
+	count := 0
+	for _, r := range j.Requests() {
+		if r.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+func (j *JSONRPC) serve(c *gin.Context) {
+	body, _ := io.ReadAll(c.Request.Body)
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			c.JSON(http.StatusOK, jsonRPCErrorResponse(nil, JSONRPCParseError, err.Error()))
+			return
+		}
+		if len(batch) == 0 {
+			c.JSON(http.StatusOK, jsonRPCErrorResponse(nil, JSONRPCInvalidRequest, "empty batch"))
+			return
+		}
+		responses := []jsonRPCResponse{}
+		for _, call := range batch {
+			if resp := j.call(call); resp != nil {
+				responses = append(responses, *resp)
+			}
+		}
+		if len(responses) == 0 {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+
+	resp := j.call(body)
+	if resp == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// call handles a single call, returning nil for notifications.
+func (j *JSONRPC) call(raw json.RawMessage) *jsonRPCResponse {
+	var req JSONRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return jsonRPCErrorResponse(nil, JSONRPCParseError, err.Error())
+		}
+		return jsonRPCErrorResponse(nil, JSONRPCInvalidRequest, err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return jsonRPCErrorResponse(req.ID, JSONRPCInvalidRequest, "not a JSON-RPC 2.0 request")
+	}
+
+	j.mutex.Lock()
+	j.requests = append(j.requests, req)
+	j.mutex.Unlock()
+
+	var resp *jsonRPCResponse
+	method, ok := j.Methods[req.Method]
+	switch {
+	case !ok:
+		resp = jsonRPCErrorResponse(req.ID, JSONRPCMethodNotFound, fmt.Sprintf("method %q not found", req.Method))
+	case method.Handler != nil:
+		result, rpcErr := method.Handler(req.Params)
+		resp = &jsonRPCResponse{JSONRPC: "2.0", Result: result, Error: rpcErr, ID: req.ID}
+	case method.Error != nil:
+		resp = &jsonRPCResponse{JSONRPC: "2.0", Error: method.Error, ID: req.ID}
+	default:
+		resp = &jsonRPCResponse{JSONRPC: "2.0", Result: method.Result, ID: req.ID}
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if resp.Error == nil && resp.Result == nil {
+		// A successful response must carry a result, even if null.
+		resp.Result = json.RawMessage("null")
+	}
+	return resp
+}
+
+func jsonRPCErrorResponse(id json.RawMessage, code int, message string) *jsonRPCResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &jsonRPCResponse{
+		JSONRPC: "2.0",
+		Error:   &JSONRPCError{Code: code, Message: message},
+		ID:      id,
+	}
+}
@@ -0,0 +1,163 @@
+package fake
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWTIssuer fakes an identity provider's signing keys. It generates an
+// RSA keypair, serves the public key as a JWKS and mints RS256 JWTs
+// signed with the private key, so services that validate tokens issued
+// upstream can be tested without a real one.
+type JWTIssuer struct {
+	// Issuer is the iss claim of minted tokens, the service's BaseURL
+	// by default.
+	Issuer string
+	// JWKSPath defaults to "/.well-known/jwks.json".
+	JWKSPath string
+	// KeyID is the kid of the key, "fake" by default.
+	KeyID string
+	// TTL is how long minted tokens last, an hour by default.
+	TTL time.Duration
+
+	f    *FakeService
+	once sync.Once
+	key  *rsa.PrivateKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// AddJWTIssuer registers the JWKS endpoint for j. Clients often cache
+// keys, so the endpoint is Optional.
+func (f *FakeService) AddJWTIssuer(j *JWTIssuer) {
+	if j.JWKSPath == "" {
+		j.JWKSPath = "/.well-known/jwks.json"
+	}
+	j.f = f
+	f.AddEndpoint(&Endpoint{
+		Path:     j.JWKSPath,
+		Method:   http.MethodGet,
+		Handler:  j.serveJWKS,
+		Optional: true,
+	})
+}
+
+// PublicKey returns the key tokens are signed with.
+func (j *JWTIssuer) PublicKey() *rsa.PublicKey {
+	return &j.signingKey().PublicKey
+}
+
+// Mint returns a JWT carrying claims, with iss, iat and exp added
+// unless claims sets them.
+func (j *JWTIssuer) Mint(claims map[string]any) string {
+	now := time.Now()
+	payload := map[string]any{
+		"iat": now.Unix(),
+		"exp": now.Add(j.ttl()).Unix(),
+	}
+	if issuer := j.issuer(); issuer != "" {
+		payload["iss"] = issuer
+	}
+	for name, value := range claims {
+		payload[name] = value
+	}
+
+	header := map[string]any{"alg": "RS256", "typ": "JWT", "kid": j.keyID()}
+	token := encodeJWTPart(header) + "." + encodeJWTPart(payload)
+	digest := sha256.Sum256([]byte(token))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, j.signingKey(), crypto.SHA256, digest[:])
+	if err != nil {
+		panic(fmt.Sprintf("failed to sign JWT: %v", err))
+	}
+	return token + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// OAuth2Token mints a JWT for a token issued by an OAuth2 fake, whose
+// subject is its user or else its client, for use as OAuth2.NewToken.
+func (j *JWTIssuer) OAuth2Token(token OAuth2Token) string {
+	subject := token.Username
+	if subject == "" {
+		subject = token.ClientID
+	}
+	claims := map[string]any{
+		"sub":       subject,
+		"client_id": token.ClientID,
+		"exp":       token.ExpiresAt.Unix(),
+	}
+	if token.Scope != "" {
+		claims["scope"] = token.Scope
+	}
+	return j.Mint(claims)
+}
+
+func (j *JWTIssuer) serveJWKS(c *gin.Context) {
+	key := j.PublicKey()
+	c.JSON(http.StatusOK, jwks{Keys: []jwk{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: j.keyID(),
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+}
+
+// signingKey generates the keypair the first time it is needed.
+func (j *JWTIssuer) signingKey() *rsa.PrivateKey {
+	j.once.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(fmt.Sprintf("failed to generate JWT signing key: %v", err))
+		}
+		j.key = key
+	})
+	return j.key
+}
+
+func (j *JWTIssuer) issuer() string {
+	if j.Issuer == "" && j.f != nil {
+		return j.f.BaseURL()
+	}
+	return j.Issuer
+}
+
+func (j *JWTIssuer) keyID() string {
+	if j.KeyID == "" {
+		return "fake"
+	}
+	return j.KeyID
+}
+
+func (j *JWTIssuer) ttl() time.Duration {
+	if j.TTL == 0 {
+		return time.Hour
+	}
+	return j.TTL
+}
+
+func encodeJWTPart(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,1110 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Kubernetes fakes the subset of a Kubernetes API server that clients
+// built on client-go rely on: discovery, and get, list, watch, create,
+// update, patch and delete on a configurable set of resource types, so
+// operators can be integration tested without running a real control
+// plane. Objects are stored as JSON and aren't validated beyond their
+// metadata, and namespaces needn't exist to hold objects. Deletes honour
+// finalizers, setting deletionTimestamp until they're removed, and
+// deleting a namespace deletes everything in it.
+type Kubernetes struct {
+	// Resources are the resource types served, and
+	// DefaultKubernetesResources if it is empty.
+	Resources []KubernetesResource
+	// Version is reported by /version, and defaults to "v1.29.0".
+	Version string
+
+	mutex    sync.Mutex
+	objects  map[*KubernetesResource]map[string]map[string]any
+	revision int64
+	events   []kubernetesEvent
+	watchers map[*kubernetesWatcher]bool
+}
+
+// KubernetesResource is a resource type served by Kubernetes.
+type KubernetesResource struct {
+	// Group is the API group, empty for the core group.
+	Group   string
+	Version string
+	Kind    string
+	// Resource is the plural name used in paths, and defaults to the
+	// lowercased Kind with an s added.
+	Resource   string
+	Namespaced bool
+	// Status is set for resource types with a status subresource,
+	// whose status is then only changed through the subresource.
+	Status bool
+}
+
+// DefaultKubernetesResources are the resource types Kubernetes serves
+// unless it is given others: a few core types, Deployments, and the
+// Leases used for leader election.
+var DefaultKubernetesResources = []KubernetesResource{
+	{Version: "v1", Kind: "Namespace", Status: true},
+	{Version: "v1", Kind: "Pod", Namespaced: true, Status: true},
+	{Version: "v1", Kind: "Service", Namespaced: true, Status: true},
+	{Version: "v1", Kind: "ConfigMap", Namespaced: true},
+	{Version: "v1", Kind: "Secret", Namespaced: true},
+	{Version: "v1", Kind: "ServiceAccount", Namespaced: true},
+	{Version: "v1", Kind: "Event", Namespaced: true},
+	{Group: "apps", Version: "v1", Kind: "Deployment", Namespaced: true, Status: true},
+	{Group: "coordination.k8s.io", Version: "v1", Kind: "Lease", Namespaced: true},
+}
+
+const (
+	kubernetesPatchJSON      = "application/json-patch+json"
+	kubernetesPatchMerge     = "application/merge-patch+json"
+	kubernetesPatchStrategic = "application/strategic-merge-patch+json"
+	kubernetesPatchApply     = "application/apply-patch+yaml"
+)
+
+type kubernetesEvent struct {
+	revision int64
+	Type     string         `json:"type"`
+	Object   map[string]any `json:"object"`
+	resource *KubernetesResource
+}
+
+type kubernetesWatcher struct {
+	resource  *KubernetesResource
+	namespace string
+	selector  func(obj map[string]any) bool
+	events    chan kubernetesEvent
+}
+
+// kubernetesError is answered as a Kubernetes Status.
+type kubernetesError struct {
+	code    int
+	reason  string
+	message string
+}
+
+func (e *kubernetesError) Error() string {
+	return e.message
+}
+
+// AddKubernetes registers the API server's endpoints for k. Clients
+// discover the API and then call only what they need, so the endpoints
+// are Optional.
+func (f *FakeService) AddKubernetes(k *Kubernetes) {
+	k.mutex.Lock()
+	k.init()
+	k.mutex.Unlock()
+
+	serve := func(c *gin.Context) { k.serve(f, c) }
+	for _, path := range []string{"/version", "/api", "/api/*path", "/apis", "/apis/*path"} {
+		f.AddEndpoint(&Endpoint{Path: path, Handler: serve, Optional: true})
+	}
+}
+
+// init applies k's defaults, so objects can be put before the API
+// server is added to a service. The caller holds the mutex.
+func (k *Kubernetes) init() {
+	if k.objects != nil {
+		return
+	}
+	if len(k.Resources) == 0 {
+		k.Resources = append([]KubernetesResource(nil), DefaultKubernetesResources...)
+	}
+	for i := range k.Resources {
+		if k.Resources[i].Resource == "" {
+			k.Resources[i].Resource = strings.ToLower(k.Resources[i].Kind) + "s"
+		}
+	}
+	if k.Version == "" {
+		k.Version = "v1.29.0"
+	}
+	k.objects = map[*KubernetesResource]map[string]map[string]any{}
+	k.watchers = map[*kubernetesWatcher]bool{}
+}
+
+// Get returns a copy of the named object of a resource type, given by
+// its plural name, qualified by its group if that's ambiguous, such as
+// "deployments.apps". Namespace is empty for cluster-scoped types.
+func (k *Kubernetes) Get(resource, namespace, name string) (map[string]any, bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.init()
+	res := k.resourceNamed(resource)
+	if res == nil {
+		return nil, false
+	}
+	obj, ok := k.objects[res][namespace+"/"+name]
+	if !ok {
+		return nil, false
+	}
+	return kubernetesCopy(obj), true
+}
+
+// List returns copies of the objects of a resource type in a namespace,
+// or in every namespace if it is empty, ordered by namespace and name.
+func (k *Kubernetes) List(resource, namespace string) []map[string]any {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.init()
+	res := k.resourceNamed(resource)
+	if res == nil {
+		return nil
+	}
+	return k.list(res, namespace, nil)
+}
+
+// Put creates an object, or replaces the object with its name, as
+// though a client had, so watchers see the change. It is served as the
+// type matching its apiVersion and kind.
+func (k *Kubernetes) Put(obj map[string]any) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.init()
+	obj = kubernetesCopy(obj)
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	var res *KubernetesResource
+	for i := range k.Resources {
+		if k.Resources[i].groupVersion() == apiVersion && k.Resources[i].Kind == kind {
+			res = &k.Resources[i]
+		}
+	}
+	if res == nil {
+		return fmt.Errorf("no resource type for %s %s", apiVersion, kind)
+	}
+	namespace, name := kubernetesName(obj)
+	if _, ok := k.objects[res][namespace+"/"+name]; ok {
+		kubernetesMetadata(obj)["resourceVersion"] = nil
+		_, err := k.update(res, namespace, name, "", obj)
+		return err
+	}
+	_, err := k.create(res, namespace, obj)
+	return err
+}
+
+// Delete deletes an object as though a client had, returning false if
+// there was no such object. An object with finalizers is only marked
+// for deletion.
+func (k *Kubernetes) Delete(resource, namespace, name string) bool {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.init()
+	res := k.resourceNamed(resource)
+	if res == nil {
+		return false
+	}
+	_, err := k.delete(res, namespace, name)
+	return err == nil
+}
+
+func (r *KubernetesResource) groupVersion() string {
+	if r.Group == "" {
+		return r.Version
+	}
+	return r.Group + "/" + r.Version
+}
+
+// resourceNamed finds a resource type by its plural name, optionally
+// qualified by its group.
+func (k *Kubernetes) resourceNamed(name string) *KubernetesResource {
+	plural, group, qualified := strings.Cut(name, ".")
+	for i := range k.Resources {
+		if k.Resources[i].Resource == plural && (!qualified || k.Resources[i].Group == group) {
+			return &k.Resources[i]
+		}
+	}
+	return nil
+}
+
+func (k *Kubernetes) resource(group, version, plural string) *KubernetesResource {
+	for i := range k.Resources {
+		r := &k.Resources[i]
+		if r.Group == group && r.Version == version && r.Resource == plural {
+			return r
+		}
+	}
+	return nil
+}
+
+func (k *Kubernetes) serve(f *FakeService, c *gin.Context) {
+	segments := strings.Split(strings.Trim(c.Request.URL.Path, "/"), "/")
+	var group, version string
+	var rest []string
+	switch {
+	case segments[0] == "version":
+		major, minor, _ := strings.Cut(strings.TrimPrefix(k.Version, "v"), ".")
+		minor, _, _ = strings.Cut(minor, ".")
+		c.JSON(http.StatusOK, gin.H{"major": major, "minor": minor, "gitVersion": k.Version, "platform": "linux/amd64"})
+		return
+	case len(segments) == 1 && segments[0] == "api":
+		c.JSON(http.StatusOK, gin.H{
+			"kind":     "APIVersions",
+			"versions": []string{"v1"},
+			"serverAddressByClientCIDRs": []gin.H{
+				{"clientCIDR": "0.0.0.0/0", "serverAddress": c.Request.Host},
+			},
+		})
+		return
+	case len(segments) == 1:
+		c.JSON(http.StatusOK, gin.H{"kind": "APIGroupList", "apiVersion": "v1", "groups": k.groups()})
+		return
+	case segments[0] == "api":
+		version, rest = segments[1], segments[2:]
+	case len(segments) == 2:
+		for _, g := range k.groups() {
+			if g["name"] == segments[1] {
+				g["kind"], g["apiVersion"] = "APIGroup", "v1"
+				c.JSON(http.StatusOK, g)
+				return
+			}
+		}
+		k.fail(c, &kubernetesError{http.StatusNotFound, "NotFound", "the server could not find the requested resource"})
+		return
+	default:
+		group, version, rest = segments[1], segments[2], segments[3:]
+	}
+
+	if len(rest) == 0 {
+		k.discover(c, group, version)
+		return
+	}
+	var namespace string
+	if len(rest) >= 3 && rest[0] == "namespaces" {
+		if res := k.resource(group, version, rest[2]); res != nil && res.Namespaced {
+			namespace, rest = rest[1], rest[2:]
+		}
+	}
+	res := k.resource(group, version, rest[0])
+	if res == nil || len(rest) > 3 {
+		k.fail(c, &kubernetesError{http.StatusNotFound, "NotFound", "the server could not find the requested resource"})
+		return
+	}
+	var name, subresource string
+	if len(rest) > 1 {
+		name = rest[1]
+	}
+	if len(rest) > 2 {
+		subresource = rest[2]
+		if subresource != "status" || !res.Status {
+			k.fail(c, &kubernetesError{http.StatusNotFound, "NotFound", "the server could not find the requested resource"})
+			return
+		}
+	}
+
+	if name == "" && c.Request.Method == http.MethodGet && (c.Query("watch") == "true" || c.Query("watch") == "1") {
+		k.watch(f, c, res, namespace)
+		return
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	obj, status, err := k.do(c, res, namespace, name, subresource)
+	if err != nil {
+		k.fail(c, err)
+		return
+	}
+	c.JSON(status, obj)
+}
+
+// do carries out a request other than a watch.
+func (k *Kubernetes) do(c *gin.Context, res *KubernetesResource, namespace, name, subresource string) (any, int, error) {
+	if name == "" {
+		selector, err := kubernetesSelector(c.Query("labelSelector"), c.Query("fieldSelector"))
+		if err != nil {
+			return nil, 0, err
+		}
+		switch c.Request.Method {
+		case http.MethodGet:
+			items := k.list(res, namespace, selector)
+			if items == nil {
+				items = []map[string]any{}
+			}
+			return gin.H{
+				"apiVersion": res.groupVersion(),
+				"kind":       res.Kind + "List",
+				"metadata":   gin.H{"resourceVersion": strconv.FormatInt(k.revision, 10)},
+				"items":      items,
+			}, http.StatusOK, nil
+		case http.MethodPost:
+			obj, err := kubernetesBody(c)
+			if err != nil {
+				return nil, 0, err
+			}
+			obj, err = k.create(res, namespace, obj)
+			return obj, http.StatusCreated, err
+		case http.MethodDelete:
+			for _, obj := range k.list(res, namespace, selector) {
+				ns, name := kubernetesName(obj)
+				k.delete(res, ns, name)
+			}
+			return kubernetesStatus(http.StatusOK, "", ""), http.StatusOK, nil
+		}
+		return nil, 0, &kubernetesError{http.StatusMethodNotAllowed, "MethodNotAllowed", "the server does not allow this method on the requested resource"}
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet:
+		obj, ok := k.objects[res][namespace+"/"+name]
+		if !ok {
+			return nil, 0, kubernetesNotFound(res, name)
+		}
+		return obj, http.StatusOK, nil
+	case http.MethodPut:
+		obj, err := kubernetesBody(c)
+		if err != nil {
+			return nil, 0, err
+		}
+		obj, err = k.update(res, namespace, name, subresource, obj)
+		return obj, http.StatusOK, err
+	case http.MethodPatch:
+		obj, created, err := k.patch(c, res, namespace, name, subresource)
+		if created {
+			return obj, http.StatusCreated, err
+		}
+		return obj, http.StatusOK, err
+	case http.MethodDelete:
+		if subresource == "" {
+			obj, err := k.delete(res, namespace, name)
+			return obj, http.StatusOK, err
+		}
+	}
+	return nil, 0, &kubernetesError{http.StatusMethodNotAllowed, "MethodNotAllowed", "the server does not allow this method on the requested resource"}
+}
+
+func (k *Kubernetes) discover(c *gin.Context, group, version string) {
+	var resources []gin.H
+	for _, r := range k.Resources {
+		if r.Group != group || r.Version != version {
+			continue
+		}
+		resources = append(resources, gin.H{
+			"name":         r.Resource,
+			"singularName": strings.ToLower(r.Kind),
+			"namespaced":   r.Namespaced,
+			"kind":         r.Kind,
+			"verbs":        []string{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"},
+		})
+		if r.Status {
+			resources = append(resources, gin.H{
+				"name":       r.Resource + "/status",
+				"namespaced": r.Namespaced,
+				"kind":       r.Kind,
+				"verbs":      []string{"get", "patch", "update"},
+			})
+		}
+	}
+	if resources == nil {
+		k.fail(c, &kubernetesError{http.StatusNotFound, "NotFound", "the server could not find the requested resource"})
+		return
+	}
+	groupVersion := version
+	if group != "" {
+		groupVersion = group + "/" + version
+	}
+	c.JSON(http.StatusOK, gin.H{"kind": "APIResourceList", "apiVersion": "v1", "groupVersion": groupVersion, "resources": resources})
+}
+
+// groups describes the named API groups served, in the order their
+// resources were given.
+func (k *Kubernetes) groups() []gin.H {
+	var groups []gin.H
+	index := map[string]gin.H{}
+	for _, r := range k.Resources {
+		if r.Group == "" {
+			continue
+		}
+		version := gin.H{"groupVersion": r.groupVersion(), "version": r.Version}
+		g, ok := index[r.Group]
+		if !ok {
+			g = gin.H{"name": r.Group, "versions": []gin.H{}, "preferredVersion": version}
+			index[r.Group] = g
+			groups = append(groups, g)
+		}
+		versions := g["versions"].([]gin.H)
+		seen := false
+		for _, v := range versions {
+			seen = seen || v["version"] == r.Version
+		}
+		if !seen {
+			g["versions"] = append(versions, version)
+		}
+	}
+	if groups == nil {
+		groups = []gin.H{}
+	}
+	return groups
+}
+
+func (k *Kubernetes) list(res *KubernetesResource, namespace string, selector func(map[string]any) bool) []map[string]any {
+	var items []map[string]any
+	for _, key := range sortedKeys(k.objects[res]) {
+		obj := k.objects[res][key]
+		if ns, _ := kubernetesName(obj); namespace != "" && ns != namespace {
+			continue
+		}
+		if selector != nil && !selector(obj) {
+			continue
+		}
+		items = append(items, kubernetesCopy(obj))
+	}
+	return items
+}
+
+func (k *Kubernetes) create(res *KubernetesResource, namespace string, obj map[string]any) (map[string]any, error) {
+	meta := kubernetesMetadata(obj)
+	if ns, _ := meta["namespace"].(string); ns != "" && namespace != "" && ns != namespace {
+		return nil, &kubernetesError{http.StatusBadRequest, "BadRequest", "the namespace of the provided object does not match the namespace sent on the request"}
+	} else if namespace == "" {
+		namespace = ns
+	}
+	if res.Namespaced && namespace == "" {
+		return nil, &kubernetesError{http.StatusBadRequest, "BadRequest", "an empty namespace may not be set during creation"}
+	}
+	name, _ := meta["name"].(string)
+	if generateName, _ := meta["generateName"].(string); name == "" && generateName != "" {
+		name = generateName + randomToken()[:5]
+	}
+	if name == "" {
+		return nil, &kubernetesError{http.StatusUnprocessableEntity, "Invalid", "metadata.name: Required value: name or generateName is required"}
+	}
+	if !res.Namespaced {
+		namespace = ""
+	}
+	key := namespace + "/" + name
+	if _, ok := k.objects[res][key]; ok {
+		return nil, &kubernetesError{http.StatusConflict, "AlreadyExists", fmt.Sprintf("%s %q already exists", res.Resource, name)}
+	}
+
+	obj["apiVersion"], obj["kind"] = res.groupVersion(), res.Kind
+	meta["name"] = name
+	if namespace != "" {
+		meta["namespace"] = namespace
+	}
+	meta["uid"] = newUUID()
+	meta["creationTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	meta["generation"] = 1
+	delete(meta, "deletionTimestamp")
+	return k.store(res, key, obj, "ADDED"), nil
+}
+
+func (k *Kubernetes) update(res *KubernetesResource, namespace, name, subresource string, obj map[string]any) (map[string]any, error) {
+	key := namespace + "/" + name
+	existing, ok := k.objects[res][key]
+	if !ok {
+		return nil, kubernetesNotFound(res, name)
+	}
+	meta := kubernetesMetadata(obj)
+	if got, _ := meta["name"].(string); got != "" && got != name {
+		return nil, &kubernetesError{http.StatusBadRequest, "BadRequest", "the name of the object does not match the name on the URL"}
+	}
+	oldMeta := kubernetesMetadata(existing)
+	if version, _ := meta["resourceVersion"].(string); version != "" && version != oldMeta["resourceVersion"] {
+		return nil, &kubernetesError{http.StatusConflict, "Conflict", fmt.Sprintf("Operation cannot be fulfilled on %s %q: the object has been modified; please apply your changes to the latest version and try again", res.Resource, name)}
+	}
+
+	if subresource == "status" {
+		status := obj["status"]
+		obj = kubernetesCopy(existing)
+		obj["status"] = status
+		meta = kubernetesMetadata(obj)
+	} else if res.Status {
+		obj["status"] = existing["status"]
+	}
+	obj["apiVersion"], obj["kind"] = res.groupVersion(), res.Kind
+	meta["name"] = name
+	if namespace != "" {
+		meta["namespace"] = namespace
+	}
+	for _, field := range []string{"uid", "creationTimestamp", "deletionTimestamp", "generation"} {
+		meta[field] = oldMeta[field]
+	}
+	if meta["deletionTimestamp"] == nil {
+		delete(meta, "deletionTimestamp")
+	}
+	if !reflect.DeepEqual(obj["spec"], existing["spec"]) {
+		generation, _ := oldMeta["generation"].(float64)
+		meta["generation"] = generation + 1
+	}
+
+	if meta["deletionTimestamp"] != nil && len(kubernetesFinalizers(obj)) == 0 {
+		k.remove(res, key, obj)
+		return obj, nil
+	}
+	return k.store(res, key, obj, "MODIFIED"), nil
+}
+
+// patch applies a JSON patch, a JSON merge patch, a strategic merge
+// patch or a server-side apply to an object. Strategic merge patches
+// and applies are treated as merge patches, so replace lists rather than
+// merging them, and an apply creates the object if it doesn't exist.
+func (k *Kubernetes) patch(c *gin.Context, res *KubernetesResource, namespace, name, subresource string) (map[string]any, bool, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, false, &kubernetesError{http.StatusBadRequest, "BadRequest", err.Error()}
+	}
+	existing, ok := k.objects[res][namespace+"/"+name]
+	contentType := c.ContentType()
+	if !ok && contentType != kubernetesPatchApply {
+		return nil, false, kubernetesNotFound(res, name)
+	}
+
+	var patched any
+	switch contentType {
+	case kubernetesPatchJSON:
+		var ops []jsonPatchOperation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, false, &kubernetesError{http.StatusBadRequest, "BadRequest", err.Error()}
+		}
+		patched, err = applyJSONPatch(kubernetesCopy(existing), ops)
+		if err != nil {
+			return nil, false, &kubernetesError{http.StatusUnprocessableEntity, "Invalid", err.Error()}
+		}
+	case kubernetesPatchMerge, kubernetesPatchStrategic, kubernetesPatchApply:
+		var patch map[string]any
+		if err := yaml.Unmarshal(body, &patch); err != nil {
+			return nil, false, &kubernetesError{http.StatusBadRequest, "BadRequest", err.Error()}
+		}
+		patch = kubernetesCopy(patch)
+		if !ok {
+			kubernetesMetadata(patch)["name"] = name
+			obj, err := k.create(res, namespace, patch)
+			return obj, true, err
+		}
+		patched = mergePatch(kubernetesCopy(existing), patch)
+	default:
+		return nil, false, &kubernetesError{http.StatusUnsupportedMediaType, "UnsupportedMediaType", fmt.Sprintf("the body of the request was in an unknown format - accepted media types include: %s, %s, %s, %s", kubernetesPatchJSON, kubernetesPatchMerge, kubernetesPatchStrategic, kubernetesPatchApply)}
+	}
+	obj, isObject := patched.(map[string]any)
+	if !isObject {
+		return nil, false, &kubernetesError{http.StatusUnprocessableEntity, "Invalid", "the patch did not produce an object"}
+	}
+	obj, err = k.update(res, namespace, name, subresource, obj)
+	return obj, false, err
+}
+
+func (k *Kubernetes) delete(res *KubernetesResource, namespace, name string) (map[string]any, error) {
+	key := namespace + "/" + name
+	existing, ok := k.objects[res][key]
+	if !ok {
+		return nil, kubernetesNotFound(res, name)
+	}
+	obj := kubernetesCopy(existing)
+	if len(kubernetesFinalizers(obj)) > 0 {
+		meta := kubernetesMetadata(obj)
+		if meta["deletionTimestamp"] != nil {
+			return obj, nil
+		}
+		meta["deletionTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+		return k.store(res, key, obj, "MODIFIED"), nil
+	}
+	k.remove(res, key, obj)
+	return obj, nil
+}
+
+// store saves an object with the next resourceVersion, telling watchers
+// about it, and returns a copy.
+func (k *Kubernetes) store(res *KubernetesResource, key string, obj map[string]any, event string) map[string]any {
+	k.revision++
+	kubernetesMetadata(obj)["resourceVersion"] = strconv.FormatInt(k.revision, 10)
+	obj = kubernetesCopy(obj)
+	if k.objects[res] == nil {
+		k.objects[res] = map[string]map[string]any{}
+	}
+	k.objects[res][key] = obj
+	k.notify(res, event, obj)
+	return kubernetesCopy(obj)
+}
+
+// remove deletes an object, along with everything in it if it is a
+// namespace.
+func (k *Kubernetes) remove(res *KubernetesResource, key string, obj map[string]any) {
+	k.revision++
+	kubernetesMetadata(obj)["resourceVersion"] = strconv.FormatInt(k.revision, 10)
+	delete(k.objects[res], key)
+	k.notify(res, "DELETED", kubernetesCopy(obj))
+
+	if res.Group != "" || res.Kind != "Namespace" {
+		return
+	}
+	_, namespace := kubernetesName(obj)
+	for i := range k.Resources {
+		other := &k.Resources[i]
+		if !other.Namespaced {
+			continue
+		}
+		for _, item := range k.list(other, namespace, nil) {
+			_, name := kubernetesName(item)
+			k.remove(other, namespace+"/"+name, item)
+		}
+	}
+}
+
+func (k *Kubernetes) notify(res *KubernetesResource, eventType string, obj map[string]any) {
+	event := kubernetesEvent{revision: k.revision, Type: eventType, Object: obj, resource: res}
+	k.events = append(k.events, event)
+	for w := range k.watchers {
+		if !w.wants(event) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			// The watcher has fallen behind, so its watch is ended
+			// and the client will list and watch again.
+			close(w.events)
+			delete(k.watchers, w)
+		}
+	}
+}
+
+func (w *kubernetesWatcher) wants(event kubernetesEvent) bool {
+	if event.resource != w.resource {
+		return false
+	}
+	if ns, _ := kubernetesName(event.Object); w.namespace != "" && ns != w.namespace {
+		return false
+	}
+	return w.selector == nil || w.selector(event.Object)
+}
+
+// watch streams changes to a resource type as newline delimited watch
+// events. Watches from resourceVersion 0, or none, start with an ADDED
+// event for every existing object, and others replay the changes since.
+func (k *Kubernetes) watch(f *FakeService, c *gin.Context, res *KubernetesResource, namespace string) {
+	selector, err := kubernetesSelector(c.Query("labelSelector"), c.Query("fieldSelector"))
+	if err != nil {
+		k.fail(c, err)
+		return
+	}
+	var timeout <-chan time.Time
+	if seconds, err := strconv.Atoi(c.Query("timeoutSeconds")); err == nil && seconds > 0 {
+		timeout = time.After(time.Duration(seconds) * time.Second)
+	}
+
+	w := &kubernetesWatcher{resource: res, namespace: namespace, selector: selector, events: make(chan kubernetesEvent, 1024)}
+	k.mutex.Lock()
+	var initial []kubernetesEvent
+	if from := c.Query("resourceVersion"); from == "" || from == "0" {
+		for _, obj := range k.list(res, namespace, selector) {
+			initial = append(initial, kubernetesEvent{Type: "ADDED", Object: obj})
+		}
+	} else {
+		revision, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			k.mutex.Unlock()
+			k.fail(c, &kubernetesError{http.StatusBadRequest, "BadRequest", "invalid resourceVersion " + from})
+			return
+		}
+		for _, event := range k.events {
+			if event.revision > revision && w.wants(event) {
+				initial = append(initial, event)
+			}
+		}
+	}
+	k.watchers[w] = true
+	k.mutex.Unlock()
+	defer func() {
+		k.mutex.Lock()
+		delete(k.watchers, w)
+		k.mutex.Unlock()
+	}()
+
+	c.Header("Content-Type", "application/json")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, event := range initial {
+		enc.Encode(event)
+	}
+	c.Writer.Flush()
+	for {
+		select {
+		case event, ok := <-w.events:
+			if !ok {
+				return
+			}
+			enc.Encode(event)
+			c.Writer.Flush()
+		case <-timeout:
+			return
+		case <-c.Request.Context().Done():
+			return
+		case <-f.closing:
+			return
+		}
+	}
+}
+
+func (k *Kubernetes) fail(c *gin.Context, err error) {
+	e, ok := err.(*kubernetesError)
+	if !ok {
+		e = &kubernetesError{http.StatusInternalServerError, "InternalError", err.Error()}
+	}
+	c.JSON(e.code, kubernetesStatus(e.code, e.reason, e.message))
+}
+
+func kubernetesStatus(code int, reason, message string) gin.H {
+	status := gin.H{"kind": "Status", "apiVersion": "v1", "metadata": gin.H{}, "status": "Success", "code": code}
+	if reason != "" {
+		status["status"], status["reason"], status["message"] = "Failure", reason, message
+	}
+	return status
+}
+
+func kubernetesNotFound(res *KubernetesResource, name string) error {
+	resource := res.Resource
+	if res.Group != "" {
+		resource += "." + res.Group
+	}
+	return &kubernetesError{http.StatusNotFound, "NotFound", fmt.Sprintf("%s %q not found", resource, name)}
+}
+
+func kubernetesBody(c *gin.Context) (map[string]any, error) {
+	var obj map[string]any
+	if err := json.NewDecoder(c.Request.Body).Decode(&obj); err != nil {
+		return nil, &kubernetesError{http.StatusBadRequest, "BadRequest", "invalid object: " + err.Error()}
+	}
+	return obj, nil
+}
+
+// kubernetesCopy deep copies an object through JSON, which also
+// normalises numbers to float64 as they are when decoded from requests.
+func kubernetesCopy(obj map[string]any) map[string]any {
+	data, _ := json.Marshal(obj)
+	var copied map[string]any
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// kubernetesMetadata returns an object's metadata, adding it if it has
+// none.
+func kubernetesMetadata(obj map[string]any) map[string]any {
+	meta, ok := obj["metadata"].(map[string]any)
+	if !ok {
+		meta = map[string]any{}
+		obj["metadata"] = meta
+	}
+	return meta
+}
+
+func kubernetesName(obj map[string]any) (namespace, name string) {
+	meta, _ := obj["metadata"].(map[string]any)
+	namespace, _ = meta["namespace"].(string)
+	name, _ = meta["name"].(string)
+	return namespace, name
+}
+
+func kubernetesFinalizers(obj map[string]any) []any {
+	finalizers, _ := kubernetesMetadata(obj)["finalizers"].([]any)
+	return finalizers
+}
+
+var kubernetesSetRequirement = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\((.*)\)$`)
+
+// kubernetesSelector parses label and field selectors into a function
+// reporting whether an object is selected, or nil if both are empty.
+// Field selectors can only select on metadata.name and
+// metadata.namespace.
+func kubernetesSelector(labelSelector, fieldSelector string) (func(map[string]any) bool, error) {
+	if labelSelector == "" && fieldSelector == "" {
+		return nil, nil
+	}
+	labels, err := parseSelector(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseSelector(fieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	return func(obj map[string]any) bool {
+		meta := kubernetesMetadata(obj)
+		objLabels := map[string]string{}
+		if l, ok := meta["labels"].(map[string]any); ok {
+			for name, value := range l {
+				objLabels[name], _ = value.(string)
+			}
+		}
+		namespace, name := kubernetesName(obj)
+		return labels(objLabels) && fields(map[string]string{"metadata.name": name, "metadata.namespace": namespace})
+	}, nil
+}
+
+// parseSelector parses a selector's comma separated requirements: key,
+// !key, key=value, key==value, key!=value, key in (a,b) and
+// key notin (a,b).
+func parseSelector(selector string) (func(map[string]string) bool, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, ch := range selector {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	terms = append(terms, selector[start:])
+
+	var requirements []func(map[string]string) bool
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if m := kubernetesSetRequirement.FindStringSubmatch(term); m != nil {
+			key, in := m[1], m[2] == "in"
+			values := strings.Split(m[3], ",")
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			requirements = append(requirements, func(labels map[string]string) bool {
+				value, ok := labels[key]
+				return (ok && containsString(values, value)) == in
+			})
+			continue
+		}
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			requirements = append(requirements, func(labels map[string]string) bool {
+				got, ok := labels[key]
+				return !ok || got != value
+			})
+			continue
+		}
+		if key, value, ok := strings.Cut(term, "="); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(strings.TrimPrefix(value, "="))
+			requirements = append(requirements, func(labels map[string]string) bool {
+				got, ok := labels[key]
+				return ok && got == value
+			})
+			continue
+		}
+		if strings.ContainsAny(term, " ()<>") {
+			return nil, &kubernetesError{http.StatusBadRequest, "BadRequest", fmt.Sprintf("unable to parse requirement %q", term)}
+		}
+		key, negated := strings.CutPrefix(term, "!")
+		requirements = append(requirements, func(labels map[string]string) bool {
+			_, ok := labels[key]
+			return ok != negated
+		})
+	}
+	return func(labels map[string]string) bool {
+		for _, requirement := range requirements {
+			if !requirement(labels) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// mergePatch applies an RFC 7386 JSON merge patch.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], value)
+		}
+	}
+	return t
+}
+
+type jsonPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from"`
+	Value any    `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON patch.
+func applyJSONPatch(doc any, ops []jsonPatchOperation) (any, error) {
+	var err error
+	for _, op := range ops {
+		switch op.Op {
+		case "add":
+			doc, err = jsonPointerAdd(doc, op.Path, op.Value)
+		case "remove":
+			doc, _, err = jsonPointerRemove(doc, op.Path)
+		case "replace":
+			if doc, _, err = jsonPointerRemove(doc, op.Path); err == nil {
+				doc, err = jsonPointerAdd(doc, op.Path, op.Value)
+			}
+		case "move":
+			var value any
+			if doc, value, err = jsonPointerRemove(doc, op.From); err == nil {
+				doc, err = jsonPointerAdd(doc, op.Path, value)
+			}
+		case "copy":
+			var value any
+			if value, err = jsonPointerGet(doc, op.From); err == nil {
+				doc, err = jsonPointerAdd(doc, op.Path, value)
+			}
+		case "test":
+			var value any
+			if value, err = jsonPointerGet(doc, op.Path); err == nil && !reflect.DeepEqual(value, op.Value) {
+				err = fmt.Errorf("test of %s failed", op.Path)
+			}
+		default:
+			err = fmt.Errorf("unsupported JSON patch operation %q", op.Op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func jsonPointerTokens(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func jsonPointerGet(doc any, pointer string) (any, error) {
+	tokens, err := jsonPointerTokens(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch v := doc.(type) {
+		case map[string]any:
+			var ok bool
+			if doc, ok = v[token]; !ok {
+				return nil, fmt.Errorf("%s does not exist", pointer)
+			}
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("%s does not exist", pointer)
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// jsonPointerUpdate replaces the container holding the value a pointer
+// refers to with the result of update, given the container and the
+// pointer's last token.
+func jsonPointerUpdate(doc any, pointer string, update func(parent any, token string) (any, error)) (any, error) {
+	tokens, err := jsonPointerTokens(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return update(nil, "")
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := jsonPointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := update(parent, tokens[len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	if parentPointer == "" {
+		return updated, nil
+	}
+	// Slices may have been reallocated, so the parent is put back.
+	return jsonPointerUpdate(doc, parentPointer, func(grandparent any, token string) (any, error) {
+		return jsonPointerSet(grandparent, token, updated)
+	})
+}
+
+func jsonPointerSet(parent any, token string, value any) (any, error) {
+	switch v := parent.(type) {
+	case map[string]any:
+		v[token] = value
+		return v, nil
+	case []any:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, fmt.Errorf("index %s is out of range", token)
+		}
+		v[i] = value
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot set %s on a value that isn't an object or array", token)
+}
+
+func jsonPointerAdd(doc any, pointer string, value any) (any, error) {
+	return jsonPointerUpdate(doc, pointer, func(parent any, token string) (any, error) {
+		switch v := parent.(type) {
+		case nil:
+			return value, nil
+		case map[string]any:
+			v[token] = value
+			return v, nil
+		case []any:
+			if token == "-" {
+				return append(v, value), nil
+			}
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i > len(v) {
+				return nil, fmt.Errorf("index %s is out of range", token)
+			}
+			v = append(v[:i], append([]any{value}, v[i:]...)...)
+			return v, nil
+		}
+		return nil, fmt.Errorf("cannot add %s to a value that isn't an object or array", pointer)
+	})
+}
+
+func jsonPointerRemove(doc any, pointer string) (any, any, error) {
+	removed, err := jsonPointerGet(doc, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err = jsonPointerUpdate(doc, pointer, func(parent any, token string) (any, error) {
+		switch v := parent.(type) {
+		case nil:
+			return nil, nil
+		case map[string]any:
+			delete(v, token)
+			return v, nil
+		case []any:
+			i, _ := strconv.Atoi(token)
+			return append(v[:i:i], v[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove %s", pointer)
+	})
+	return doc, removed, err
+}
@@ -0,0 +1,188 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestKubernetesPutBeforeAdding(t *testing.T) {
+	k := &Kubernetes{}
+	if err := k.Put(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings", "namespace": "default"},
+		"data":       map[string]any{"mode": "test"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.Get("configmaps", "default", "settings"); !ok {
+		t.Fatal("Get didn't find the ConfigMap put before AddKubernetes")
+	}
+
+	f := New()
+	f.AddKubernetes(k)
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	resp, err := http.Get(f.BaseURL() + "/api/v1/namespaces/default/configmaps/settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var obj struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || obj.Data["mode"] != "test" {
+		t.Errorf("GET configmap = %d %v, want 200 with mode test", resp.StatusCode, obj.Data)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{"add field", `{"a": 1}`, `[{"op": "add", "path": "/b", "value": 2}]`, `{"a": 1, "b": 2}`, false},
+		{"add to array", `{"a": [1, 3]}`, `[{"op": "add", "path": "/a/1", "value": 2}, {"op": "add", "path": "/a/-", "value": 4}]`, `{"a": [1, 2, 3, 4]}`, false},
+		{"remove", `{"a": 1, "b": [1, 2]}`, `[{"op": "remove", "path": "/a"}, {"op": "remove", "path": "/b/0"}]`, `{"b": [2]}`, false},
+		{"replace", `{"a": {"b": 1}}`, `[{"op": "replace", "path": "/a/b", "value": "x"}]`, `{"a": {"b": "x"}}`, false},
+		{"move", `{"a": 1}`, `[{"op": "move", "from": "/a", "path": "/b"}]`, `{"b": 1}`, false},
+		{"copy", `{"a": [1]}`, `[{"op": "copy", "from": "/a", "path": "/b"}]`, `{"a": [1], "b": [1]}`, false},
+		{"escaped pointer", `{"metadata": {"labels": {}}}`, `[{"op": "add", "path": "/metadata/labels/app.io~1name", "value": "x"}]`, `{"metadata": {"labels": {"app.io/name": "x"}}}`, false},
+		{"test passes", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 1}, {"op": "add", "path": "/b", "value": 2}]`, `{"a": 1, "b": 2}`, false},
+		{"test fails", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}]`, "", true},
+		{"replace missing", `{"a": 1}`, `[{"op": "replace", "path": "/b", "value": 2}]`, "", true},
+		{"index out of range", `{"a": [1]}`, `[{"op": "add", "path": "/a/5", "value": 2}]`, "", true},
+		{"bad pointer", `{"a": 1}`, `[{"op": "add", "path": "a", "value": 2}]`, "", true},
+		{"unknown op", `{"a": 1}`, `[{"op": "frobnicate", "path": "/a"}]`, "", true},
+	}
+	for _, tt := range tests {
+		var doc any
+		var ops []jsonPatchOperation
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.patch), &ops); err != nil {
+			t.Fatal(err)
+		}
+		got, err := applyJSONPatch(doc, ops)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		var want any
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a": 1, "b": 2}`, `{"b": 3, "c": 4}`, `{"a": 1, "b": 3, "c": 4}`},
+		{`{"a": 1, "b": 2}`, `{"a": null}`, `{"b": 2}`},
+		{`{"a": {"b": 1, "c": 2}}`, `{"a": {"c": null, "d": 3}}`, `{"a": {"b": 1, "d": 3}}`},
+		{`{"a": [1, 2]}`, `{"a": [3]}`, `{"a": [3]}`},
+		{`{"a": 1}`, `{"a": {"b": 1}}`, `{"a": {"b": 1}}`},
+		{`{"a": 1}`, `[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		var target, patch, want any
+		for _, v := range []struct {
+			src string
+			dst *any
+		}{{tt.target, &target}, {tt.patch, &patch}, {tt.want, &want}} {
+			if err := json.Unmarshal([]byte(v.src), v.dst); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend", "env": "prod"}
+	tests := []struct {
+		selector string
+		want     bool
+		wantErr  bool
+	}{
+		{selector: "", want: true},
+		{selector: "app=web", want: true},
+		{selector: "app==web", want: true},
+		{selector: "app = web", want: true},
+		{selector: "app=api", want: false},
+		{selector: "app!=api", want: true},
+		{selector: "missing!=x", want: true},
+		{selector: "app", want: true},
+		{selector: "!app", want: false},
+		{selector: "!missing", want: true},
+		{selector: "env in (prod, staging)", want: true},
+		{selector: "env notin (prod,staging)", want: false},
+		{selector: "missing notin (a)", want: true},
+		{selector: "app=web,tier in (frontend),!canary", want: true},
+		{selector: "app=web,tier=backend", want: false},
+		{selector: "app (web)", wantErr: true},
+		{selector: "app > 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		match, err := parseSelector(tt.selector)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelector(%q) error = %v, wantErr %v", tt.selector, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && match(labels) != tt.want {
+			t.Errorf("parseSelector(%q) matched = %v, want %v", tt.selector, !tt.want, tt.want)
+		}
+	}
+}
+
+func TestKubernetesSelector(t *testing.T) {
+	obj := map[string]any{"metadata": map[string]any{
+		"name":      "web-1",
+		"namespace": "shop",
+		"labels":    map[string]any{"app": "web"},
+	}}
+	tests := []struct {
+		labels, fields string
+		want           bool
+	}{
+		{"app=web", "", true},
+		{"app=api", "", false},
+		{"", "metadata.name=web-1", true},
+		{"", "metadata.namespace!=shop", false},
+		{"app=web", "metadata.name=web-1,metadata.namespace=shop", true},
+	}
+	for _, tt := range tests {
+		match, err := kubernetesSelector(tt.labels, tt.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := match(obj); got != tt.want {
+			t.Errorf("labels %q fields %q matched = %v, want %v", tt.labels, tt.fields, got, tt.want)
+		}
+	}
+	if match, err := kubernetesSelector("", ""); match != nil || err != nil {
+		t.Errorf("empty selectors = %v, %v, want no selector", match != nil, err)
+	}
+}
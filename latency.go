@@ -0,0 +1,41 @@
+package fake
+
+import (
+	"context"
+	"time"
+)
+
+// LatencyRamp describes a delay that grows with every successive call
+// made to an endpoint. The first call is delayed by Start, each call after
+// that by a further Step, and the delay never exceeds Max (if set).
+type LatencyRamp struct {
+	Start time.Duration
+	Step  time.Duration
+	Max   time.Duration
+}
+
+// delayFor returns the delay to apply to the nth (1-indexed) call.
+func (r *LatencyRamp) delayFor(call int) time.Duration {
+	if call < 1 {
+		call = 1
+	}
+	d := r.Start + r.Step*time.Duration(call-1)
+	if r.Max > 0 && d > r.Max {
+		d = r.Max
+	}
+	return d
+}
+
+// sleep blocks for the given duration, returning early if the
+// request context is cancelled because the client has given up.
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,96 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LinkPagination serves Items as a JSON array a page at a time, GitHub
+// style, with the page chosen by the page and per_page query
+// parameters and the way to the others given in a Link header with
+// first, prev, next and last relations, for clients that follow Link
+// headers rather than cursors in the body.
+type LinkPagination struct {
+	Path  string
+	Items []any
+	// PerPage is the page size when the request doesn't ask for one,
+	// 30 by default, and MaxPerPage caps what it may ask for, 100 by
+	// default.
+	PerPage    int
+	MaxPerPage int
+}
+
+// AddLinkPagination registers the endpoint serving p.
+func (f *FakeService) AddLinkPagination(p *LinkPagination) {
+	if p.PerPage == 0 {
+		p.PerPage = 30
+	}
+	if p.MaxPerPage == 0 {
+		p.MaxPerPage = 100
+	}
+	f.AddEndpoint(&Endpoint{
+		Path:    p.Path,
+		Method:  http.MethodGet,
+		Handler: p.serve,
+	})
+}
+
+func (p *LinkPagination) serve(c *gin.Context) {
+	// Like GitHub, anything that isn't a positive number is ignored.
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(c.Query("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = p.PerPage
+	}
+	if perPage > p.MaxPerPage {
+		perPage = p.MaxPerPage
+	}
+
+	last := (len(p.Items) + perPage - 1) / perPage
+	if last == 0 {
+		last = 1
+	}
+	items := []any{}
+	if start := (page - 1) * perPage; start < len(p.Items) {
+		items = p.Items[start:min(start+perPage, len(p.Items))]
+	}
+
+	var links []string
+	link := func(rel string, page int) {
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", pageURL(c.Request, page, perPage), rel))
+	}
+	if page > 1 {
+		link("prev", min(page-1, last))
+		link("first", 1)
+	}
+	if page < last {
+		link("next", page+1)
+		link("last", last)
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// pageURL returns the absolute URL of r with its page and per_page
+// parameters replaced.
+func pageURL(r *http.Request, page, perPage int) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,192 @@
+package fake
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics fakes a Prometheus scrape target, serving the values it is
+// given in the text exposition format. Values can be changed mid-test
+// to see how scrapers react.
+type Metrics struct {
+	// Path defaults to "/metrics".
+	Path string
+
+	mutex    sync.Mutex
+	families map[string]*metricFamily
+	order    []string
+}
+
+type metricFamily struct {
+	help       string
+	metricType string
+	samples    map[string]*metricSample
+	// keys holds the samples' keys in the order they were first set, so
+	// that histogram buckets can be listed in order.
+	keys []string
+}
+
+type metricSample struct {
+	name   string
+	labels string
+	value  float64
+}
+
+// AddMetrics registers the endpoint serving m. Scrapers may not run
+// during a test, so it is Optional.
+func (f *FakeService) AddMetrics(m *Metrics) {
+	if m.Path == "" {
+		m.Path = "/metrics"
+	}
+	f.AddEndpoint(&Endpoint{
+		Path:     m.Path,
+		Method:   http.MethodGet,
+		Handler:  m.serve,
+		Optional: true,
+	})
+}
+
+// Describe sets the help text and type, such as "counter", "gauge" or
+// "histogram", of the named metric. The samples of a histogram or
+// summary, such as name_bucket and name_sum, are listed under it.
+func (m *Metrics) Describe(name, metricType, help string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	family := m.family(name)
+	family.metricType = metricType
+	family.help = help
+}
+
+// Set sets the value of the named metric with the given labels, passed
+// as name and value pairs.
+func (m *Metrics) Set(name string, value float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sample(name, labels).value = value
+}
+
+// Add adds delta to the value of the named metric with the given
+// labels, passed as name and value pairs, for counting up counters.
+func (m *Metrics) Add(name string, delta float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sample(name, labels).value += delta
+}
+
+// Delete removes the named metric with the given labels, so scrapers
+// see it disappear.
+func (m *Metrics) Delete(name string, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	family := m.familyOf(name)
+	key := name + formatLabels(labels)
+	delete(family.samples, key)
+	for i, k := range family.keys {
+		if k == key {
+			family.keys = append(family.keys[:i], family.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *Metrics) family(name string) *metricFamily {
+	if m.families == nil {
+		m.families = map[string]*metricFamily{}
+	}
+	family, ok := m.families[name]
+	if !ok {
+		family = &metricFamily{samples: map[string]*metricSample{}}
+		m.families[name] = family
+		m.order = append(m.order, name)
+	}
+	return family
+}
+
+// familyOf finds the family a sample belongs to, which for histograms
+// and summaries is named without the sample's suffix.
+func (m *Metrics) familyOf(name string) *metricFamily {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		if family, ok := m.families[base]; ok && (family.metricType == "histogram" || family.metricType == "summary") {
+			return family
+		}
+	}
+	return m.family(name)
+}
+
+func (m *Metrics) sample(name string, labels []string) *metricSample {
+	family := m.familyOf(name)
+	key := name + formatLabels(labels)
+	sample, ok := family.samples[key]
+	if !ok {
+		sample = &metricSample{name: name, labels: formatLabels(labels)}
+		family.samples[key] = sample
+		family.keys = append(family.keys, key)
+	}
+	return sample
+}
+
+// formatLabels formats name and value pairs as Prometheus labels,
+// sorted by name so the same labels always format the same way.
+func formatLabels(labels []string) string {
+	if len(labels)%2 != 0 {
+		panic(fmt.Sprintf("metric labels must be name and value pairs, got %q", labels))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabelValue(labels[i+1])+`"`)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (m *Metrics) serve(c *gin.Context) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var b strings.Builder
+	for _, name := range m.order {
+		family := m.families[name]
+		if family.help != "" {
+			help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(family.help)
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, help)
+		}
+		if family.metricType != "" {
+			fmt.Fprintf(&b, "# TYPE %s %s\n", name, family.metricType)
+		}
+		for _, key := range family.keys {
+			sample := family.samples[key]
+			fmt.Fprintf(&b, "%s%s %s\n", sample.name, sample.labels, formatMetricValue(sample.value))
+		}
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
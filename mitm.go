@@ -0,0 +1,183 @@
+package fake
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// WithProxyMITM lets the service intercept HTTPS requests sent through
+// it as a forward proxy, see ProxyURL. CONNECT tunnels are answered by
+// the service itself, presenting certificates for the requested host
+// signed by a certificate authority generated for the test run, so
+// requests can be matched against endpoints as if sent in the clear.
+// The code under test must trust ProxyCertPool or ProxyCAPEM.
+func WithProxyMITM() Option {
+	return func(f *FakeService) {
+		f.mitm = &mitmProxy{certs: map[string]*tls.Certificate{}}
+	}
+}
+
+// ProxyCertPool returns a pool holding the authority behind the
+// certificates presented to intercepted HTTPS requests, once the
+// service is running with WithProxyMITM, and nil otherwise.
+func (f *FakeService) ProxyCertPool() *x509.CertPool {
+	if f.mitm == nil || f.mitm.ca == nil {
+		return nil
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(f.mitm.ca.cert)
+	return pool
+}
+
+// ProxyCAPEM returns the PEM encoded authority behind the certificates
+// presented to intercepted HTTPS requests, for code under test that
+// loads its trusted certificates from a file.
+func (f *FakeService) ProxyCAPEM() []byte {
+	if f.mitm == nil || f.mitm.ca == nil {
+		return nil
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.mitm.ca.cert.Raw})
+}
+
+// mitmProxy terminates the TLS connections tunnelled through the
+// service and serves the requests inside them.
+type mitmProxy struct {
+	ca       *certAuthority
+	server   *http.Server
+	listener *connListener
+
+	mutex sync.Mutex
+	certs map[string]*tls.Certificate
+}
+
+// start creates the proxy's authority and begins serving handler to
+// intercepted connections.
+func (m *mitmProxy) start(handler http.Handler) error {
+	ca, err := newCertAuthority("fakes proxy CA")
+	if err != nil {
+		return err
+	}
+	m.ca = ca
+	m.listener = newConnListener()
+	m.server = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Scheme = "https"
+			r.URL.Host = r.Host
+			handler.ServeHTTP(w, r)
+		}),
+	}
+	go m.server.Serve(m.listener)
+	return nil
+}
+
+func (m *mitmProxy) close() {
+	if m.server != nil {
+		m.server.Close()
+	}
+}
+
+// wrap answers CONNECT requests, passing everything else to next.
+func (m *mitmProxy) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			next.ServeHTTP(w, r)
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Printf("CONNECT: %s - intercepting\n", r.Host)
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+			conn.Close()
+			return
+		}
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		m.listener.push(tls.Server(conn, &tls.Config{
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				name := hello.ServerName
+				if name == "" {
+					name = host
+				}
+				return m.certificate(name)
+			},
+		}))
+	})
+}
+
+// certificate returns the certificate presented for host, issuing it
+// on first use.
+func (m *mitmProxy) certificate(host string) (*tls.Certificate, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if cert, ok := m.certs[host]; ok {
+		return cert, nil
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"fakes"}, CommonName: host},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	cert, err := m.ca.issue(template)
+	if err != nil {
+		return nil, err
+	}
+	m.certs[host] = &cert
+	return &cert, nil
+}
+
+// connListener is a net.Listener accepting connections handed to it by
+// push, rather than from the network.
+type connListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConnListener() *connListener {
+	return &connListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *connListener) push(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
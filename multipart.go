@@ -0,0 +1,109 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// UploadedFile describes a file part of a multipart request.
+type UploadedFile struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Size        int64
+	// SHA256 is the hex encoded digest of the file's content.
+	SHA256 string
+}
+
+// Files parses the request's multipart body and returns every file
+// part it contains. Non-file form fields are skipped.
+func (r RecordedRequest) Files() ([]UploadedFile, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Content-Type: %w", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("request is %s, not multipart", mediaType)
+	}
+
+	var files []UploadedFile
+	reader := multipart.NewReader(bytes.NewReader(r.Body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart body: %w", err)
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		hash := sha256.New()
+		size, err := io.Copy(hash, part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part %s: %w", part.FormName(), err)
+		}
+		files = append(files, UploadedFile{
+			Field:       part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        size,
+			SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+}
+
+// matches reports whether f matches every non-zero field of expected.
+func (f UploadedFile) matches(expected UploadedFile) bool {
+	switch {
+	case expected.Field != "" && expected.Field != f.Field:
+		return false
+	case expected.Filename != "" && expected.Filename != f.Filename:
+		return false
+	case expected.ContentType != "" && expected.ContentType != f.ContentType:
+		return false
+	case expected.Size != 0 && expected.Size != f.Size:
+		return false
+	case expected.SHA256 != "" && !strings.EqualFold(expected.SHA256, f.SHA256):
+		return false
+	}
+	return true
+}
+
+// ReceivedFile checks that the route was sent a multipart upload with a
+// file matching every non-zero field of expected.
+func (c *EndpointCheck) ReceivedFile(expected UploadedFile) error {
+	var received []string
+	for _, r := range c.f.requestsFor(c.path, c.method) {
+		files, err := r.Files()
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.matches(expected) {
+				return nil
+			}
+			received = append(received, fmt.Sprintf("%+v", file))
+		}
+	}
+	if len(received) == 0 {
+		return fmt.Errorf("expected %s to receive file %+v but no files were uploaded", c, expected)
+	}
+	return fmt.Errorf("expected %s to receive file %+v, received:\n  %s", c, expected, strings.Join(received, "\n  "))
+}
+
+// ReceivedFile asserts that the route was sent a multipart upload with
+// a file matching every non-zero field of expected.
+func (v *EndpointVerifier) ReceivedFile(expected UploadedFile) bool {
+	v.t.Helper()
+	return v.assert(v.check.ReceivedFile(expected))
+}
@@ -0,0 +1,289 @@
+package fake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuth2 grant types served by the OAuth2 preset.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantPassword          = "password"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuth2 fakes an OAuth2 token endpoint, issuing tokens for the
+// client_credentials, password and refresh_token grants and rejecting
+// requests as an authorization server would, with invalid_client,
+// invalid_grant, unsupported_grant_type and invalid_request errors.
+type OAuth2 struct {
+	// Path defaults to "/oauth/token".
+	Path string
+	// Clients maps client IDs to their secrets. Any client is accepted
+	// if it is empty.
+	Clients map[string]string
+	// Users maps usernames to passwords for the password grant. Any
+	// user is accepted if it is empty.
+	Users map[string]string
+	// Scope is granted when a request doesn't ask for one.
+	Scope string
+	// TokenTTL is how long access tokens last, an hour by default.
+	TokenTTL time.Duration
+	// RefreshTokenTTL is how long refresh tokens last. They never expire
+	// if it is zero.
+	RefreshTokenTTL time.Duration
+	// NewToken, if set, generates access tokens, such as JWTs, from the
+	// token being issued. They are random by default.
+	NewToken func(OAuth2Token) string
+
+	// idToken, set by AddOIDC, mints the ID token returned alongside
+	// tokens granted the openid scope.
+	idToken func(OAuth2Token) string
+
+	mutex   sync.Mutex
+	tokens  map[string]*OAuth2Token
+	refresh map[string]*OAuth2Token
+	issued  []OAuth2Token
+}
+
+// OAuth2Token is a token issued by an OAuth2 fake.
+type OAuth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	Scope        string
+	GrantType    string
+	ClientID     string
+	// Username is the resource owner for the password grant, and for
+	// tokens refreshed from one.
+	Username         string
+	ExpiresAt        time.Time
+	RefreshExpiresAt time.Time
+}
+
+type oauth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type oauth2Error struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// AddOAuth2 registers the token endpoint for o. Tests don't always need
+// a token, so the endpoint is Optional.
+func (f *FakeService) AddOAuth2(o *OAuth2) {
+	if o.Path == "" {
+		o.Path = "/oauth/token"
+	}
+	if o.TokenTTL == 0 {
+		o.TokenTTL = time.Hour
+	}
+	f.AddEndpoint(&Endpoint{
+		Path:     o.Path,
+		Method:   http.MethodPost,
+		Handler:  o.serve,
+		Optional: true,
+	})
+}
+
+// Tokens returns every token issued, oldest first.
+func (o *OAuth2) Tokens() []OAuth2Token {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]OAuth2Token(nil), o.issued...)
+}
+
+// Token returns the unexpired token issued with the given access token,
+// for endpoints the fake protects to check the bearer tokens presented.
+func (o *OAuth2) Token(accessToken string) (OAuth2Token, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	token, ok := o.tokens[accessToken]
+	if !ok || time.Now().After(token.ExpiresAt) {
+		return OAuth2Token{}, false
+	}
+	return *token, true
+}
+
+// Authorized reports whether r carries an unexpired bearer token the
+// fake issued. It can be used as an Endpoint's Matcher.
+func (o *OAuth2) Authorized(r *http.Request) bool {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	_, ok := o.Token(token)
+	return ok
+}
+
+// ExpireTokens expires every access token issued so far, to test how
+// clients recover from a 401.
+func (o *OAuth2) ExpireTokens() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, token := range o.tokens {
+		token.ExpiresAt = time.Now()
+	}
+}
+
+// ExpireRefreshTokens expires every refresh token issued so far, so
+// refreshing fails with invalid_grant and clients must log in again.
+func (o *OAuth2) ExpireRefreshTokens() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, token := range o.refresh {
+		token.RefreshExpiresAt = time.Now()
+	}
+}
+
+func (o *OAuth2) serve(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	clientID, secret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		o.fail(c, http.StatusUnauthorized, "invalid_client", "client authentication is required")
+		return
+	}
+	if want, ok := o.Clients[clientID]; len(o.Clients) > 0 && (!ok || want != secret) {
+		o.fail(c, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+
+	token := OAuth2Token{
+		GrantType: c.PostForm("grant_type"),
+		ClientID:  clientID,
+		Scope:     c.PostForm("scope"),
+	}
+	switch token.GrantType {
+	case GrantClientCredentials:
+	case GrantPassword:
+		username, password := c.PostForm("username"), c.PostForm("password")
+		if username == "" {
+			o.fail(c, http.StatusBadRequest, "invalid_request", "username is required")
+			return
+		}
+		if want, ok := o.Users[username]; len(o.Users) > 0 && (!ok || want != password) {
+			o.fail(c, http.StatusBadRequest, "invalid_grant", "wrong username or password")
+			return
+		}
+		token.Username = username
+	case GrantRefreshToken:
+		previous, ok := o.redeem(c.PostForm("refresh_token"), clientID)
+		if !ok {
+			o.fail(c, http.StatusBadRequest, "invalid_grant", "refresh token is invalid or expired")
+			return
+		}
+		token.Username = previous.Username
+		if token.Scope == "" {
+			token.Scope = previous.Scope
+		}
+	case "":
+		o.fail(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		o.fail(c, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	if token.Scope == "" {
+		token.Scope = o.Scope
+	}
+
+	token = o.issue(token)
+	response := oauth2TokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(o.TokenTTL.Seconds()),
+		RefreshToken: token.RefreshToken,
+		Scope:        token.Scope,
+	}
+	if o.idToken != nil && hasScope(token.Scope, "openid") {
+		response.IDToken = o.idToken(token)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// redeem spends a refresh token issued to clientID, as refresh tokens
+// are rotated on every use.
+func (o *OAuth2) redeem(refreshToken, clientID string) (OAuth2Token, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	token, ok := o.refresh[refreshToken]
+	if !ok || token.ClientID != clientID {
+		return OAuth2Token{}, false
+	}
+	delete(o.refresh, refreshToken)
+	if !token.RefreshExpiresAt.IsZero() && !time.Now().Before(token.RefreshExpiresAt) {
+		return OAuth2Token{}, false
+	}
+	return *token, true
+}
+
+// issue completes token with its expiry and tokens, and stores it.
+// Client credentials tokens come without a refresh token, as the client
+// can always ask for another.
+func (o *OAuth2) issue(token OAuth2Token) OAuth2Token {
+	now := time.Now()
+	token.ExpiresAt = now.Add(o.TokenTTL)
+	if token.GrantType != GrantClientCredentials {
+		token.RefreshToken = randomToken()
+		if o.RefreshTokenTTL > 0 {
+			token.RefreshExpiresAt = now.Add(o.RefreshTokenTTL)
+		}
+	}
+	if o.NewToken != nil {
+		token.AccessToken = o.NewToken(token)
+	} else {
+		token.AccessToken = randomToken()
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.tokens == nil {
+		o.tokens = map[string]*OAuth2Token{}
+		o.refresh = map[string]*OAuth2Token{}
+	}
+	stored := token
+	o.tokens[token.AccessToken] = &stored
+	if token.RefreshToken != "" {
+		o.refresh[token.RefreshToken] = &stored
+	}
+	o.issued = append(o.issued, token)
+	return token
+}
+
+func (o *OAuth2) fail(c *gin.Context, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+	}
+	c.JSON(status, oauth2Error{Error: code, ErrorDescription: description})
+}
+
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,419 @@
+package fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OCIRegistry fakes a container registry speaking the OCI distribution
+// API under /v2/, backed by memory: the version check, pulling and
+// pushing manifests by tag or digest, pulling blobs, uploading them
+// monolithically or in chunks, cross-repository mounts, tag listing and
+// deletes. Digests are verified, so tooling that pushes images can be
+// tested end to end without a real registry.
+type OCIRegistry struct {
+	mutex   sync.Mutex
+	blobs   map[string][]byte
+	repos   map[string]*ociRepository
+	uploads map[string]*ociUpload
+}
+
+type ociRepository struct {
+	blobs     map[string]bool
+	manifests map[string]ociManifest
+	tags      map[string]string
+}
+
+type ociManifest struct {
+	mediaType string
+	data      []byte
+}
+
+type ociUpload struct {
+	repo string
+	data []byte
+}
+
+type ociError struct {
+	status  int
+	code    string
+	message string
+}
+
+// AddOCIRegistry registers the /v2/ endpoints serving r. Registry
+// clients make different calls depending on what the registry already
+// holds, so the endpoint is Optional.
+func (f *FakeService) AddOCIRegistry(r *OCIRegistry) {
+	f.AddEndpoint(&Endpoint{Path: "/v2/*path", Handler: r.serve, Optional: true})
+}
+
+// PutBlob stores a blob in the named repository, for tests that pull,
+// and returns its digest.
+func (r *OCIRegistry) PutBlob(name string, data []byte) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	digest := ociDigest(data)
+	r.storeBlob(name, digest, data)
+	return digest
+}
+
+// PutManifest stores a manifest in the named repository, tagged with
+// reference unless it is empty, and returns its digest.
+func (r *OCIRegistry) PutManifest(name, reference, mediaType string, data []byte) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.storeManifest(name, reference, mediaType, data)
+}
+
+// Manifest returns the manifest stored in the named repository under a
+// tag or digest.
+func (r *OCIRegistry) Manifest(name, reference string) ([]byte, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	m, ok := r.manifest(name, reference)
+	return m.data, ok
+}
+
+// Blob returns the blob with the given digest.
+func (r *OCIRegistry) Blob(digest string) ([]byte, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	data, ok := r.blobs[digest]
+	return data, ok
+}
+
+// Tags returns the tags in the named repository, sorted.
+func (r *OCIRegistry) Tags(name string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	repo, ok := r.repos[name]
+	if !ok {
+		return nil
+	}
+	return sortedKeys(repo.tags)
+}
+
+func (r *OCIRegistry) init() {
+	if r.repos == nil {
+		r.repos = map[string]*ociRepository{}
+		r.blobs = map[string][]byte{}
+		r.uploads = map[string]*ociUpload{}
+	}
+}
+
+func (r *OCIRegistry) repo(name string) *ociRepository {
+	r.init()
+	repo, ok := r.repos[name]
+	if !ok {
+		repo = &ociRepository{blobs: map[string]bool{}, manifests: map[string]ociManifest{}, tags: map[string]string{}}
+		r.repos[name] = repo
+	}
+	return repo
+}
+
+func (r *OCIRegistry) storeBlob(name, digest string, data []byte) {
+	r.repo(name).blobs[digest] = true
+	r.blobs[digest] = data
+}
+
+func (r *OCIRegistry) storeManifest(name, reference, mediaType string, data []byte) string {
+	repo := r.repo(name)
+	digest := ociDigest(data)
+	repo.manifests[digest] = ociManifest{mediaType: mediaType, data: data}
+	if reference != "" && !strings.Contains(reference, ":") {
+		repo.tags[reference] = digest
+	}
+	return digest
+}
+
+func (r *OCIRegistry) manifest(name, reference string) (ociManifest, bool) {
+	repo, ok := r.repos[name]
+	if !ok {
+		return ociManifest{}, false
+	}
+	if digest, ok := repo.tags[reference]; ok {
+		reference = digest
+	}
+	m, ok := repo.manifests[reference]
+	return m, ok
+}
+
+func (r *OCIRegistry) serve(c *gin.Context) {
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	path := strings.Trim(c.Param("path"), "/")
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.init()
+
+	var err *ociError
+	switch {
+	case path == "":
+		c.JSON(http.StatusOK, gin.H{})
+	case path == "_catalog":
+		c.JSON(http.StatusOK, gin.H{"repositories": sortedKeys(r.repos)})
+	case strings.HasSuffix(path, "/tags/list"):
+		err = r.listTags(c, strings.TrimSuffix(path, "/tags/list"))
+	case strings.Contains(path, "/manifests/"):
+		i := strings.LastIndex(path, "/manifests/")
+		err = r.serveManifest(c, path[:i], path[i+len("/manifests/"):])
+	case strings.HasSuffix(path, "/blobs/uploads"):
+		err = r.startUpload(c, strings.TrimSuffix(path, "/blobs/uploads"))
+	case strings.Contains(path, "/blobs/uploads/"):
+		i := strings.LastIndex(path, "/blobs/uploads/")
+		err = r.serveUpload(c, path[:i], path[i+len("/blobs/uploads/"):])
+	case strings.Contains(path, "/blobs/"):
+		i := strings.LastIndex(path, "/blobs/")
+		err = r.serveBlob(c, path[:i], path[i+len("/blobs/"):])
+	default:
+		err = &ociError{http.StatusNotFound, "NAME_UNKNOWN", "unknown registry path"}
+	}
+	if err != nil {
+		c.JSON(err.status, gin.H{"errors": []gin.H{{"code": err.code, "message": err.message}}})
+	}
+}
+
+func (r *OCIRegistry) listTags(c *gin.Context, name string) *ociError {
+	if c.Request.Method != http.MethodGet {
+		return ociUnsupported()
+	}
+	repo, ok := r.repos[name]
+	if !ok {
+		return &ociError{http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry"}
+	}
+	c.JSON(http.StatusOK, gin.H{"name": name, "tags": sortedKeys(repo.tags)})
+	return nil
+}
+
+func (r *OCIRegistry) serveManifest(c *gin.Context, name, reference string) *ociError {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		m, ok := r.manifest(name, reference)
+		if !ok {
+			return &ociError{http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown"}
+		}
+		c.Header("Docker-Content-Digest", ociDigest(m.data))
+		c.Header("Content-Length", strconv.Itoa(len(m.data)))
+		if c.Request.Method == http.MethodHead {
+			c.Header("Content-Type", m.mediaType)
+			c.Status(http.StatusOK)
+			return nil
+		}
+		c.Data(http.StatusOK, m.mediaType, m.data)
+	case http.MethodPut:
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return &ociError{http.StatusBadRequest, "MANIFEST_INVALID", err.Error()}
+		}
+		if strings.Contains(reference, ":") && reference != ociDigest(data) {
+			return &ociError{http.StatusBadRequest, "DIGEST_INVALID", "provided digest did not match uploaded content"}
+		}
+		if err := r.checkManifest(name, data); err != nil {
+			return err
+		}
+		mediaType := c.ContentType()
+		if mediaType == "" {
+			mediaType = "application/vnd.oci.image.manifest.v1+json"
+		}
+		digest := r.storeManifest(name, reference, mediaType, data)
+		c.Header("Location", "/v2/"+name+"/manifests/"+digest)
+		c.Header("Docker-Content-Digest", digest)
+		c.Status(http.StatusCreated)
+	case http.MethodDelete:
+		repo, ok := r.repos[name]
+		if !ok {
+			return &ociError{http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown"}
+		}
+		if _, ok := repo.tags[reference]; ok {
+			delete(repo.tags, reference)
+		} else if _, ok := repo.manifests[reference]; ok {
+			delete(repo.manifests, reference)
+			for tag, digest := range repo.tags {
+				if digest == reference {
+					delete(repo.tags, tag)
+				}
+			}
+		} else {
+			return &ociError{http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown"}
+		}
+		c.Status(http.StatusAccepted)
+	default:
+		return ociUnsupported()
+	}
+	return nil
+}
+
+// checkManifest rejects a manifest that isn't JSON, or that refers to
+// blobs or manifests the repository doesn't hold.
+func (r *OCIRegistry) checkManifest(name string, data []byte) *ociError {
+	type descriptor struct {
+		Digest string `json:"digest"`
+	}
+	var manifest struct {
+		Config    *descriptor  `json:"config"`
+		Layers    []descriptor `json:"layers"`
+		Manifests []descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return &ociError{http.StatusBadRequest, "MANIFEST_INVALID", "manifest invalid: " + err.Error()}
+	}
+	repo := r.repo(name)
+	blobs := manifest.Layers
+	if manifest.Config != nil {
+		blobs = append(blobs, *manifest.Config)
+	}
+	for _, blob := range blobs {
+		if !repo.blobs[blob.Digest] {
+			return &ociError{http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", "blob unknown to registry: " + blob.Digest}
+		}
+	}
+	for _, m := range manifest.Manifests {
+		if _, ok := repo.manifests[m.Digest]; !ok {
+			return &ociError{http.StatusBadRequest, "MANIFEST_UNKNOWN", "manifest unknown: " + m.Digest}
+		}
+	}
+	return nil
+}
+
+func (r *OCIRegistry) serveBlob(c *gin.Context, name, digest string) *ociError {
+	repo, ok := r.repos[name]
+	if !ok || !repo.blobs[digest] {
+		return &ociError{http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry"}
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		data := r.blobs[digest]
+		c.Header("Docker-Content-Digest", digest)
+		c.Header("Content-Length", strconv.Itoa(len(data)))
+		if c.Request.Method == http.MethodHead {
+			c.Header("Content-Type", "application/octet-stream")
+			c.Status(http.StatusOK)
+			return nil
+		}
+		c.Data(http.StatusOK, "application/octet-stream", data)
+	case http.MethodDelete:
+		delete(repo.blobs, digest)
+		c.Status(http.StatusAccepted)
+	default:
+		return ociUnsupported()
+	}
+	return nil
+}
+
+// startUpload begins a blob upload, or completes one in a single
+// request if it carries the digest, or mounts a blob from another
+// repository if it asks to and the blob is there.
+func (r *OCIRegistry) startUpload(c *gin.Context, name string) *ociError {
+	if c.Request.Method != http.MethodPost {
+		return ociUnsupported()
+	}
+	if mount, from := c.Query("mount"), c.Query("from"); mount != "" {
+		if repo, ok := r.repos[from]; ok && repo.blobs[mount] {
+			r.storeBlob(name, mount, r.blobs[mount])
+			c.Header("Location", "/v2/"+name+"/blobs/"+mount)
+			c.Header("Docker-Content-Digest", mount)
+			c.Status(http.StatusCreated)
+			return nil
+		}
+	}
+	if digest := c.Query("digest"); digest != "" {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return &ociError{http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error()}
+		}
+		return r.completeUpload(c, name, digest, data)
+	}
+
+	id := newUUID()
+	r.uploads[id] = &ociUpload{repo: name}
+	r.repo(name)
+	c.Header("Location", "/v2/"+name+"/blobs/uploads/"+id)
+	c.Header("Docker-Upload-UUID", id)
+	c.Header("Range", "0-0")
+	c.Status(http.StatusAccepted)
+	return nil
+}
+
+func (r *OCIRegistry) serveUpload(c *gin.Context, name, id string) *ociError {
+	upload, ok := r.uploads[id]
+	if !ok || upload.repo != name {
+		return &ociError{http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "blob upload unknown to registry"}
+	}
+	location := "/v2/" + name + "/blobs/uploads/" + id
+	status := http.StatusAccepted
+	switch c.Request.Method {
+	case http.MethodGet:
+		status = http.StatusNoContent
+	case http.MethodPatch, http.MethodPut:
+		chunk, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return &ociError{http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error()}
+		}
+		if contentRange := c.GetHeader("Content-Range"); contentRange != "" {
+			start, _, _ := strings.Cut(strings.TrimPrefix(contentRange, "bytes="), "-")
+			if offset, err := strconv.Atoi(start); err != nil || offset != len(upload.data) {
+				c.Header("Location", location)
+				c.Header("Range", ociRange(len(upload.data)))
+				c.Status(http.StatusRequestedRangeNotSatisfiable)
+				return nil
+			}
+		}
+		upload.data = append(upload.data, chunk...)
+		if c.Request.Method == http.MethodPut {
+			digest := c.Query("digest")
+			if digest == "" {
+				return &ociError{http.StatusBadRequest, "DIGEST_INVALID", "digest is required to complete an upload"}
+			}
+			delete(r.uploads, id)
+			return r.completeUpload(c, name, digest, upload.data)
+		}
+	case http.MethodDelete:
+		delete(r.uploads, id)
+		c.Status(http.StatusNoContent)
+		return nil
+	default:
+		return ociUnsupported()
+	}
+	c.Header("Location", location)
+	c.Header("Docker-Upload-UUID", id)
+	c.Header("Range", ociRange(len(upload.data)))
+	c.Status(status)
+	return nil
+}
+
+func (r *OCIRegistry) completeUpload(c *gin.Context, name, digest string, data []byte) *ociError {
+	if ociDigest(data) != digest {
+		return &ociError{http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("provided digest %s did not match uploaded content", digest)}
+	}
+	r.storeBlob(name, digest, data)
+	c.Header("Location", "/v2/"+name+"/blobs/"+digest)
+	c.Header("Docker-Content-Digest", digest)
+	c.Status(http.StatusCreated)
+	return nil
+}
+
+func ociUnsupported() *ociError {
+	return &ociError{http.StatusMethodNotAllowed, "UNSUPPORTED", "the operation is unsupported"}
+}
+
+// ociRange is the Range header describing an upload of size bytes so
+// far, which is inclusive and so "0-0" while it is empty.
+func ociRange(size int) string {
+	if size == 0 {
+		return "0-0"
+	}
+	return "0-" + strconv.Itoa(size-1)
+}
+
+func ociDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
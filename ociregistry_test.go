@@ -0,0 +1,117 @@
+package fake
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func ociRequest(t *testing.T, method, url string, body []byte, header map[string]string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestOCIRegistryPushThenPull(t *testing.T) {
+	r := &OCIRegistry{}
+	f := New()
+	f.AddOCIRegistry(r)
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	layer := []byte("layer contents")
+	layerDigest := ociDigest(layer)
+	config := []byte(`{}`)
+	configDigest := ociDigest(config)
+
+	// The layer is pushed in two chunks and the config monolithically.
+	resp := ociRequest(t, http.MethodPost, f.BaseURL()+"/v2/team/app/blobs/uploads/", nil, nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("starting upload: status %d", resp.StatusCode)
+	}
+	location := f.BaseURL() + resp.Header.Get("Location")
+	resp = ociRequest(t, http.MethodPatch, location, layer[:5], map[string]string{"Content-Range": "0-4"})
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Range") != "0-4" {
+		t.Fatalf("first chunk: status %d, range %q", resp.StatusCode, resp.Header.Get("Range"))
+	}
+	resp = ociRequest(t, http.MethodPut, location+"?digest="+layerDigest, layer[5:], map[string]string{"Content-Range": "5-13"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("completing upload: status %d", resp.StatusCode)
+	}
+	resp = ociRequest(t, http.MethodPost, f.BaseURL()+"/v2/team/app/blobs/uploads/?digest="+configDigest, config, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("monolithic upload: status %d", resp.StatusCode)
+	}
+
+	manifest := []byte(`{"schemaVersion":2,"config":{"digest":"` + configDigest + `"},"layers":[{"digest":"` + layerDigest + `"}]}`)
+	resp = ociRequest(t, http.MethodPut, f.BaseURL()+"/v2/team/app/manifests/v1", manifest, map[string]string{"Content-Type": "application/vnd.oci.image.manifest.v1+json"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("pushing manifest: status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Docker-Content-Digest"); got != ociDigest(manifest) {
+		t.Errorf("manifest digest = %s, want %s", got, ociDigest(manifest))
+	}
+
+	for path, want := range map[string][]byte{
+		"/v2/team/app/manifests/v1":                     manifest,
+		"/v2/team/app/manifests/" + ociDigest(manifest): manifest,
+		"/v2/team/app/blobs/" + layerDigest:             layer,
+		"/v2/team/app/blobs/" + configDigest:            config,
+	} {
+		resp := ociRequest(t, http.MethodGet, f.BaseURL()+path, nil, nil)
+		got, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(got, want) {
+			t.Errorf("GET %s = %d %q, want %q", path, resp.StatusCode, got, want)
+		}
+	}
+	if tags := r.Tags("team/app"); len(tags) != 1 || tags[0] != "v1" {
+		t.Errorf("Tags = %v, want [v1]", tags)
+	}
+}
+
+func TestOCIRegistryRejectsBadDigest(t *testing.T) {
+	r := &OCIRegistry{}
+	f := New()
+	f.AddOCIRegistry(r)
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	resp := ociRequest(t, http.MethodPost, f.BaseURL()+"/v2/app/blobs/uploads/?digest="+ociDigest([]byte("expected")), []byte("actual"), nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if body, _ := io.ReadAll(resp.Body); !bytes.Contains(body, []byte("DIGEST_INVALID")) {
+		t.Errorf("body = %s, want a DIGEST_INVALID error", body)
+	}
+	if _, ok := r.Blob(ociDigest([]byte("actual"))); ok {
+		t.Error("blob was stored despite the bad digest")
+	}
+}
+
+func TestOCIRegistryRejectsOutOfOrderChunk(t *testing.T) {
+	f := New()
+	f.AddOCIRegistry(&OCIRegistry{})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	resp := ociRequest(t, http.MethodPost, f.BaseURL()+"/v2/app/blobs/uploads/", nil, nil)
+	location := f.BaseURL() + resp.Header.Get("Location")
+	ociRequest(t, http.MethodPatch, location, []byte("12345"), map[string]string{"Content-Range": "0-4"})
+
+	resp = ociRequest(t, http.MethodPatch, location, []byte("67890"), map[string]string{"Content-Range": "10-14"})
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusRequestedRangeNotSatisfiable)
+	}
+	if got := resp.Header.Get("Range"); got != "0-4" {
+		t.Errorf("Range = %q, want 0-4", got)
+	}
+}
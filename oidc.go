@@ -0,0 +1,122 @@
+package fake
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDC fakes an OpenID Connect provider on top of the OAuth2 and
+// JWTIssuer presets. It serves a discovery document describing them and
+// a userinfo endpoint, mints access tokens as JWTs, and returns an ID
+// token alongside tokens granted the openid scope, so a client's whole
+// flow from discovery to token to userinfo runs against one service.
+type OIDC struct {
+	// OAuth2 issues the tokens, and a default one is used if it is nil.
+	OAuth2 *OAuth2
+	// JWT signs the tokens, and a default one is used if it is nil.
+	JWT *JWTIssuer
+	// UserInfoPath defaults to "/userinfo".
+	UserInfoPath string
+	// Claims maps subjects, the user of a token or else its client, to
+	// the claims returned by userinfo and added to their ID tokens.
+	Claims map[string]map[string]any
+}
+
+type oidcConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// AddOIDC registers the discovery and userinfo endpoints for o, along
+// with its OAuth2 and JWTIssuer. Like theirs, the endpoints are
+// Optional.
+func (f *FakeService) AddOIDC(o *OIDC) {
+	if o.OAuth2 == nil {
+		o.OAuth2 = &OAuth2{}
+	}
+	if o.JWT == nil {
+		o.JWT = &JWTIssuer{}
+	}
+	if o.UserInfoPath == "" {
+		o.UserInfoPath = "/userinfo"
+	}
+	if o.OAuth2.NewToken == nil {
+		o.OAuth2.NewToken = o.JWT.OAuth2Token
+	}
+	o.OAuth2.idToken = o.idToken
+	f.AddOAuth2(o.OAuth2)
+	f.AddJWTIssuer(o.JWT)
+
+	f.AddEndpoint(&Endpoint{
+		Path:     "/.well-known/openid-configuration",
+		Method:   http.MethodGet,
+		Handler:  func(c *gin.Context) { c.JSON(http.StatusOK, o.configuration(f)) },
+		Optional: true,
+	})
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		f.AddEndpoint(&Endpoint{
+			Path:     o.UserInfoPath,
+			Method:   method,
+			Handler:  o.serveUserInfo,
+			Optional: true,
+		})
+	}
+}
+
+func (o *OIDC) configuration(f *FakeService) oidcConfiguration {
+	base := f.BaseURL()
+	return oidcConfiguration{
+		Issuer:                            o.JWT.issuer(),
+		TokenEndpoint:                     base + o.OAuth2.Path,
+		UserInfoEndpoint:                  base + o.UserInfoPath,
+		JWKSURI:                           base + o.JWT.JWKSPath,
+		ResponseTypesSupported:            []string{"token", "id_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		GrantTypesSupported:               []string{GrantClientCredentials, GrantPassword, GrantRefreshToken},
+		ScopesSupported:                   []string{"openid", "profile", "email"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+	}
+}
+
+func (o *OIDC) serveUserInfo(c *gin.Context) {
+	scheme, accessToken, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+	token, ok := o.OAuth2.Token(accessToken)
+	if !strings.EqualFold(scheme, "Bearer") || !ok {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	c.JSON(http.StatusOK, o.claims(token))
+}
+
+// claims returns the claims about the subject of token.
+func (o *OIDC) claims(token OAuth2Token) map[string]any {
+	subject := token.Username
+	if subject == "" {
+		subject = token.ClientID
+	}
+	claims := map[string]any{}
+	for name, value := range o.Claims[subject] {
+		claims[name] = value
+	}
+	claims["sub"] = subject
+	return claims
+}
+
+func (o *OIDC) idToken(token OAuth2Token) string {
+	claims := o.claims(token)
+	claims["aud"] = token.ClientID
+	claims["exp"] = token.ExpiresAt.Unix()
+	return o.JWT.Mint(claims)
+}
@@ -0,0 +1,411 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI is an OpenAPI 3 document, holding the parts of the
+// specification used to fake the API it describes.
+type OpenAPI struct {
+	OpenAPI    string                      `json:"openapi" yaml:"openapi"`
+	Info       OpenAPIInfo                 `json:"info" yaml:"info"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths" yaml:"paths"`
+	Components *OpenAPIComponents          `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas       map[string]*OpenAPISchema      `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	Parameters    map[string]*OpenAPIParameter   `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBodies map[string]*OpenAPIRequestBody `json:"requestBodies,omitempty" yaml:"requestBodies,omitempty"`
+	Responses     map[string]*OpenAPIResponse    `json:"responses,omitempty" yaml:"responses,omitempty"`
+}
+
+type OpenAPIPathItem struct {
+	Parameters []*OpenAPIParameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Get        *OpenAPIOperation   `json:"get,omitempty" yaml:"get,omitempty"`
+	Put        *OpenAPIOperation   `json:"put,omitempty" yaml:"put,omitempty"`
+	Post       *OpenAPIOperation   `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete     *OpenAPIOperation   `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Options    *OpenAPIOperation   `json:"options,omitempty" yaml:"options,omitempty"`
+	Head       *OpenAPIOperation   `json:"head,omitempty" yaml:"head,omitempty"`
+	Patch      *OpenAPIOperation   `json:"patch,omitempty" yaml:"patch,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses" yaml:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Ref      string         `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Name     string         `json:"name,omitempty" yaml:"name,omitempty"`
+	In       string         `json:"in,omitempty" yaml:"in,omitempty"`
+	Required bool           `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Ref      string                       `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Required bool                         `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type OpenAPIResponse struct {
+	Ref         string                       `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Description string                       `json:"description" yaml:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   *OpenAPISchema             `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example  any                        `json:"example,omitempty" yaml:"example,omitempty"`
+	Examples map[string]*OpenAPIExample `json:"examples,omitempty" yaml:"examples,omitempty"`
+}
+
+type OpenAPIExample struct {
+	Summary string `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Value   any    `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string                    `json:"format,omitempty" yaml:"format,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty" yaml:"items,omitempty"`
+	AdditionalProperties any                       `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Example              any                       `json:"example,omitempty" yaml:"example,omitempty"`
+	Default              any                       `json:"default,omitempty" yaml:"default,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	AllOf                []*OpenAPISchema          `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	OneOf                []*OpenAPISchema          `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+	AnyOf                []*OpenAPISchema          `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+}
+
+// FromOpenAPI loads an OpenAPI 3 document from a JSON or YAML file,
+// ready to be added to a FakeService with AddOpenAPI.
+func FromOpenAPI(path string) (*OpenAPI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+	}
+	return ParseOpenAPI(data)
+}
+
+// ParseOpenAPI parses an OpenAPI 3 document in JSON or YAML.
+func ParseOpenAPI(data []byte) (*OpenAPI, error) {
+	var spec OpenAPI
+	// JSON is valid YAML, so one decoder handles both.
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, only 3.x is supported", spec.OpenAPI)
+	}
+	return &spec, nil
+}
+
+// OpenAPIOption configures how AddOpenAPI fakes a document.
+type OpenAPIOption func(*openAPIConfig)
+
+type openAPIConfig struct {
+	seed             int64
+	validationStatus int
+}
+
+// AddOpenAPI registers an endpoint for every operation in spec, each
+// answering with the example given for its success response, or with
+// a body made up from the response's schema if there is no example.
+// Tests rarely call every operation, so the endpoints are Optional.
+// Stubs for individual operations can be added beforehand to take
+// priority.
+func (f *FakeService) AddOpenAPI(spec *OpenAPI, opts ...OpenAPIOption) {
+	config := openAPIConfig{seed: 1}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	synth := &synthesizer{spec: spec, rng: rand.New(rand.NewSource(config.seed))}
+	if config.validationStatus != 0 {
+		f.validators = append(f.validators, &openAPIValidator{spec: spec, status: config.validationStatus})
+	}
+
+	names := f.wildcardNames()
+	for _, op := range spec.operations() {
+		e := &Endpoint{
+			Path:     names.ginPath(op.path),
+			Method:   op.method,
+			Optional: true,
+		}
+		status, response := spec.successResponse(op.operation)
+		e.StatusCode = status
+		if response != nil {
+			if contentType, body, ok := spec.exampleBody(response, synth); ok {
+				e.Response = body
+				e.ResponseHeaders = http.Header{"Content-Type": {contentType}}
+			}
+		}
+		f.AddEndpoint(e)
+	}
+}
+
+// openAPIOperation is an operation along with where it is served.
+type openAPIOperation struct {
+	method    string
+	path      string
+	item      *OpenAPIPathItem
+	operation *OpenAPIOperation
+}
+
+// operations returns every operation in the document, ordered by path
+// and then method.
+func (spec *OpenAPI) operations() []openAPIOperation {
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ops []openAPIOperation
+	for _, path := range paths {
+		item := spec.Paths[path]
+		if item == nil {
+			continue
+		}
+		for _, m := range []struct {
+			method string
+			op     *OpenAPIOperation
+		}{
+			{http.MethodGet, item.Get},
+			{http.MethodPut, item.Put},
+			{http.MethodPost, item.Post},
+			{http.MethodDelete, item.Delete},
+			{http.MethodOptions, item.Options},
+			{http.MethodHead, item.Head},
+			{http.MethodPatch, item.Patch},
+		} {
+			if m.op != nil {
+				ops = append(ops, openAPIOperation{method: m.method, path: path, item: item, operation: m.op})
+			}
+		}
+	}
+	return ops
+}
+
+// successResponse picks the response a stub should give: the lowest
+// 2xx response, otherwise the default response, otherwise the lowest
+// response of any kind.
+func (spec *OpenAPI) successResponse(op *OpenAPIOperation) (int, *OpenAPIResponse) {
+	best, bestStatus := "", 0
+	for code := range op.Responses {
+		status := openAPIStatus(code)
+		if status == 0 {
+			continue
+		}
+		if best == "" || openAPIRank(status) < openAPIRank(bestStatus) ||
+			openAPIRank(status) == openAPIRank(bestStatus) && status < bestStatus {
+			best, bestStatus = code, status
+		}
+	}
+	if best == "" || bestStatus >= 300 {
+		if response, ok := op.Responses["default"]; ok {
+			return http.StatusOK, spec.resolveResponse(response)
+		}
+	}
+	if best == "" {
+		return http.StatusOK, nil
+	}
+	return bestStatus, spec.resolveResponse(op.Responses[best])
+}
+
+// openAPIStatus converts a response key such as "200" or "2XX" into a
+// status code, or 0 for the default response.
+func openAPIStatus(code string) int {
+	if len(code) == 3 && strings.HasSuffix(strings.ToUpper(code), "XX") {
+		code = code[:1] + "00"
+	}
+	status, err := strconv.Atoi(code)
+	if err != nil {
+		return 0
+	}
+	return status
+}
+
+func openAPIRank(status int) int {
+	if status >= 200 && status < 300 {
+		return 0
+	}
+	return 1
+}
+
+// exampleBody returns the content type and example body given for a
+// response, preferring JSON content. JSON bodies without an example
+// are made up by synth from their schema.
+func (spec *OpenAPI) exampleBody(response *OpenAPIResponse, synth *synthesizer) (string, string, bool) {
+	contentType, media := preferredMediaType(response.Content)
+	if media == nil {
+		return "", "", false
+	}
+	example, ok := spec.mediaExample(media)
+	if !ok && isJSONContentType(contentType) && media.Schema != nil {
+		example, ok = synth.value(media.Schema, 0), true
+	}
+	if !ok {
+		return "", "", false
+	}
+	return contentType, encodeExample(contentType, example), true
+}
+
+// preferredMediaType picks JSON content if there is any, otherwise the
+// first content type alphabetically.
+func preferredMediaType(content map[string]*OpenAPIMediaType) (string, *OpenAPIMediaType) {
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	for _, contentType := range types {
+		if isJSONContentType(contentType) {
+			return contentType, content[contentType]
+		}
+	}
+	if len(types) == 0 {
+		return "", nil
+	}
+	return types[0], content[types[0]]
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// mediaExample finds the example for some content: its own example,
+// then the first of its named examples, then its schema's example.
+func (spec *OpenAPI) mediaExample(media *OpenAPIMediaType) (any, bool) {
+	if media.Example != nil {
+		return media.Example, true
+	}
+	if len(media.Examples) > 0 {
+		names := make([]string, 0, len(media.Examples))
+		for name := range media.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if example := media.Examples[names[0]]; example != nil && example.Value != nil {
+			return example.Value, true
+		}
+	}
+	if schema := spec.resolveSchema(media.Schema); schema != nil && schema.Example != nil {
+		return schema.Example, true
+	}
+	return nil, false
+}
+
+// encodeExample renders an example as a response body, leaving strings
+// for non-JSON content as they are.
+func encodeExample(contentType string, example any) string {
+	if s, ok := example.(string); ok && !isJSONContentType(contentType) {
+		return s
+	}
+	data, err := json.Marshal(example)
+	if err != nil {
+		return fmt.Sprint(example)
+	}
+	return string(data)
+}
+
+// wildcardNames maps the segments of a route before a wildcard, such
+// as /pets, to the name gin knows the wildcard by.
+type wildcardNames map[string]string
+
+// wildcardNames returns the wildcard names used by the routes already
+// registered.
+func (f *FakeService) wildcardNames() wildcardNames {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	names := wildcardNames{}
+	for path := range f.routes {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				names[strings.Join(segments[:i], "/")] = segment[1:]
+			}
+		}
+	}
+	return names
+}
+
+// ginPath converts path templates such as /pets/{petId} into gin routes
+// such as /pets/:petId. gin panics if routes sharing the segments before
+// a wildcard name it differently, as /pets/{id} and /pets/{petId}/toys
+// would, so a wildcard takes the name it was first given there.
+func (names wildcardNames) ginPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			prefix := strings.Join(segments[:i], "/")
+			name, ok := names[prefix]
+			if !ok {
+				name = segment[1 : len(segment)-1]
+				names[prefix] = name
+			}
+			segments[i] = ":" + name
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// componentName returns the name a local reference such as
+// #/components/schemas/Pet points to within the given section.
+func componentName(ref, section string) (string, bool) {
+	return strings.CutPrefix(ref, "#/components/"+section+"/")
+}
+
+func (spec *OpenAPI) resolveSchema(schema *OpenAPISchema) *OpenAPISchema {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < 32; depth++ {
+		name, ok := componentName(schema.Ref, "schemas")
+		if !ok || spec.Components == nil {
+			return nil
+		}
+		schema = spec.Components.Schemas[name]
+	}
+	return schema
+}
+
+func (spec *OpenAPI) resolveResponse(response *OpenAPIResponse) *OpenAPIResponse {
+	if response == nil || response.Ref == "" {
+		return response
+	}
+	name, ok := componentName(response.Ref, "responses")
+	if !ok || spec.Components == nil {
+		return nil
+	}
+	return spec.Components.Responses[name]
+}
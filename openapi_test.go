@@ -0,0 +1,117 @@
+package fake
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"testing"
+)
+
+func TestAddOpenAPIWithDifferentlyNamedParameters(t *testing.T) {
+	spec, err := ParseOpenAPI([]byte(`
+openapi: 3.0.0
+info: {title: Pets, version: "1"}
+paths:
+  /pets/{id}:
+    get:
+      responses:
+        "200":
+          description: a pet
+          content:
+            application/json:
+              example: {"name": "rex"}
+  /pets/{petId}/toys:
+    get:
+      responses:
+        "200":
+          description: its toys
+          content:
+            application/json:
+              example: [{"name": "ball"}]
+  /owners/{ownerId}/pets/{id}:
+    get:
+      responses:
+        "200":
+          description: an owner's pet
+          content:
+            application/json:
+              example: {"name": "fido"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New()
+	f.AddEndpoint(&Endpoint{Path: "/owners/:owner", Method: http.MethodGet, Response: "owner", Optional: true})
+	f.AddOpenAPI(spec)
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/pets/1", `{"name":"rex"}`},
+		{"/pets/1/toys", `[{"name":"ball"}]`},
+		{"/owners/2/pets/1", `{"name":"fido"}`},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(f.BaseURL() + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != tt.want {
+			t.Errorf("GET %s = %d %s, want 200 %s", tt.path, resp.StatusCode, body, tt.want)
+		}
+	}
+}
+
+func TestWildcardNames(t *testing.T) {
+	names := wildcardNames{}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/pets/{id}", "/pets/:id"},
+		{"/pets/{petId}/toys/{toyId}", "/pets/:id/toys/:toyId"},
+		{"/pets/{petId}/toys/{id}", "/pets/:id/toys/:toyId"},
+		{"/stores/{petId}", "/stores/:petId"},
+		{"/pets", "/pets"},
+	}
+	for _, tt := range tests {
+		if got := names.ginPath(tt.path); got != tt.want {
+			t.Errorf("ginPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSynthesizeWideRanges(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	tests := []struct {
+		typ      string
+		min, max *float64
+	}{
+		{"integer", nil, float(math.MaxInt64)},
+		{"integer", float(0), float(math.MaxInt64)},
+		{"integer", float(math.MinInt64), float(math.MaxInt64)},
+		{"number", float(-math.MaxFloat64 / 2), float(math.MaxFloat64 / 2)},
+	}
+	synth := &synthesizer{spec: &OpenAPI{}, rng: rand.New(rand.NewSource(1))}
+	for _, tt := range tests {
+		schema := &OpenAPISchema{Type: tt.typ, Minimum: tt.min, Maximum: tt.max}
+		for i := 0; i < 100; i++ {
+			var got float64
+			switch v := synth.value(schema, 0).(type) {
+			case int64:
+				got = float64(v)
+			case float64:
+				got = v
+			}
+			if tt.min != nil && got < *tt.min || got > *tt.max {
+				t.Fatalf("%s in [%v, %v] = %v", tt.typ, tt.min, *tt.max, got)
+			}
+		}
+	}
+}
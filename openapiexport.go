@@ -0,0 +1,176 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// anyMethod lists the methods an endpoint without a Method is
+// documented under.
+var anyMethod = []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch}
+
+// ExportOpenAPI describes the registered endpoints as an OpenAPI 3
+// document, with their static responses as examples and schemas
+// inferred from them, to document what the fake promises or to diff it
+// against the real upstream's document. Endpoints without a Method are
+// documented under every common method their path doesn't otherwise
+// have, and endpoints matched by a PathPattern are left out.
+func (f *FakeService) ExportOpenAPI() *OpenAPI {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	spec := &OpenAPI{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: "FakeService", Version: "1.0.0"},
+		Paths:   map[string]*OpenAPIPathItem{},
+	}
+	var anyMethodEndpoints []*Endpoint
+	specific := map[string]bool{}
+	for _, e := range f.Endpoints {
+		if e.PathPattern != "" {
+			continue
+		}
+		if e.Method == "" {
+			anyMethodEndpoints = append(anyMethodEndpoints, e)
+			continue
+		}
+		specific[strings.ToUpper(e.Method)+" "+e.Path] = true
+		spec.document(e, strings.ToUpper(e.Method))
+	}
+	for _, e := range anyMethodEndpoints {
+		for _, method := range anyMethod {
+			if !specific[method+" "+e.Path] {
+				spec.document(e, method)
+			}
+		}
+	}
+	return spec
+}
+
+// document adds the endpoint's response to the operation for method.
+// Only the first endpoint's response for each status is documented.
+func (spec *OpenAPI) document(e *Endpoint, method string) {
+	path, params := ginPathToOpenAPI(e.Path)
+	item := spec.Paths[path]
+	if item == nil {
+		item = &OpenAPIPathItem{}
+		spec.Paths[path] = item
+	}
+	op := item.operation(method)
+	if op == nil {
+		return
+	}
+	if *op == nil {
+		*op = &OpenAPIOperation{Responses: map[string]*OpenAPIResponse{}}
+		for _, name := range params {
+			(*op).Parameters = append((*op).Parameters, &OpenAPIParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &OpenAPISchema{Type: "string"},
+			})
+		}
+	}
+
+	status := e.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	code := strconv.Itoa(status)
+	if _, ok := (*op).Responses[code]; ok {
+		return
+	}
+	response := &OpenAPIResponse{Description: http.StatusText(status)}
+	if e.Handler == nil && e.ResponseTemplate == "" && e.Response != "" {
+		contentType, example := exampleFromResponse(e)
+		response.Content = map[string]*OpenAPIMediaType{
+			contentType: {Schema: schemaForExample(example), Example: example},
+		}
+	}
+	(*op).Responses[code] = response
+}
+
+// operation returns where the operation for method is held, or nil if
+// the method isn't one OpenAPI documents.
+func (item *OpenAPIPathItem) operation(method string) **OpenAPIOperation {
+	switch method {
+	case http.MethodGet:
+		return &item.Get
+	case http.MethodPut:
+		return &item.Put
+	case http.MethodPost:
+		return &item.Post
+	case http.MethodDelete:
+		return &item.Delete
+	case http.MethodOptions:
+		return &item.Options
+	case http.MethodHead:
+		return &item.Head
+	case http.MethodPatch:
+		return &item.Patch
+	}
+	return nil
+}
+
+// ginPathToOpenAPI turns a route such as /pets/:id into a path template
+// such as /pets/{id}, returning the names of its parameters.
+func ginPathToOpenAPI(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// exampleFromResponse returns the content type of the endpoint's
+// response and the response as an example, decoded if it is JSON.
+func exampleFromResponse(e *Endpoint) (string, any) {
+	contentType := e.ResponseHeaders.Get("Content-Type")
+	var decoded any
+	isJSON := json.Unmarshal([]byte(e.Response), &decoded) == nil
+	if contentType == "" {
+		contentType = "text/plain"
+		if isJSON {
+			contentType = "application/json"
+		}
+	}
+	contentType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if isJSON && isJSONContentType(contentType) {
+		return contentType, decoded
+	}
+	return contentType, e.Response
+}
+
+// schemaForExample infers a schema from a value decoded from JSON.
+func schemaForExample(value any) *OpenAPISchema {
+	switch v := value.(type) {
+	case map[string]any:
+		schema := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+		for key, item := range v {
+			schema.Properties[key] = schemaForExample(item)
+		}
+		return schema
+	case []any:
+		schema := &OpenAPISchema{Type: "array", Items: &OpenAPISchema{}}
+		if len(v) > 0 {
+			schema.Items = schemaForExample(v[0])
+		}
+		return schema
+	case string:
+		return &OpenAPISchema{Type: "string"}
+	case float64:
+		if v == float64(int64(v)) {
+			return &OpenAPISchema{Type: "integer"}
+		}
+		return &OpenAPISchema{Type: "number"}
+	case bool:
+		return &OpenAPISchema{Type: "boolean"}
+	}
+	return &OpenAPISchema{Nullable: true}
+}
@@ -0,0 +1,180 @@
+package fake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WithSynthesisSeed seeds the values made up for responses that have a
+// schema but no example, which are otherwise the same on every run.
+func WithSynthesisSeed(seed int64) OpenAPIOption {
+	return func(c *openAPIConfig) {
+		c.seed = seed
+	}
+}
+
+// maxSynthesisDepth is how deeply optional properties are filled in;
+// beyond it only required properties are, so recursive schemas end.
+const maxSynthesisDepth = 3
+
+// synthesizer makes up values that satisfy OpenAPI schemas.
+type synthesizer struct {
+	spec *OpenAPI
+	rng  *rand.Rand
+}
+
+func (s *synthesizer) value(schema *OpenAPISchema, depth int) any {
+	schema = s.spec.resolveSchema(schema)
+	if schema == nil || depth > 16 {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[s.rng.Intn(len(schema.Enum))]
+	case len(schema.AllOf) > 0:
+		merged := map[string]any{}
+		for _, part := range schema.AllOf {
+			if object, ok := s.value(part, depth).(map[string]any); ok {
+				for k, v := range object {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return s.value(schema.OneOf[0], depth)
+	case len(schema.AnyOf) > 0:
+		return s.value(schema.AnyOf[0], depth)
+	}
+
+	switch schema.Type {
+	case "object", "":
+		if schema.Type == "" && schema.Properties == nil {
+			return nil
+		}
+		return s.object(schema, depth)
+	case "array":
+		n := 1
+		if schema.MinItems != nil && *schema.MinItems > n {
+			n = *schema.MinItems
+		}
+		if schema.MaxItems != nil && *schema.MaxItems < n {
+			n = *schema.MaxItems
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i] = s.value(schema.Items, depth+1)
+		}
+		return items
+	case "string":
+		return s.string(schema)
+	case "integer":
+		n := s.number(schema)
+		switch {
+		case n >= math.MaxInt64:
+			return int64(math.MaxInt64)
+		case n <= math.MinInt64:
+			return int64(math.MinInt64)
+		}
+		return int64(n)
+	case "number":
+		return s.number(schema)
+	case "boolean":
+		return s.rng.Intn(2) == 1
+	}
+	return nil
+}
+
+func (s *synthesizer) object(schema *OpenAPISchema, depth int) map[string]any {
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	object := map[string]any{}
+	for _, name := range names {
+		if required[name] || depth < maxSynthesisDepth {
+			object[name] = s.value(schema.Properties[name], depth+1)
+		}
+	}
+	return object
+}
+
+func (s *synthesizer) string(schema *OpenAPISchema) string {
+	n := s.rng.Intn(10000)
+	var value string
+	switch schema.Format {
+	case "date-time":
+		value = s.time().Format(time.RFC3339)
+	case "date":
+		value = s.time().Format(time.DateOnly)
+	case "uuid":
+		b := make([]byte, 16)
+		s.rng.Read(b)
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		value = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	case "email":
+		value = fmt.Sprintf("user%d@example.com", n)
+	case "uri", "url":
+		value = fmt.Sprintf("https://example.com/%d", n)
+	case "hostname":
+		value = fmt.Sprintf("host%d.example.com", n)
+	case "ipv4":
+		value = fmt.Sprintf("192.0.2.%d", n%256)
+	case "ipv6":
+		value = fmt.Sprintf("2001:db8::%x", n)
+	case "byte":
+		value = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("data%d", n)))
+	default:
+		value = fmt.Sprintf("string%d", n)
+	}
+	if schema.MinLength != nil && len(value) < *schema.MinLength {
+		value += strings.Repeat("x", *schema.MinLength-len(value))
+	}
+	if schema.MaxLength != nil && len(value) > *schema.MaxLength {
+		value = value[:*schema.MaxLength]
+	}
+	return value
+}
+
+func (s *synthesizer) time() time.Time {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return base.Add(time.Duration(s.rng.Intn(365*24)) * time.Hour)
+}
+
+func (s *synthesizer) number(schema *OpenAPISchema) float64 {
+	min, max := 1.0, 100.0
+	if schema.Minimum != nil {
+		min = *schema.Minimum
+		if schema.Maximum == nil {
+			max = min + 100
+		}
+	}
+	if schema.Maximum != nil {
+		max = *schema.Maximum
+		if schema.Minimum == nil && max < min {
+			min = max - 100
+		}
+	}
+	if max <= min {
+		return min
+	}
+	// Bounds as wide as the int64 range would overflow Int63n, so the
+	// step is made in floating point instead.
+	return math.Min(min+math.Floor(s.rng.Float64()*(max-min+1)), max)
+}
@@ -0,0 +1,377 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithRequestValidation checks every request the service receives
+// against the OpenAPI document. Requests that break it, by calling an
+// operation the document doesn't have, missing required parameters or
+// sending a body that doesn't match its schema, fail the test and are
+// answered with status (400 if 0) and a JSON list of the problems.
+func WithRequestValidation(status int) OpenAPIOption {
+	return func(c *openAPIConfig) {
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		c.validationStatus = status
+	}
+}
+
+// openAPIValidator checks requests against an OpenAPI document.
+type openAPIValidator struct {
+	spec   *OpenAPI
+	status int
+}
+
+// validateRequests wraps next so that every request is checked against
+// the service's OpenAPI documents before it is handled.
+func (f *FakeService) validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+			r.Body.Close()
+		}
+		rewindBody(r, body)
+
+		status, violations := f.validate(r, body)
+		if len(violations) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fmt.Printf("%s: %s - HTTP %d, breaks the OpenAPI document\n", r.Method, r.URL, status)
+		if f.t != nil {
+			f.t.Errorf("FakeService received a request that breaks the OpenAPI document: %s %s\n  %s",
+				r.Method, r.URL, strings.Join(violations, "\n  "))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string][]string{"errors": violations})
+	})
+}
+
+// validate checks r against the first document describing its path.
+func (f *FakeService) validate(r *http.Request, body []byte) (int, []string) {
+	for _, v := range f.validators {
+		if violations, found := v.validate(r, body); found {
+			return v.status, violations
+		}
+	}
+	return f.validators[0].status, []string{fmt.Sprintf("path %s is not in the OpenAPI document", r.URL.Path)}
+}
+
+// validate checks r against the document, reporting whether the
+// document describes its path at all.
+func (v *openAPIValidator) validate(r *http.Request, body []byte) ([]string, bool) {
+	var pathFound bool
+	for _, op := range v.spec.operations() {
+		pathParams, ok := matchOpenAPIPath(op.path, r.URL.Path)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if op.method != r.Method {
+			continue
+		}
+		return v.validateOperation(op, r, pathParams, body), true
+	}
+	if pathFound {
+		return []string{fmt.Sprintf("method %s is not allowed for %s", r.Method, r.URL.Path)}, true
+	}
+	return nil, false
+}
+
+func (v *openAPIValidator) validateOperation(op openAPIOperation, r *http.Request, pathParams map[string]string, body []byte) []string {
+	var violations []string
+	for _, param := range v.parameters(op) {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = pathParams[param.Name]
+		case "query":
+			values, ok := r.URL.Query()[param.Name]
+			if ok && len(values) > 0 {
+				value, present = values[0], true
+			}
+		case "header":
+			values := r.Header.Values(param.Name)
+			if len(values) > 0 {
+				value, present = values[0], true
+			}
+		case "cookie":
+			if cookie, err := r.Cookie(param.Name); err == nil {
+				value, present = cookie.Value, true
+			}
+		default:
+			continue
+		}
+		where := fmt.Sprintf("%s parameter %s", param.In, param.Name)
+		if !present {
+			if param.Required || param.In == "path" {
+				violations = append(violations, fmt.Sprintf("%s is required", where))
+			}
+			continue
+		}
+		violations = append(violations, v.spec.validateValue(param.Schema, parseParameter(v.spec.resolveSchema(param.Schema), value), where)...)
+	}
+
+	requestBody := v.spec.resolveRequestBody(op.operation.RequestBody)
+	if requestBody == nil {
+		return violations
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if requestBody.Required {
+			violations = append(violations, "request body is required")
+		}
+		return violations
+	}
+	media, ok := requestBody.Content[mediaTypeOf(r)]
+	if !ok {
+		violations = append(violations, fmt.Sprintf("content type %q is not accepted", r.Header.Get("Content-Type")))
+		return violations
+	}
+	if !isJSONContentType(mediaTypeOf(r)) || media.Schema == nil {
+		return violations
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return append(violations, fmt.Sprintf("request body is not valid JSON: %s", err))
+	}
+	return append(violations, v.spec.validateValue(media.Schema, value, "request body")...)
+}
+
+// parameters returns the operation's parameters along with those of
+// its path that it doesn't override.
+func (v *openAPIValidator) parameters(op openAPIOperation) []*OpenAPIParameter {
+	var params []*OpenAPIParameter
+	seen := map[string]bool{}
+	for _, list := range [][]*OpenAPIParameter{op.operation.Parameters, op.item.Parameters} {
+		for _, param := range list {
+			param = v.spec.resolveParameter(param)
+			if param == nil || seen[param.In+":"+param.Name] {
+				continue
+			}
+			seen[param.In+":"+param.Name] = true
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+func mediaTypeOf(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return r.Header.Get("Content-Type")
+	}
+	return mediaType
+}
+
+// matchOpenAPIPath matches a request path against a path template such
+// as /pets/{petId}, returning the path parameters.
+func matchOpenAPIPath(template, path string) (map[string]string, bool) {
+	want := strings.Split(strings.Trim(template, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = got[i]
+			continue
+		}
+		if segment != got[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// parseParameter converts a parameter's value to the type its schema
+// calls for, leaving it as a string if it doesn't parse so validation
+// reports the mismatch.
+func parseParameter(schema *OpenAPISchema, value string) any {
+	if schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// validateValue checks a value decoded from JSON against schema,
+// describing the value as at in any violations.
+func (spec *OpenAPI) validateValue(schema *OpenAPISchema, value any, at string) []string {
+	schema = spec.resolveSchema(schema)
+	if schema == nil {
+		return nil
+	}
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s must not be null", at)}
+	}
+
+	var violations []string
+	for _, part := range schema.AllOf {
+		violations = append(violations, spec.validateValue(part, value, at)...)
+	}
+	for _, alternatives := range [][]*OpenAPISchema{schema.OneOf, schema.AnyOf} {
+		if len(alternatives) == 0 {
+			continue
+		}
+		matched := false
+		for _, alternative := range alternatives {
+			if len(spec.validateValue(alternative, value, at)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = append(violations, fmt.Sprintf("%s matches none of the allowed schemas", at))
+		}
+	}
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, value) {
+		violations = append(violations, fmt.Sprintf("%s must be one of %v, got %v", at, schema.Enum, value))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return append(violations, fmt.Sprintf("%s must be an object", at))
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				violations = append(violations, fmt.Sprintf("%s.%s is required", at, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				violations = append(violations, spec.validateValue(property, object[name], at+"."+name)...)
+			} else if schema.AdditionalProperties == false {
+				violations = append(violations, fmt.Sprintf("%s.%s is not allowed", at, name))
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return append(violations, fmt.Sprintf("%s must be an array", at))
+		}
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			violations = append(violations, fmt.Sprintf("%s must have at least %d items", at, *schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			violations = append(violations, fmt.Sprintf("%s must have at most %d items", at, *schema.MaxItems))
+		}
+		for i, item := range items {
+			violations = append(violations, spec.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(violations, fmt.Sprintf("%s must be a string", at))
+		}
+		if schema.MinLength != nil && len([]rune(s)) < *schema.MinLength {
+			violations = append(violations, fmt.Sprintf("%s must be at least %d characters", at, *schema.MinLength))
+		}
+		if schema.MaxLength != nil && len([]rune(s)) > *schema.MaxLength {
+			violations = append(violations, fmt.Sprintf("%s must be at most %d characters", at, *schema.MaxLength))
+		}
+		if schema.Pattern != "" {
+			if re, err := regexp.Compile(schema.Pattern); err == nil && !re.MatchString(s) {
+				violations = append(violations, fmt.Sprintf("%s must match %s", at, schema.Pattern))
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok && schema.Type == "integer" {
+			return append(violations, fmt.Sprintf("%s must be an integer", at))
+		}
+		if !ok {
+			return append(violations, fmt.Sprintf("%s must be a number", at))
+		}
+		if schema.Type == "integer" && n != float64(int64(n)) {
+			violations = append(violations, fmt.Sprintf("%s must be an integer", at))
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			violations = append(violations, fmt.Sprintf("%s must be at least %v", at, *schema.Minimum))
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			violations = append(violations, fmt.Sprintf("%s must be at most %v", at, *schema.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			violations = append(violations, fmt.Sprintf("%s must be a boolean", at))
+		}
+	}
+	return violations
+}
+
+// enumContains reports whether value, decoded from JSON, is one of the
+// enum's values, which were decoded from YAML and so may be typed
+// differently.
+func enumContains(enum []any, value any) bool {
+	for _, allowed := range enum {
+		data, err := json.Marshal(allowed)
+		if err != nil {
+			continue
+		}
+		var normalised any
+		if err := json.Unmarshal(data, &normalised); err != nil {
+			continue
+		}
+		if reflect.DeepEqual(normalised, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (spec *OpenAPI) resolveParameter(param *OpenAPIParameter) *OpenAPIParameter {
+	if param == nil || param.Ref == "" {
+		return param
+	}
+	name, ok := componentName(param.Ref, "parameters")
+	if !ok || spec.Components == nil {
+		return nil
+	}
+	return spec.Components.Parameters[name]
+}
+
+func (spec *OpenAPI) resolveRequestBody(body *OpenAPIRequestBody) *OpenAPIRequestBody {
+	if body == nil || body.Ref == "" {
+		return body
+	}
+	name, ok := componentName(body.Ref, "requestBodies")
+	if !ok || spec.Components == nil {
+		return nil
+	}
+	return spec.Components.RequestBodies[name]
+}
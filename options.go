@@ -0,0 +1,58 @@
+package fake
+
+import (
+	"math/rand"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Option configures a FakeService created with New.
+type Option func(*FakeService)
+
+// ginTestMode quiets gin once, as its mode is global and setting it
+// races with services already being set up by parallel tests.
+var ginTestMode sync.Once
+
+// New creates a FakeService configured by the given options. Without
+// WithPort the service listens on a random free port.
+func New(opts ...Option) *FakeService {
+	ginTestMode.Do(func() { gin.SetMode(gin.TestMode) })
+	router := gin.New()
+	seed := time.Now().UnixNano()
+	f := &FakeService{
+		router:         router,
+		testserver:     httptest.NewUnstartedServer(router),
+		routes:         map[string][]*Endpoint{},
+		scenarios:      newScenarios(),
+		State:          newStateStore(),
+		closing:        make(chan struct{}),
+		chaosControl:   newChaosControl(),
+		failureHandler: defaultFailureHandler,
+		chaosSeed:      seed,
+		rng:            rand.New(&lockedSource{src: rand.NewSource(seed)}),
+	}
+	router.NoRoute(f.unmatched)
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithPort sets the port the service listens on.
+func WithPort(port string) Option {
+	return func(f *FakeService) {
+		f.port = port
+	}
+}
+
+// Strict fails the test whenever the service receives a request that
+// doesn't match any registered endpoint, rather than quietly answering
+// with a 404 the code under test may swallow.
+func Strict() Option {
+	return func(f *FakeService) {
+		f.strict = true
+	}
+}
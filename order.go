@@ -0,0 +1,34 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (f *FakeService) recordOrder(e *Endpoint) {
+	f.orderMutex.Lock()
+	defer f.orderMutex.Unlock()
+
+	f.order = append(f.order, e.route())
+}
+
+// CallOrder returns the path of every endpoint called, in the order
+// the calls were received.
+func (f *FakeService) CallOrder() []string {
+	f.orderMutex.Lock()
+	defer f.orderMutex.Unlock()
+
+	return append([]string(nil), f.order...)
+}
+
+// VerifyOrder asserts that the given paths were called in order. Other
+// calls may happen in between, so this checks that paths appears as a
+// subsequence of CallOrder.
+func (f *FakeService) VerifyOrder(t testing.TB, paths ...string) bool {
+	t.Helper()
+	if err := f.CheckOrder(paths...); err != nil {
+		return assert.Fail(t, err.Error())
+	}
+	return true
+}
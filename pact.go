@@ -0,0 +1,387 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Pact is a consumer contract in the Pact specification's JSON format,
+// versions 2 and 3.
+type Pact struct {
+	Consumer     PactParticipant   `json:"consumer"`
+	Provider     PactParticipant   `json:"provider"`
+	Interactions []PactInteraction `json:"interactions"`
+	Metadata     map[string]any    `json:"metadata,omitempty"`
+}
+
+type PactParticipant struct {
+	Name string `json:"name"`
+}
+
+// PactInteraction is a request the consumer makes and the response it
+// expects. Version 2 contracts name a single ProviderState, version 3
+// contracts list ProviderStates.
+type PactInteraction struct {
+	Description    string              `json:"description"`
+	ProviderState  string              `json:"providerState,omitempty"`
+	ProviderStates []PactProviderState `json:"providerStates,omitempty"`
+	Request        PactRequest         `json:"request"`
+	Response       PactResponse        `json:"response"`
+}
+
+type PactProviderState struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+type PactRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   PactQuery         `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+}
+
+type PactResponse struct {
+	Status        int               `json:"status"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          any               `json:"body,omitempty"`
+	MatchingRules map[string]any    `json:"matchingRules,omitempty"`
+}
+
+// PactQuery is a request's query string, written as a string by
+// version 2 contracts and as a map of values by version 3 ones.
+type PactQuery url.Values
+
+func (q *PactQuery) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			return fmt.Errorf("invalid query %q: %w", raw, err)
+		}
+		*q = PactQuery(values)
+		return nil
+	}
+	var values map[string][]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("query must be a string or a map of values: %w", err)
+	}
+	*q = PactQuery(values)
+	return nil
+}
+
+// PactOption configures how a contract is verified, see VerifyPact.
+type PactOption func(*pactConfig)
+
+type pactConfig struct {
+	states map[string]func(*FakeService)
+}
+
+// WithProviderState registers setup to run before verifying any
+// interaction that requires the named provider state, e.g. to move a
+// scenario into the state the interaction expects. States without
+// setup are assumed to hold already.
+func WithProviderState(name string, setup func(*FakeService)) PactOption {
+	return func(c *pactConfig) {
+		c.states[name] = setup
+	}
+}
+
+// FromPact reads a Pact contract file.
+func FromPact(path string) (*Pact, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Pact file: %w", err)
+	}
+	var pact Pact
+	if err := json.Unmarshal(data, &pact); err != nil {
+		return nil, fmt.Errorf("failed to parse Pact file: %w", err)
+	}
+	return &pact, nil
+}
+
+// VerifyPact replays every interaction in the contract against the
+// running service, acting as the provider, and returns an error
+// describing each response that doesn't satisfy the consumer. The
+// service is Reset before each interaction and once verification is
+// done, so it is best verified in a test of its own.
+func (f *FakeService) VerifyPact(pact *Pact, opts ...PactOption) error {
+	config := pactConfig{states: map[string]func(*FakeService){}}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	defer f.Reset()
+
+	var errs []error
+	for _, interaction := range pact.Interactions {
+		f.Reset()
+		for _, state := range interaction.states() {
+			if setup, ok := config.states[state]; ok {
+				setup(f)
+			}
+		}
+		if mismatches, err := f.verifyInteraction(interaction); err != nil {
+			errs = append(errs, fmt.Errorf("interaction %q: %w", interaction.Description, err))
+		} else if len(mismatches) > 0 {
+			errs = append(errs, fmt.Errorf("interaction %q:\n  %s", interaction.Description, strings.Join(mismatches, "\n  ")))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s does not satisfy the contract with %s:\n%w", pact.Provider.Name, pact.Consumer.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (i PactInteraction) states() []string {
+	var states []string
+	if i.ProviderState != "" {
+		states = append(states, i.ProviderState)
+	}
+	for _, state := range i.ProviderStates {
+		states = append(states, state.Name)
+	}
+	return states
+}
+
+// verifyInteraction sends the interaction's request, returning the
+// ways the response differs from the expected one.
+func (f *FakeService) verifyInteraction(interaction PactInteraction) ([]string, error) {
+	want := interaction.Request
+	u := f.BaseURL() + want.Path
+	if len(want.Query) > 0 {
+		u += "?" + url.Values(want.Query).Encode()
+	}
+	header := http.Header{}
+	for name, value := range want.Headers {
+		header.Set(name, value)
+	}
+	body, err := pactBody(want.Body, header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(want.Method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header = header
+	if len(body) > 0 && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := f.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return interaction.Response.mismatches(resp, got), nil
+}
+
+// pactBody encodes a request body, which is sent as is if it is a
+// string in a contract that doesn't say the body is JSON.
+func pactBody(body any, contentType string) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	if text, ok := body.(string); ok && contentType != "" && !isJSONContentType(contentType) {
+		return []byte(text), nil
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+	return data, nil
+}
+
+func (want PactResponse) mismatches(resp *http.Response, body []byte) []string {
+	var mismatches []string
+	if want.Status != 0 && resp.StatusCode != want.Status {
+		mismatches = append(mismatches, fmt.Sprintf("status is %d, expected %d", resp.StatusCode, want.Status))
+	}
+
+	names := make([]string, 0, len(want.Headers))
+	for name := range want.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if got := resp.Header.Get(name); !headerValueMatches(name, want.Headers[name], got) {
+			mismatches = append(mismatches, fmt.Sprintf("header %s is %q, expected %q", name, got, want.Headers[name]))
+		}
+	}
+
+	if want.Body == nil {
+		return mismatches
+	}
+	if text, ok := want.Body.(string); ok && !isJSONContentType(resp.Header.Get("Content-Type")) {
+		if string(body) != text {
+			mismatches = append(mismatches, fmt.Sprintf("body is %q, expected %q", body, text))
+		}
+		return mismatches
+	}
+	var got any
+	if err := json.Unmarshal(body, &got); err != nil {
+		return append(mismatches, fmt.Sprintf("body is not valid JSON: %s", err))
+	}
+	rules := newPactRules(want.MatchingRules)
+	rules.compare("$", want.Body, got, false, &mismatches)
+	return mismatches
+}
+
+// headerValueMatches compares header values, ignoring parameters such
+// as charset on a Content-Type the contract gives without them.
+func headerValueMatches(name, want, got string) bool {
+	if strings.EqualFold(name, "Content-Type") && !strings.Contains(want, ";") {
+		got, _, _ = strings.Cut(got, ";")
+	}
+	return strings.TrimSpace(got) == strings.TrimSpace(want)
+}
+
+// pactRule is a matching rule relaxing how a part of the body is
+// compared, keyed by a JSON path such as $.items[*].id.
+type pactRule struct {
+	Match string `json:"match"`
+	Regex string `json:"regex"`
+	Min   *int   `json:"min"`
+	Max   *int   `json:"max"`
+}
+
+type pactRules map[string]pactRule
+
+// newPactRules reads the body matching rules from a response, written
+// as "$.body.path" keys by version 2 contracts and under "body" by
+// version 3 ones, where each rule lists matchers.
+func newPactRules(matchingRules map[string]any) pactRules {
+	rules := pactRules{}
+	decode := func(path string, raw any) {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return
+		}
+		var v3 struct {
+			Matchers []pactRule `json:"matchers"`
+		}
+		if json.Unmarshal(data, &v3) == nil && len(v3.Matchers) > 0 {
+			rules[path] = v3.Matchers[0]
+			return
+		}
+		var rule pactRule
+		if json.Unmarshal(data, &rule) == nil {
+			rules[path] = rule
+		}
+	}
+	for key, raw := range matchingRules {
+		if strings.HasPrefix(key, "$.body") {
+			decode("$"+strings.TrimPrefix(key, "$.body"), raw)
+		}
+	}
+	if body, ok := matchingRules["body"].(map[string]any); ok {
+		for path, raw := range body {
+			decode(path, raw)
+		}
+	}
+	return rules
+}
+
+var pactIndex = regexp.MustCompile(`\[\d+\]`)
+
+// rule returns the rule covering path, either given for it exactly or
+// for every element of the arrays it is in.
+func (rules pactRules) rule(path string) (pactRule, bool) {
+	if rule, ok := rules[path]; ok {
+		return rule, true
+	}
+	rule, ok := rules[pactIndex.ReplaceAllString(path, "[*]")]
+	return rule, ok
+}
+
+// compare checks got against want, following Pact's rules that
+// responses may hold keys the consumer doesn't use and that matching
+// rules loosen the comparison from equality. Once like is set, by a
+// type rule, values are only compared by type all the way down.
+func (rules pactRules) compare(path string, want, got any, like bool, mismatches *[]string) {
+	rule, hasRule := rules.rule(path)
+	switch {
+	case hasRule && rule.Match == "regex":
+		if re, err := regexp.Compile("^(?:" + rule.Regex + ")$"); err == nil && !re.MatchString(fmt.Sprint(got)) {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s is %s, which doesn't match %s", path, compactJSON(got), rule.Regex))
+		}
+		return
+	case hasRule && rule.Match == "type":
+		like = true
+	case hasRule && rule.Match == "equality":
+		like = false
+	}
+
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s is %s, expected an object", path, compactJSON(got)))
+			return
+		}
+		for _, key := range sortedKeys(w) {
+			gv, ok := g[key]
+			if !ok {
+				*mismatches = append(*mismatches, fmt.Sprintf("%s.%s is missing", path, key))
+				continue
+			}
+			rules.compare(path+"."+key, w[key], gv, like, mismatches)
+		}
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s is %s, expected an array", path, compactJSON(got)))
+			return
+		}
+		if like {
+			rules.compareLike(path, w, g, rule, mismatches)
+			return
+		}
+		if len(g) != len(w) {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s has %d items, expected %d", path, len(g), len(w)))
+			return
+		}
+		for i := range w {
+			rules.compare(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], false, mismatches)
+		}
+	default:
+		if like && reflect.TypeOf(want) != reflect.TypeOf(got) {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s is %s, expected a value like %s", path, compactJSON(got), compactJSON(want)))
+		}
+		if !like && !reflect.DeepEqual(want, got) {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s is %s, expected %s", path, compactJSON(got), compactJSON(want)))
+		}
+	}
+}
+
+// compareLike checks every item of an array matched by type against
+// the first expected item, and its length against the rule's bounds.
+func (rules pactRules) compareLike(path string, want, got []any, rule pactRule, mismatches *[]string) {
+	if rule.Min != nil && len(got) < *rule.Min {
+		*mismatches = append(*mismatches, fmt.Sprintf("%s has %d items, expected at least %d", path, len(got), *rule.Min))
+	}
+	if rule.Max != nil && len(got) > *rule.Max {
+		*mismatches = append(*mismatches, fmt.Sprintf("%s has %d items, expected at most %d", path, len(got), *rule.Max))
+	}
+	if len(want) == 0 {
+		return
+	}
+	for i, item := range got {
+		rules.compare(fmt.Sprintf("%s[%d]", path, i), want[0], item, true, mismatches)
+	}
+}
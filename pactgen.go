@@ -0,0 +1,260 @@
+package fake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// pactOutput is where recorded interactions are written as a contract,
+// see WithPactOutput.
+type pactOutput struct {
+	consumer string
+	provider string
+	dir      string
+}
+
+// WithPactOutput writes the requests the service handled, and the
+// responses it sent, as a Pact contract between consumer and provider
+// once a test passes. The contract is written to
+// dir/consumer-provider.json (dir is pacts by default) and interactions
+// from earlier tests already in the file are kept, so every test using
+// the fake adds to the same contract, even when run in parallel.
+func WithPactOutput(consumer, provider, dir string) Option {
+	return func(f *FakeService) {
+		if dir == "" {
+			dir = "pacts"
+		}
+		f.pactOutput = &pactOutput{consumer: consumer, provider: provider, dir: dir}
+	}
+}
+
+// Pact returns the requests the service has handled, and the responses
+// it sent, as a contract between consumer and provider. Identical
+// interactions appear once. Calls to endpoints taking part in a
+// scenario require the provider state "<scenario> is <state>".
+func (f *FakeService) Pact(consumer, provider string) *Pact {
+	type call struct {
+		e *Endpoint
+		r RecordedRequest
+	}
+	f.mutex.RLock()
+	var calls []call
+	for _, e := range f.Endpoints {
+		for _, r := range e.Requests() {
+			if r.Response != nil {
+				calls = append(calls, call{e: e, r: r})
+			}
+		}
+	}
+	f.mutex.RUnlock()
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].r.Time.Before(calls[j].r.Time)
+	})
+
+	pact := newPact(consumer, provider)
+	for _, c := range calls {
+		pact.add(newPactInteraction(c.e, c.r))
+	}
+	return pact
+}
+
+// ExportPact writes the contract returned by Pact to w.
+func (f *FakeService) ExportPact(w io.Writer, consumer, provider string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f.Pact(consumer, provider))
+}
+
+func newPact(consumer, provider string) *Pact {
+	return &Pact{
+		Consumer:     PactParticipant{Name: consumer},
+		Provider:     PactParticipant{Name: provider},
+		Interactions: []PactInteraction{},
+		Metadata: map[string]any{
+			"pactSpecification": map[string]string{"version": "2.0.0"},
+		},
+	}
+}
+
+// add appends an interaction unless the contract already has it,
+// numbering its description if another interaction shares it.
+func (p *Pact) add(interaction PactInteraction) {
+	description := interaction.Description
+	for n := 2; ; n++ {
+		existing := p.interaction(interaction.Description)
+		if existing == nil {
+			break
+		}
+		if reflect.DeepEqual(normalisePactInteraction(*existing), normalisePactInteraction(interaction)) {
+			return
+		}
+		interaction.Description = fmt.Sprintf("%s #%d", description, n)
+	}
+	p.Interactions = append(p.Interactions, interaction)
+}
+
+func (p *Pact) interaction(description string) *PactInteraction {
+	for i := range p.Interactions {
+		if p.Interactions[i].Description == description {
+			return &p.Interactions[i]
+		}
+	}
+	return nil
+}
+
+// normalisePactInteraction round trips an interaction through JSON so
+// one read from a file compares equal to one built from a request.
+func normalisePactInteraction(interaction PactInteraction) any {
+	interaction.Description = ""
+	data, err := json.Marshal(interaction)
+	if err != nil {
+		return nil
+	}
+	var normalised any
+	json.Unmarshal(data, &normalised)
+	return normalised
+}
+
+func newPactInteraction(e *Endpoint, r RecordedRequest) PactInteraction {
+	interaction := PactInteraction{
+		Description: fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+		Request: PactRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Body:   pactBodyFrom(r.Body),
+		},
+		Response: PactResponse{
+			Status: r.Response.StatusCode,
+			Body:   pactBodyFrom(r.Response.Body),
+		},
+	}
+	if e.Scenario != "" && e.RequiredState != "" {
+		interaction.ProviderState = fmt.Sprintf("%s is %s", e.Scenario, e.RequiredState)
+	}
+	if query := r.URL.Query(); len(query) > 0 {
+		interaction.Request.Query = PactQuery(query)
+	}
+	// Only the content type is kept, as other headers tend to vary
+	// between runs and would make the contract brittle.
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && interaction.Request.Body != nil {
+		interaction.Request.Headers = map[string]string{"Content-Type": contentType}
+	}
+	if contentType := r.Response.Header.Get("Content-Type"); contentType != "" {
+		interaction.Response.Headers = map[string]string{"Content-Type": contentType}
+	}
+	return interaction
+}
+
+// pactBodyFrom decodes a JSON body, leaving any other body as text.
+func pactBodyFrom(body []byte) any {
+	if len(body) == 0 {
+		return nil
+	}
+	var decoded any
+	if json.Unmarshal(body, &decoded) == nil {
+		return decoded
+	}
+	return string(body)
+}
+
+// MarshalJSON writes the query as a string, as version 2 contracts do.
+func (q PactQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(url.Values(q).Encode())
+}
+
+// pactFileLocks serializes writes to each contract file, as parallel
+// tests for the same consumer and provider each read, extend and
+// rewrite it.
+var pactFileLocks = struct {
+	sync.Mutex
+	files map[string]*sync.Mutex
+}{files: map[string]*sync.Mutex{}}
+
+// lockPactFile locks the named contract file, returning the unlock.
+func lockPactFile(file string) func() {
+	pactFileLocks.Lock()
+	lock, ok := pactFileLocks.files[file]
+	if !ok {
+		lock = &sync.Mutex{}
+		pactFileLocks.files[file] = lock
+	}
+	pactFileLocks.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// writePact adds the interactions recorded during the test to the
+// contract file, see WithPactOutput.
+func (f *FakeService) writePact() error {
+	out := f.pactOutput
+	if out == nil {
+		return nil
+	}
+	file := filepath.Join(out.dir, unsafeSnapshotChars.ReplaceAllString(out.consumer+"-"+out.provider, "_")+".json")
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	defer lockPactFile(file)()
+
+	pact, err := FromPact(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		pact, err = newPact(out.consumer, out.provider), nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, interaction := range f.Pact(out.consumer, out.provider).Interactions {
+		pact.add(interaction)
+	}
+
+	data, err := json.MarshalIndent(pact, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal Pact file: %w", err)
+	}
+	if err := os.MkdirAll(out.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create Pact directory: %w", err)
+	}
+	// Write the contract beside the old one and swap it in, so readers
+	// never see it half written.
+	tmp, err := os.CreateTemp(out.dir, filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write Pact file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write Pact file: %w", err)
+	}
+	return nil
+}
+
+// writePactT writes the contract if the test passed.
+func (f *FakeService) writePactT(t testing.TB) {
+	t.Helper()
+	if t.Failed() {
+		return
+	}
+	if err := f.writePact(); err != nil {
+		t.Errorf("failed to write Pact contract: %s", err.Error())
+	}
+}
@@ -0,0 +1,52 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWritePactFromParallelServices(t *testing.T) {
+	dir := t.TempDir()
+	const services = 20
+
+	var wg, ready sync.WaitGroup
+	ready.Add(services)
+	errs := make([]error, services)
+	for i := 0; i < services; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/pets/%d", i)
+			f := New(WithPactOutput("web", "pets", dir))
+			f.AddEndpoint(&Endpoint{Path: path, Method: http.MethodGet, Response: "{}"})
+			f.Run(t)
+			resp, err := http.Get(f.BaseURL() + path)
+			ready.Done()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			resp.Body.Close()
+			// Every service writes the contract at once.
+			ready.Wait()
+			errs[i] = f.TidyUpE()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pact, err := FromPact(filepath.Join(dir, "web-pets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pact.Interactions) != services {
+		t.Errorf("contract has %d interactions, want one from each of the %d services", len(pact.Interactions), services)
+	}
+}
@@ -0,0 +1,33 @@
+package fake
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ProxyURL returns the URL of the service for use as a forward proxy,
+// such as in HTTP_PROXY or with http.ProxyURL, once it is running.
+// Requests for any host are then sent to the service, where endpoints
+// with a Host only match requests for that host, so third-party APIs
+// can be faked without changing the URLs the code under test calls.
+func (f *FakeService) ProxyURL() *url.URL {
+	u, _ := url.Parse(f.BaseURL())
+	return u
+}
+
+// matchesHost reports whether the endpoint serves requests for host.
+func (e *Endpoint) matchesHost(host string) bool {
+	if e.Host == "" {
+		return true
+	}
+	if strings.EqualFold(e.Host, host) {
+		return true
+	}
+	// Unless the endpoint names a port, match the host on any port.
+	if _, _, err := net.SplitHostPort(e.Host); err == nil {
+		return false
+	}
+	hostname, _, err := net.SplitHostPort(host)
+	return err == nil && strings.EqualFold(e.Host, hostname)
+}
@@ -0,0 +1,95 @@
+package fake
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit rejects calls with a 429 once Requests calls have been
+// made within the current fixed Window, until the window resets. A
+// zero Window is a second.
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+
+	// Headers, if set, sends the X-RateLimit-Limit, X-RateLimit-Remaining
+	// and X-RateLimit-Reset headers on every response, not just on
+	// rejections, counting down with each call so clients can pace
+	// themselves.
+	Headers bool
+	// DraftHeaders, if set, sends the IETF draft's RateLimit-Limit,
+	// RateLimit-Remaining and RateLimit-Reset headers on every response,
+	// the reset being the seconds until the window resets.
+	DraftHeaders bool
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// take counts a call against the limit, reporting whether it is allowed
+// along with the calls remaining and when the window resets.
+func (l *RateLimit) take(now time.Time) (allowed bool, remaining int, reset time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	window := l.Window
+	if window <= 0 {
+		window = time.Second
+	}
+	if l.windowStart.IsZero() || now.Sub(l.windowStart) >= window {
+		l.windowStart = now
+		l.count = 0
+	}
+	reset = l.windowStart.Add(window)
+	if l.count >= l.Requests {
+		return false, 0, reset
+	}
+	l.count++
+	return true, l.Requests - l.count, reset
+}
+
+func (l *RateLimit) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.windowStart = time.Time{}
+	l.count = 0
+}
+
+// rejectRateLimited responds with a 429 and the standard headers
+// telling the client when it may retry.
+func rejectRateLimited(c *gin.Context, l *RateLimit, reset time.Time) {
+	retryAfter := int(math.Ceil(time.Until(reset).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", fmt.Sprint(retryAfter))
+	setRateLimitHeaders(c, l, 0, reset, true)
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+}
+
+// setRateLimitHeaders describes the state of the limit to the client,
+// with the X-RateLimit headers if l asks for them or withLegacy is set,
+// and the draft RateLimit headers if l asks for them.
+func setRateLimitHeaders(c *gin.Context, l *RateLimit, remaining int, reset time.Time, withLegacy bool) {
+	if l.Headers || withLegacy {
+		c.Header("X-RateLimit-Limit", fmt.Sprint(l.Requests))
+		c.Header("X-RateLimit-Remaining", fmt.Sprint(remaining))
+		c.Header("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+	}
+	if l.DraftHeaders {
+		seconds := int(math.Ceil(time.Until(reset).Seconds()))
+		if seconds < 0 {
+			seconds = 0
+		}
+		c.Header("RateLimit-Limit", fmt.Sprint(l.Requests))
+		c.Header("RateLimit-Remaining", fmt.Sprint(remaining))
+		c.Header("RateLimit-Reset", fmt.Sprint(seconds))
+	}
+}
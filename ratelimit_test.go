@@ -0,0 +1,28 @@
+package fake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitWindow(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name   string
+		window time.Duration
+		calls  []time.Duration
+		want   []bool
+	}{
+		{"within the window", time.Minute, []time.Duration{0, time.Second, 2 * time.Second}, []bool{true, true, false}},
+		{"after the window", time.Minute, []time.Duration{0, time.Second, time.Minute}, []bool{true, true, true}},
+		{"zero window is a second", 0, []time.Duration{0, 0, 500 * time.Millisecond, time.Second}, []bool{true, true, false, true}},
+	}
+	for _, tt := range tests {
+		l := &RateLimit{Requests: 2, Window: tt.window}
+		for i, offset := range tt.calls {
+			if allowed, _, _ := l.take(start.Add(offset)); allowed != tt.want[i] {
+				t.Errorf("%s: call %d allowed = %v, want %v", tt.name, i+1, allowed, tt.want[i])
+			}
+		}
+	}
+}
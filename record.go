@@ -0,0 +1,180 @@
+package fake
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordedRequest is a copy of a request received by an endpoint,
+// safe to inspect once the request has been handled.
+type RecordedRequest struct {
+	Method string
+	URL    *url.URL
+	Host   string
+	Proto  string
+	Header http.Header
+	Body   []byte
+	// Time is when the service started handling the request.
+	Time time.Time
+	// ClientCertificate is the certificate the client presented, if
+	// any, see RequireClientCert.
+	ClientCertificate *x509.Certificate
+
+	// Response is the response the service sent and Duration how long
+	// the service took to handle the request, both set once the request
+	// has been handled. Response is nil if no response was sent, such as
+	// when the connection was hijacked.
+	Response *RecordedResponse
+	Duration time.Duration
+}
+
+// End returns when the service finished handling the request.
+func (r RecordedRequest) End() time.Time {
+	return r.Time.Add(r.Duration)
+}
+
+// RecordedResponse is a copy of the response sent for a request.
+type RecordedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// recordRequest captures r, buffering its body and replacing it so
+// that it can still be read by expectations and handlers.
+func recordRequest(r *http.Request) RecordedRequest {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	rewindBody(r, body)
+
+	recorded := recordRequestHeader(r)
+	recorded.Body = body
+	return recorded
+}
+
+// recordRequestHeader captures everything about r but its body, which
+// is left for a streaming handler to read as it arrives.
+func recordRequestHeader(r *http.Request) RecordedRequest {
+	u := *r.URL
+	recorded := RecordedRequest{
+		Method: r.Method,
+		URL:    &u,
+		Host:   r.Host,
+		Proto:  r.Proto,
+		Header: r.Header.Clone(),
+		Time:   time.Now(),
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		recorded.ClientCertificate = r.TLS.PeerCertificates[0]
+	}
+	return recorded
+}
+
+func rewindBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+}
+
+// recorder is a thread-safe record of the calls made to an endpoint.
+// The call count can be read without locking, and readers of the
+// recorded requests always receive their own copy.
+type recorder struct {
+	calls atomic.Int64
+
+	mutex    sync.RWMutex
+	recorded []RecordedRequest
+}
+
+// record stores r and returns which call (1-indexed) it was.
+func (rec *recorder) record(r RecordedRequest) int {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	// The count is bumped under the lock so that a call's number
+	// always matches its position in recorded.
+	rec.recorded = append(rec.recorded, r)
+	return int(rec.calls.Add(1))
+}
+
+// recordResponse attaches the response sent for the nth call, along
+// with how long it took to handle.
+func (rec *recorder) recordResponse(call int, resp *RecordedResponse, duration time.Duration) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if call < 1 || call > len(rec.recorded) {
+		return
+	}
+	rec.recorded[call-1].Response = resp
+	rec.recorded[call-1].Duration = duration
+}
+
+func (rec *recorder) count() int {
+	return int(rec.calls.Load())
+}
+
+func (rec *recorder) requests() []RecordedRequest {
+	rec.mutex.RLock()
+	defer rec.mutex.RUnlock()
+
+	return append([]RecordedRequest(nil), rec.recorded...)
+}
+
+func (rec *recorder) reset() {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	rec.recorded = nil
+	rec.calls.Store(0)
+}
+
+// responseCapture tees everything written to the response so it can
+// be recorded once the request has been handled.
+type responseCapture struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	hijacked bool
+}
+
+func (w *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// recorded returns the response written, or nil if the connection was
+// hijacked and no response was sent.
+func (w *responseCapture) recorded() *RecordedResponse {
+	if w.hijacked {
+		return nil
+	}
+	return &RecordedResponse{
+		StatusCode: w.Status(),
+		Header:     w.Header().Clone(),
+		Body:       append([]byte(nil), w.body.Bytes()...),
+	}
+}
@@ -0,0 +1,278 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// ResourceStore is an in-memory REST collection served by a FakeService,
+// see Resource.
+type ResourceStore struct {
+	path    string
+	idField string
+
+	mutex sync.RWMutex
+	items map[string]map[string]any
+	order []string
+	next  int
+}
+
+// ResourceOption configures a ResourceStore.
+type ResourceOption func(*ResourceStore)
+
+// WithIDField sets the JSON field used to identify items, "id" by default.
+func WithIDField(field string) ResourceOption {
+	return func(r *ResourceStore) {
+		r.idField = field
+	}
+}
+
+// Resource creates a CRUD resource rooted at path. Once added to a
+// FakeService with AddResource it serves:
+//
+//	GET    /path      list all items
+//	POST   /path      create an item, 201 (409 if the ID is taken)
+//	GET    /path/:id  fetch an item, 404 if missing
+//	PUT    /path/:id  replace an item, 404 if missing
+//	PATCH  /path/:id  merge fields into an item, 404 if missing
+//	DELETE /path/:id  remove an item, 204 or 404 if missing
+//
+// Items created without an ID are assigned a sequential one.
+func Resource(path string, opts ...ResourceOption) *ResourceStore {
+	r := &ResourceStore{
+		path:    strings.TrimSuffix(path, "/"),
+		idField: "id",
+		items:   map[string]map[string]any{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// AddResource registers the collection and item endpoints for r. Tests
+// rarely exercise every operation, so the endpoints are Optional.
+func (f *FakeService) AddResource(r *ResourceStore) {
+	item := r.path + "/:id"
+	f.AddEndpoint(&Endpoint{Path: r.path, Method: http.MethodGet, Handler: r.list, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: r.path, Method: http.MethodPost, Handler: r.create, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: item, Method: http.MethodGet, Handler: r.get, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: item, Method: http.MethodPut, Handler: r.replace, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: item, Method: http.MethodPatch, Handler: r.patch, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: item, Method: http.MethodDelete, Handler: r.delete, Optional: true})
+}
+
+// Get returns the item stored under id.
+func (r *ResourceStore) Get(id string) (map[string]any, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	item, ok := r.items[id]
+	return item, ok
+}
+
+// List returns every stored item in the order they were created.
+func (r *ResourceStore) List() []map[string]any {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	items := make([]map[string]any, 0, len(r.order))
+	for _, id := range r.order {
+		items = append(items, r.items[id])
+	}
+	return items
+}
+
+// Put stores an item, replacing any existing item with the same ID,
+// and returns the ID it was stored under.
+func (r *ResourceStore) Put(item map[string]any) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.put(item)
+}
+
+func (r *ResourceStore) put(item map[string]any) string {
+	id, ok := r.idOf(item)
+	for !ok {
+		r.next++
+		id = fmt.Sprint(r.next)
+		if _, taken := r.items[id]; !taken {
+			item[r.idField] = id
+			ok = true
+		}
+	}
+	if _, exists := r.items[id]; !exists {
+		r.order = append(r.order, id)
+	}
+	r.items[id] = item
+	return id
+}
+
+func (r *ResourceStore) idOf(item map[string]any) (string, bool) {
+	v, ok := item[r.idField]
+	if !ok || v == nil {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+func (r *ResourceStore) list(c *gin.Context) {
+	c.JSON(http.StatusOK, r.List())
+}
+
+func (r *ResourceStore) create(c *gin.Context) {
+	var item map[string]any
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if id, ok := r.idOf(item); ok {
+		if _, exists := r.items[id]; exists {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s already exists", id)})
+			return
+		}
+	}
+	id := r.put(item)
+	c.Header("Location", r.path+"/"+id)
+	c.JSON(http.StatusCreated, item)
+}
+
+func (r *ResourceStore) get(c *gin.Context) {
+	item, ok := r.Get(c.Param("id"))
+	if !ok {
+		r.notFound(c)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+func (r *ResourceStore) replace(c *gin.Context) {
+	var item map[string]any
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := c.Param("id")
+	if _, ok := r.items[id]; !ok {
+		r.notFound(c)
+		return
+	}
+	item[r.idField] = id
+	r.items[id] = item
+	c.JSON(http.StatusOK, item)
+}
+
+func (r *ResourceStore) patch(c *gin.Context) {
+	var fields map[string]any
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := c.Param("id")
+	item, ok := r.items[id]
+	if !ok {
+		r.notFound(c)
+		return
+	}
+	merged := make(map[string]any, len(item)+len(fields))
+	for k, v := range item {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	merged[r.idField] = item[r.idField]
+	r.items[id] = merged
+	c.JSON(http.StatusOK, merged)
+}
+
+func (r *ResourceStore) delete(c *gin.Context) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := c.Param("id")
+	if _, ok := r.items[id]; !ok {
+		r.notFound(c)
+		return
+	}
+	delete(r.items, id)
+	for i, existing := range r.order {
+		if existing == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (r *ResourceStore) notFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s not found", c.Param("id"))})
+}
+
+// Seed stores each element of items, which must be a slice of structs
+// or maps that marshal to JSON objects, so the resource has data before
+// the first request.
+func (r *ResourceStore) Seed(items any) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal seed items: %w", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("seed items must be a list of objects: %w", err)
+	}
+	r.seed(decoded)
+	return nil
+}
+
+// SeedFile seeds the resource from a JSON or YAML fixture file holding
+// a list of objects. The format is chosen by the file's extension.
+func (r *ResourceStore) SeedFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var items []map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &items)
+	default:
+		err = json.Unmarshal(data, &items)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	r.seed(items)
+	return nil
+}
+
+func (r *ResourceStore) seed(items []map[string]any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, item := range items {
+		r.put(item)
+	}
+}
@@ -0,0 +1,144 @@
+package fake
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ScenarioStarted is the state every scenario begins in.
+const ScenarioStarted = "Started"
+
+// ScenarioTransition records a scenario moving between states.
+type ScenarioTransition struct {
+	Scenario string
+	From     string
+	To       string
+	// Method and URL describe the request that triggered the transition
+	// and are empty if the state was set directly by the test.
+	Method string
+	URL    string
+	Time   time.Time
+}
+
+type scenarios struct {
+	mutex   sync.Mutex
+	states  map[string]string
+	history []ScenarioTransition
+}
+
+func newScenarios() *scenarios {
+	return &scenarios{states: map[string]string{}}
+}
+
+func (s *scenarios) state(name string) string {
+	return s.stateOr(name, ScenarioStarted)
+}
+
+// stateOr returns the current state of the named scenario, or initial
+// if it has yet to transition.
+func (s *scenarios) stateOr(name, initial string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if state, ok := s.states[name]; ok {
+		return state
+	}
+	return initial
+}
+
+// move transitions the named scenario to the given state, recording
+// the request responsible, if any.
+func (s *scenarios) move(name, initial, to string, r *http.Request) {
+	s.moveIf(name, initial, "", to, r)
+}
+
+// moveIf transitions the named scenario to the given state if it is
+// currently in state expected, or in any state if expected is empty,
+// reporting whether it moved. The state is checked and moved under one
+// lock, so concurrent calls can't both move a scenario on from the
+// same state.
+func (s *scenarios) moveIf(name, initial, expected, to string, r *http.Request) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	from, ok := s.states[name]
+	if !ok {
+		from = initial
+	}
+	if expected != "" && from != expected {
+		return false
+	}
+	s.states[name] = to
+
+	transition := ScenarioTransition{
+		Scenario: name,
+		From:     from,
+		To:       to,
+		Time:     time.Now(),
+	}
+	if r != nil {
+		transition.Method = r.Method
+		transition.URL = r.URL.String()
+	}
+	s.history = append(s.history, transition)
+	return true
+}
+
+func (s *scenarios) transitions(name string) []ScenarioTransition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var history []ScenarioTransition
+	for _, t := range s.history {
+		if name == "" || t.Scenario == name {
+			history = append(history, t)
+		}
+	}
+	return history
+}
+
+func (s *scenarios) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.states = map[string]string{}
+	s.history = nil
+}
+
+// matches reports whether the endpoint's required state, if any,
+// is the current state of its scenario.
+func (s *scenarios) matches(e *Endpoint) bool {
+	if e.Scenario == "" || e.RequiredState == "" {
+		return true
+	}
+	return s.state(e.Scenario) == e.RequiredState
+}
+
+// transition moves the endpoint's scenario into its new state, if any.
+// The scenario only moves if it is still in the endpoint's required
+// state, as a concurrent call may have moved it on since this one
+// matched.
+func (s *scenarios) transition(e *Endpoint, r *http.Request) {
+	if e.Scenario == "" || e.NewState == "" {
+		return
+	}
+	s.moveIf(e.Scenario, ScenarioStarted, e.RequiredState, e.NewState, r)
+}
+
+// ScenarioState returns the current state of the named scenario.
+func (f *FakeService) ScenarioState(name string) string {
+	return f.scenarios.state(name)
+}
+
+// SetScenarioState forces the named scenario into the given state.
+func (f *FakeService) SetScenarioState(name, state string) {
+	f.scenarios.move(name, ScenarioStarted, state, nil)
+}
+
+// ScenarioHistory returns every transition the named scenario has
+// made, oldest first. An empty name returns the history of every
+// scenario, including state machines.
+func (f *FakeService) ScenarioHistory(name string) []ScenarioTransition {
+	return f.scenarios.transitions(name)
+}
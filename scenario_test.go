@@ -0,0 +1,78 @@
+package fake
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// callConcurrently makes n POST calls to url at once.
+func callConcurrently(t *testing.T, n int, url string) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(url, "text/plain", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentCallsMoveScenarioOnce(t *testing.T) {
+	f := New()
+	f.AddEndpoint(&Endpoint{
+		Path:          "/claim",
+		Method:        http.MethodPost,
+		Response:      "won",
+		Scenario:      "claim",
+		RequiredState: ScenarioStarted,
+		NewState:      "claimed",
+		// Widen the gap between matching a call and moving the
+		// scenario on.
+		Expectation: func(*http.Request) { time.Sleep(10 * time.Millisecond) },
+	})
+	f.AddEndpoint(&Endpoint{
+		Path:          "/claim",
+		Method:        http.MethodPost,
+		Response:      "lost",
+		Scenario:      "claim",
+		RequiredState: "claimed",
+		Optional:      true,
+	})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	callConcurrently(t, 20, f.BaseURL()+"/claim")
+	if history := f.ScenarioHistory("claim"); len(history) != 1 {
+		t.Errorf("scenario moved %d times, want once: %+v", len(history), history)
+	}
+}
+
+func TestConcurrentCallsMoveStateMachineOnce(t *testing.T) {
+	f := New()
+	f.AddStateMachine(&StateMachine{
+		Name:    "lock",
+		Initial: "free",
+		Transitions: []Transition{
+			{From: "free", To: "held", Method: http.MethodPost, Path: "/lock", Guard: func(*http.Request) bool {
+				time.Sleep(10 * time.Millisecond)
+				return true
+			}},
+		},
+	})
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	callConcurrently(t, 20, f.BaseURL()+"/lock")
+	if history := f.ScenarioHistory("lock"); len(history) != 1 {
+		t.Errorf("state machine moved %d times, want once: %+v", len(history), history)
+	}
+}
@@ -0,0 +1,20 @@
+package fake
+
+import "slices"
+
+// CallRange returns the calls from through to inclusive, for use with
+// FailOnCalls, e.g. FailOnCalls: fake.CallRange(3, 5) fails the third,
+// fourth and fifth calls.
+func CallRange(from, to int) []int {
+	var calls []int
+	for n := from; n <= to; n++ {
+		calls = append(calls, n)
+	}
+	return calls
+}
+
+// scheduledFailure reports whether call is one the endpoint has been
+// told to fail on.
+func (e *Endpoint) scheduledFailure(call int) bool {
+	return slices.Contains(e.FailOnCalls, call)
+}
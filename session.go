@@ -0,0 +1,160 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CookieSession fakes a form login backed by a session cookie. A POST
+// to LoginPath with the right username and password sets the cookie, a
+// POST to LogoutPath clears it, and endpoints added with RequireSession
+// reject calls without a live session with a 401, so browser-style
+// clients and their cookie jars can be exercised.
+type CookieSession struct {
+	// LoginPath defaults to "/login" and LogoutPath to "/logout".
+	LoginPath  string
+	LogoutPath string
+	// Users maps usernames to passwords. Any user is accepted if it is
+	// empty.
+	Users map[string]string
+	// CookieName defaults to "session".
+	CookieName string
+	// TTL is how long sessions last, an hour by default.
+	TTL time.Duration
+	// RedirectTo, if set, is where a successful login redirects with a
+	// 303, as a form post would. Otherwise it answers with a 204.
+	RedirectTo string
+
+	mutex    sync.Mutex
+	sessions map[string]*Session
+}
+
+// Session is a session started by logging in to a CookieSession.
+type Session struct {
+	ID        string
+	Username  string
+	ExpiresAt time.Time
+}
+
+// AddCookieSession registers the login and logout endpoints for s.
+// Tests don't always log in or out, so the endpoints are Optional.
+func (f *FakeService) AddCookieSession(s *CookieSession) {
+	if s.LoginPath == "" {
+		s.LoginPath = "/login"
+	}
+	if s.LogoutPath == "" {
+		s.LogoutPath = "/logout"
+	}
+	if s.CookieName == "" {
+		s.CookieName = "session"
+	}
+	if s.TTL == 0 {
+		s.TTL = time.Hour
+	}
+	f.AddEndpoint(&Endpoint{Path: s.LoginPath, Method: http.MethodPost, Handler: s.login, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: s.LogoutPath, Method: http.MethodPost, Handler: s.logout, Optional: true})
+}
+
+// RequireSession makes the endpoint reject calls without a live session
+// from s with a 401. Rejected calls are still recorded.
+func (e *Endpoint) RequireSession(s *CookieSession) *Endpoint {
+	e.Session = s
+	return e
+}
+
+// Session returns the live session r's cookie belongs to.
+func (s *CookieSession) Session(r *http.Request) (Session, bool) {
+	cookie, err := r.Cookie(s.CookieName)
+	if err != nil {
+		return Session{}, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, ok := s.sessions[cookie.Value]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return Session{}, false
+	}
+	return *session, true
+}
+
+// Sessions returns every live session.
+func (s *CookieSession) Sessions() []Session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var sessions []Session
+	for _, session := range s.sessions {
+		if time.Now().Before(session.ExpiresAt) {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions
+}
+
+// ExpireSessions expires every session, to test how clients handle
+// being logged out.
+func (s *CookieSession) ExpireSessions() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, session := range s.sessions {
+		session.ExpiresAt = time.Now()
+	}
+}
+
+// credentials reads the username and password from a form or, if the
+// request is JSON, a JSON body.
+func (s *CookieSession) credentials(c *gin.Context) (string, string) {
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		var body struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		json.NewDecoder(c.Request.Body).Decode(&body)
+		return body.Username, body.Password
+	}
+	return c.PostForm("username"), c.PostForm("password")
+}
+
+func (s *CookieSession) login(c *gin.Context) {
+	username, password := s.credentials(c)
+	if want, ok := s.Users[username]; username == "" || (len(s.Users) > 0 && (!ok || want != password)) {
+		c.String(http.StatusUnauthorized, "wrong username or password")
+		return
+	}
+
+	session := &Session{ID: randomToken(), Username: username, ExpiresAt: time.Now().Add(s.TTL)}
+	s.mutex.Lock()
+	if s.sessions == nil {
+		s.sessions = map[string]*Session{}
+	}
+	s.sessions[session.ID] = session
+	s.mutex.Unlock()
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.CookieName,
+		Value:    session.ID,
+		Path:     "/",
+		MaxAge:   int(s.TTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if s.RedirectTo != "" {
+		c.Redirect(http.StatusSeeOther, s.RedirectTo)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *CookieSession) logout(c *gin.Context) {
+	if cookie, err := c.Request.Cookie(s.CookieName); err == nil {
+		s.mutex.Lock()
+		delete(s.sessions, cookie.Value)
+		s.mutex.Unlock()
+	}
+	http.SetCookie(c.Writer, &http.Cookie{Name: s.CookieName, Value: "", Path: "/", MaxAge: -1})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package fake
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSigV4Escape(t *testing.T) {
+	tests := []struct {
+		in          string
+		encodeSlash bool
+		want        string
+	}{
+		{"abc-_.~019", true, "abc-_.~019"},
+		{"a b+c", true, "a%20b%2Bc"},
+		{"/a/b", false, "/a/b"},
+		{"/a/b", true, "%2Fa%2Fb"},
+		{"%2F", false, "%252F"},
+		{"é", true, "%C3%A9"},
+		{"=&*", true, "%3D%26%2A"},
+	}
+	for _, tt := range tests {
+		if got := sigV4Escape(tt.in, tt.encodeSlash); got != tt.want {
+			t.Errorf("sigV4Escape(%q, %v) = %q, want %q", tt.in, tt.encodeSlash, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalSigV4Request(t *testing.T) {
+	emptyHash := sha256Hex(nil)
+	tests := []struct {
+		name    string
+		url     string
+		service string
+		headers map[string]string
+		signed  []string
+		want    string
+	}{
+		{
+			name:    "vanilla",
+			url:     "http://example.amazonaws.com/",
+			service: "service",
+			headers: map[string]string{"X-Amz-Date": "20150830T123600Z"},
+			signed:  []string{"host", "x-amz-date"},
+			want:    "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + emptyHash,
+		},
+		{
+			name:    "query sorted and encoded",
+			url:     "http://example.amazonaws.com/?Param2=value2&Param1=value%201&a=b%2Fc",
+			service: "service",
+			signed:  []string{"host"},
+			want:    "GET\n/\nParam1=value%201&Param2=value2&a=b%2Fc\nhost:example.amazonaws.com\n\nhost\n" + emptyHash,
+		},
+		{
+			name:    "path encoded twice",
+			url:     "http://example.amazonaws.com/a%20b/c",
+			service: "service",
+			signed:  []string{"host"},
+			want:    "GET\n/a%2520b/c\n\nhost:example.amazonaws.com\n\nhost\n" + emptyHash,
+		},
+		{
+			name:    "S3 path encoded once",
+			url:     "http://bucket.s3.amazonaws.com/a%20b/c",
+			service: "s3",
+			signed:  []string{"host"},
+			want:    "GET\n/a%20b/c\n\nhost:bucket.s3.amazonaws.com\n\nhost\n" + emptyHash,
+		},
+		{
+			name:    "header whitespace folded",
+			url:     "http://example.amazonaws.com/",
+			service: "service",
+			headers: map[string]string{"My-Header": "  value   with  spaces "},
+			signed:  []string{"host", "my-header"},
+			want:    "GET\n/\n\nhost:example.amazonaws.com\nmy-header:value with spaces\n\nhost;my-header\n" + emptyHash,
+		},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		for name, value := range tt.headers {
+			r.Header.Set(name, value)
+		}
+		sig := sigV4Signature{signedHeaders: tt.signed, payloadHash: emptyHash}
+		if got := canonicalSigV4Request(r, sig, tt.service); got != tt.want {
+			t.Errorf("%s: canonical request =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestVerifySigV4 checks requests from the AWS SigV4 test suite.
+func TestVerifySigV4(t *testing.T) {
+	const credential = "Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+		wantErr   string
+	}{
+		{"get-vanilla", "http://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", ""},
+		{"get-vanilla-query-order-key-case", "http://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500", ""},
+		{"tampered", "http://example.amazonaws.com/?Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500", "signature doesn't match"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		r.Header.Set("Authorization", sigV4Algorithm+" "+credential+tt.signature)
+		err := verifySigV4(r, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
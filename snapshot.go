@@ -0,0 +1,153 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// updateSnapshots reports whether snapshots and cassettes should be
+// rewritten rather than compared against, which is asked for by setting
+// FAKES_UPDATE=1, or by running with -update if the test package
+// defines that flag itself. The library doesn't define the flag, as
+// that would clash with packages that do.
+func updateSnapshots() bool {
+	if update, _ := strconv.ParseBool(os.Getenv("FAKES_UPDATE")); update {
+		return true
+	}
+	if update := flag.Lookup("update"); update != nil {
+		set, _ := strconv.ParseBool(update.Value.String())
+		return set
+	}
+	return false
+}
+
+// snapshotIgnoredHeaders vary between runs and are left out of snapshots.
+var snapshotIgnoredHeaders = []string{
+	"Accept-Encoding",
+	"Content-Length",
+	"Date",
+	"Traceparent",
+	"User-Agent",
+	"X-Request-Id",
+}
+
+type requestSnapshot struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Query  map[string][]string `json:"query,omitempty"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   any                 `json:"body,omitempty"`
+}
+
+// WithSnapshots records a normalized snapshot of every request the
+// service receives and, during TidyUp, compares them with the snapshot
+// stored under dir (testdata/fakes by default) for the running test.
+// Run the tests with FAKES_UPDATE=1 to write new snapshots.
+func WithSnapshots(dir string) Option {
+	return func(f *FakeService) {
+		if dir == "" {
+			dir = filepath.Join("testdata", "fakes")
+		}
+		f.snapshotDir = dir
+	}
+}
+
+var unsafeSnapshotChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+
+func (f *FakeService) verifySnapshots(t testing.TB) {
+	t.Helper()
+	if err := f.checkSnapshots(t.Name()); err != nil {
+		t.Error(err)
+	}
+}
+
+// checkSnapshots compares the requests received with the snapshot
+// stored for the named test, or rewrites it if FAKES_UPDATE is set.
+func (f *FakeService) checkSnapshots(name string) error {
+	if f.snapshotDir == "" {
+		return nil
+	}
+
+	snapshots := []requestSnapshot{}
+	for _, r := range f.Journal() {
+		snapshots = append(snapshots, newRequestSnapshot(r))
+	}
+	actual, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal request snapshots: %w", err)
+	}
+	actual = append(actual, '\n')
+
+	file := filepath.Join(f.snapshotDir, unsafeSnapshotChars.ReplaceAllString(name, "_")+".json")
+	if updateSnapshots() {
+		if err := os.MkdirAll(f.snapshotDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+		if err := os.WriteFile(file, actual, 0o644); err != nil {
+			return fmt.Errorf("failed to write request snapshot: %w", err)
+		}
+		return nil
+	}
+
+	expected, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no request snapshot found at %s, run the tests with FAKES_UPDATE=1 to create it", file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read request snapshot: %w", err)
+	}
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+	diffs, err := jsonDiff(expected, actual)
+	if err != nil {
+		return fmt.Errorf("request snapshot %s: %w", file, err)
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("requests did not match snapshot %s, run the tests with FAKES_UPDATE=1 if this change is expected:\n%s", file, formatJSONDiff(diffs))
+}
+
+func newRequestSnapshot(r RecordedRequest) requestSnapshot {
+	s := requestSnapshot{
+		Method: r.Method,
+		Path:   r.URL.Path,
+	}
+	if query := r.URL.Query(); len(query) > 0 {
+		s.Query = query
+		for _, values := range query {
+			sort.Strings(values)
+		}
+	}
+
+	header := r.Header.Clone()
+	for _, name := range snapshotIgnoredHeaders {
+		header.Del(name)
+	}
+	if len(header) > 0 {
+		s.Header = header
+	}
+
+	if len(r.Body) > 0 {
+		// JSON bodies are stored decoded, so key order and whitespace
+		// don't cause spurious differences.
+		var body any
+		if json.Unmarshal(r.Body, &body) == nil {
+			s.Body = body
+		} else {
+			s.Body = string(r.Body)
+		}
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package fake
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUpdateFlagIsNotRegistered(t *testing.T) {
+	// Packages using the fakes commonly define -update themselves.
+	if flag.Lookup("update") != nil {
+		t.Fatal("the package must not register an -update flag")
+	}
+}
+
+func TestUpdateSnapshotsFromEnv(t *testing.T) {
+	tests := []struct {
+		env  string
+		want bool
+	}{
+		{"", false},
+		{"0", false},
+		{"1", true},
+		{"true", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("FAKES_UPDATE", tt.env)
+		if got := updateSnapshots(); got != tt.want {
+			t.Errorf("FAKES_UPDATE=%q: updateSnapshots() = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestTidyUpEChecksSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	call := func(path string) error {
+		f := New(WithSnapshots(dir))
+		f.AddEndpoint(&Endpoint{Path: path, Method: http.MethodGet, Optional: true})
+		f.Run(t)
+		resp, err := http.Get(f.BaseURL() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return f.TidyUpE()
+	}
+
+	if err := call("/pets"); err == nil || !strings.Contains(err.Error(), "no request snapshot") {
+		t.Fatalf("TidyUpE() = %v, want a missing snapshot error", err)
+	}
+	t.Setenv("FAKES_UPDATE", "1")
+	if err := call("/pets"); err != nil {
+		t.Fatalf("TidyUpE() updating = %v", err)
+	}
+	t.Setenv("FAKES_UPDATE", "")
+	if err := call("/pets"); err != nil {
+		t.Fatalf("TidyUpE() = %v, want the snapshot to match", err)
+	}
+	if err := call("/owners"); err == nil || !strings.Contains(err.Error(), "did not match snapshot") {
+		t.Fatalf("TidyUpE() = %v, want a snapshot mismatch", err)
+	}
+}
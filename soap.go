@@ -0,0 +1,256 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// SOAP fakes a SOAP service, where every operation is POSTed to the
+// same path. Requests are answered by the first of Operations that
+// matches them, and anything else gets a SOAP fault. SOAP 1.2 requests
+// are answered with SOAP 1.2 envelopes and everything else with 1.1.
+type SOAP struct {
+	// Path defaults to "/".
+	Path       string
+	Operations []*SOAPOperation
+
+	mutex sync.Mutex
+	calls map[*SOAPOperation]int
+}
+
+// SOAPOperation stubs the response to a SOAP operation. Every field
+// that is set must match for the stub to be used.
+type SOAPOperation struct {
+	// Action is matched against the SOAPAction header, or the action
+	// parameter of a SOAP 1.2 request's Content-Type.
+	Action string
+	// Element is matched against the local name of the operation
+	// element inside the request's Body.
+	Element string
+
+	// Response is the XML placed inside the response envelope's Body.
+	Response string
+	// ResponseTemplate, if set, is rendered with text/template against
+	// SOAPTemplateData in place of Response.
+	ResponseTemplate string
+	// Fault, if set, is returned in place of a response.
+	Fault *SOAPFault
+}
+
+// SOAPFault is a SOAP fault returned with a 500.
+type SOAPFault struct {
+	// Code defaults to a server (receiver) fault.
+	Code   string
+	String string
+	// Detail is raw XML placed in the fault's detail.
+	Detail string
+}
+
+// SOAPTemplateData is made available to a SOAPOperation's
+// ResponseTemplate.
+type SOAPTemplateData struct {
+	TemplateData
+	// Operation is the local name of the request's operation element
+	// and Body the raw XML inside it.
+	Operation string
+	Body      string
+}
+
+// AddSOAP registers the endpoint serving s.
+func (f *FakeService) AddSOAP(s *SOAP) {
+	if s.Path == "" {
+		s.Path = "/"
+	}
+	s.calls = map[*SOAPOperation]int{}
+	f.AddEndpoint(&Endpoint{
+		Path:   s.Path,
+		Method: http.MethodPost,
+		Handler: func(c *gin.Context) {
+			s.serve(f, c)
+		},
+	})
+}
+
+// Operation returns the first operation stubbed for the element name,
+// such as those created by SOAPFromWSDL, so its response can be set.
+func (s *SOAP) Operation(element string) *SOAPOperation {
+	for _, op := range s.Operations {
+		if op.Element == element {
+			return op
+		}
+	}
+	return nil
+}
+
+// soapEnvelope picks the operation element out of a request.
+type soapEnvelope struct {
+	Body struct {
+		Operation struct {
+			XMLName xml.Name
+			Inner   string `xml:",innerxml"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+func (s *SOAP) serve(f *FakeService, c *gin.Context) {
+	version := soap11Namespace
+	action := strings.Trim(c.GetHeader("SOAPAction"), `"`)
+	if mediaType, params, err := mime.ParseMediaType(c.ContentType()); err == nil && mediaType == "application/soap+xml" {
+		version = soap12Namespace
+		if action == "" {
+			action = params["action"]
+		}
+	}
+
+	body, _ := io.ReadAll(c.Request.Body)
+	rewindBody(c.Request, body)
+	var envelope soapEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		writeSOAPFault(c, version, &SOAPFault{Code: "Client", String: fmt.Sprintf("invalid SOAP envelope: %s", err)})
+		return
+	}
+	element := envelope.Body.Operation.XMLName.Local
+
+	op := s.match(action, element)
+	if op == nil {
+		writeSOAPFault(c, version, &SOAPFault{
+			Code:   "Client",
+			String: fmt.Sprintf("no stub for SOAP operation %q (action %q)", element, action),
+		})
+		return
+	}
+	s.mutex.Lock()
+	s.calls[op]++
+	calls := s.calls[op]
+	s.mutex.Unlock()
+
+	if op.Fault != nil {
+		writeSOAPFault(c, version, op.Fault)
+		return
+	}
+	response := op.Response
+	if op.ResponseTemplate != "" {
+		var err error
+		response, err = renderTemplate(op.ResponseTemplate, SOAPTemplateData{
+			TemplateData: TemplateData{CallCount: calls, State: f.State, Request: c.Request},
+			Operation:    element,
+			Body:         envelope.Body.Operation.Inner,
+		})
+		if err != nil {
+			writeSOAPFault(c, version, &SOAPFault{String: fmt.Sprintf("failed to render response template: %s", err)})
+			return
+		}
+	}
+	writeSOAPEnvelope(c, version, http.StatusOK, response)
+}
+
+func (s *SOAP) match(action, element string) *SOAPOperation {
+	for _, op := range s.Operations {
+		if op.Action != "" && op.Action != action {
+			continue
+		}
+		if op.Element != "" && op.Element != element {
+			continue
+		}
+		return op
+	}
+	return nil
+}
+
+func writeSOAPEnvelope(c *gin.Context, version string, status int, body string) {
+	contentType := "text/xml; charset=utf-8"
+	if version == soap12Namespace {
+		contentType = "application/soap+xml; charset=utf-8"
+	}
+	c.Data(status, contentType, []byte(xml.Header+
+		`<soap:Envelope xmlns:soap="`+version+`"><soap:Body>`+body+`</soap:Body></soap:Envelope>`))
+}
+
+func writeSOAPFault(c *gin.Context, version string, fault *SOAPFault) {
+	var b bytes.Buffer
+	if version == soap12Namespace {
+		code := fault.Code
+		switch code {
+		case "", "Server":
+			code = "Receiver"
+		case "Client":
+			code = "Sender"
+		}
+		b.WriteString(`<soap:Fault><soap:Code><soap:Value>soap:` + code + `</soap:Value></soap:Code>`)
+		b.WriteString(`<soap:Reason><soap:Text xml:lang="en">`)
+		xml.EscapeText(&b, []byte(fault.String))
+		b.WriteString(`</soap:Text></soap:Reason>`)
+		if fault.Detail != "" {
+			b.WriteString(`<soap:Detail>` + fault.Detail + `</soap:Detail>`)
+		}
+		b.WriteString(`</soap:Fault>`)
+	} else {
+		code := fault.Code
+		if code == "" {
+			code = "Server"
+		}
+		b.WriteString(`<soap:Fault><faultcode>soap:` + code + `</faultcode><faultstring>`)
+		xml.EscapeText(&b, []byte(fault.String))
+		b.WriteString(`</faultstring>`)
+		if fault.Detail != "" {
+			b.WriteString(`<detail>` + fault.Detail + `</detail>`)
+		}
+		b.WriteString(`</soap:Fault>`)
+	}
+	writeSOAPEnvelope(c, version, http.StatusInternalServerError, b.String())
+}
+
+// wsdlDefinitions holds the parts of a WSDL 1.1 document needed to stub
+// its operations.
+type wsdlDefinitions struct {
+	Bindings []struct {
+		Operations []struct {
+			Name string `xml:"name,attr"`
+			SOAP struct {
+				Action string `xml:"soapAction,attr"`
+			} `xml:"operation"`
+		} `xml:"operation"`
+	} `xml:"binding"`
+}
+
+// SOAPFromWSDL creates a stub for every operation bound in a WSDL 1.1
+// document, matched on its operation element and answering with an
+// empty <NameResponse/> element. Use SOAP.Operation to fill in real
+// responses.
+func SOAPFromWSDL(path string, wsdl io.Reader) (*SOAP, error) {
+	var defs wsdlDefinitions
+	if err := xml.NewDecoder(wsdl).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("failed to parse WSDL: %w", err)
+	}
+	s := &SOAP{Path: path}
+	seen := map[string]bool{}
+	for _, binding := range defs.Bindings {
+		for _, op := range binding.Operations {
+			if op.Name == "" || seen[op.Name] {
+				continue
+			}
+			seen[op.Name] = true
+			s.Operations = append(s.Operations, &SOAPOperation{
+				Element:  op.Name,
+				Response: "<" + op.Name + "Response/>",
+			})
+		}
+	}
+	if len(s.Operations) == 0 {
+		return nil, fmt.Errorf("WSDL has no bound operations")
+	}
+	return s, nil
+}
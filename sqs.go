@@ -0,0 +1,722 @@
+package fake
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sqsAccountID is the account queue URLs are made under.
+const sqsAccountID = "000000000000"
+
+// SQS fakes Amazon SQS with in-memory queues. It speaks both the JSON
+// protocol used by current AWS SDKs and the older query protocol, and
+// supports CreateQueue, GetQueueUrl, ListQueues, SendMessage,
+// ReceiveMessage with long polling, DeleteMessage,
+// ChangeMessageVisibility and PurgeQueue. Received messages stay
+// invisible for their visibility timeout and are redelivered unless
+// they are deleted before it runs out.
+type SQS struct {
+	// VisibilityTimeout is how long received messages stay invisible
+	// unless the receive asks otherwise, 30 seconds by default.
+	VisibilityTimeout time.Duration
+
+	f       *FakeService
+	mutex   sync.Mutex
+	queues  map[string]*sqsQueue
+	changed chan struct{}
+}
+
+// SQSMessage is a message sent to an SQS fake.
+type SQSMessage struct {
+	ID         string
+	Body       string
+	Attributes map[string]SQSAttribute
+	SentAt     time.Time
+	// ReceiveCount is how many times the message has been received.
+	ReceiveCount int
+}
+
+// SQSAttribute is a message attribute. DataType is String, Number or
+// Binary, optionally followed by a custom type such as "String.JSON".
+type SQSAttribute struct {
+	DataType    string `json:"DataType"`
+	StringValue string `json:"StringValue,omitempty"`
+	BinaryValue []byte `json:"BinaryValue,omitempty"`
+}
+
+type sqsQueue struct {
+	name     string
+	messages []*sqsMessage
+	sent     []SQSMessage
+}
+
+type sqsMessage struct {
+	SQSMessage
+	receiptHandle string
+	visibleAt     time.Time
+	firstReceived time.Time
+}
+
+// AddSQS registers the endpoints for s: the root, where the JSON
+// protocol sends every action, and the queue URLs, where the query
+// protocol sends actions on a queue. Tests don't always use both, so
+// the endpoints are Optional.
+func (f *FakeService) AddSQS(s *SQS) {
+	if s.VisibilityTimeout == 0 {
+		s.VisibilityTimeout = 30 * time.Second
+	}
+	s.f = f
+	f.AddEndpoint(&Endpoint{Path: "/", Method: http.MethodPost, Handler: s.serve, Optional: true})
+	f.AddEndpoint(&Endpoint{Path: "/" + sqsAccountID + "/:queue", Method: http.MethodPost, Handler: s.serve, Optional: true})
+}
+
+// CreateQueue creates the named queue, if it doesn't exist, and returns
+// its URL.
+func (s *SQS) CreateQueue(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queue(name, true)
+	return s.queueURL(name)
+}
+
+// Send sends a message to the named queue, creating it if need be, for
+// testing consumers.
+func (s *SQS) Send(queue, body string) SQSMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.send(s.queue(queue, true), body, nil, 0)
+}
+
+// Sent returns every message sent to the named queue, oldest first,
+// whether or not it has since been deleted.
+func (s *SQS) Sent(queue string) []SQSMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q := s.queue(queue, false)
+	if q == nil {
+		return nil
+	}
+	return append([]SQSMessage(nil), q.sent...)
+}
+
+// Messages returns the messages in the named queue that haven't been
+// deleted, oldest first.
+func (s *SQS) Messages(queue string) []SQSMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q := s.queue(queue, false)
+	if q == nil {
+		return nil
+	}
+	messages := make([]SQSMessage, len(q.messages))
+	for i, m := range q.messages {
+		messages[i] = m.SQSMessage
+	}
+	return messages
+}
+
+func (s *SQS) queue(name string, create bool) *sqsQueue {
+	if s.queues == nil {
+		s.queues = map[string]*sqsQueue{}
+	}
+	q, ok := s.queues[name]
+	if !ok && create {
+		q = &sqsQueue{name: name}
+		s.queues[name] = q
+	}
+	return q
+}
+
+func (s *SQS) queueURL(name string) string {
+	return s.f.BaseURL() + "/" + sqsAccountID + "/" + name
+}
+
+func (s *SQS) send(q *sqsQueue, body string, attributes map[string]SQSAttribute, delay time.Duration) SQSMessage {
+	now := time.Now()
+	m := &sqsMessage{
+		SQSMessage: SQSMessage{ID: newUUID(), Body: body, Attributes: attributes, SentAt: now},
+		visibleAt:  now.Add(delay),
+	}
+	q.messages = append(q.messages, m)
+	q.sent = append(q.sent, m.SQSMessage)
+	s.notify()
+	return m.SQSMessage
+}
+
+// notify wakes long polls waiting for a message. It must be called
+// with the mutex held.
+func (s *SQS) notify() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
+// sqsInput holds the parameters of every action, read from either
+// protocol.
+type sqsInput struct {
+	QueueName                   string                  `json:"QueueName"`
+	QueueURL                    string                  `json:"QueueUrl"`
+	QueueNamePrefix             string                  `json:"QueueNamePrefix"`
+	MessageBody                 string                  `json:"MessageBody"`
+	DelaySeconds                int                     `json:"DelaySeconds"`
+	MessageAttributes           map[string]SQSAttribute `json:"MessageAttributes"`
+	ReceiptHandle               string                  `json:"ReceiptHandle"`
+	MaxNumberOfMessages         int                     `json:"MaxNumberOfMessages"`
+	WaitTimeSeconds             int                     `json:"WaitTimeSeconds"`
+	VisibilityTimeout           *int                    `json:"VisibilityTimeout"`
+	AttributeNames              []string                `json:"AttributeNames"`
+	MessageSystemAttributeNames []string                `json:"MessageSystemAttributeNames"`
+	MessageAttributeNames       []string                `json:"MessageAttributeNames"`
+}
+
+type sqsError struct {
+	status int
+	// code names the error in the query protocol and name in the JSON
+	// protocol.
+	code    string
+	name    string
+	message string
+}
+
+func sqsNonExistentQueue() *sqsError {
+	return &sqsError{http.StatusBadRequest, "AWS.SimpleQueueService.NonExistentQueue", "QueueDoesNotExist", "The specified queue does not exist."}
+}
+
+func sqsMissingParameter(name string) *sqsError {
+	return &sqsError{http.StatusBadRequest, "MissingParameter", "MissingParameter", fmt.Sprintf("The request must contain the parameter %s.", name)}
+}
+
+func (s *SQS) serve(c *gin.Context) {
+	var (
+		action string
+		input  sqsInput
+		isJSON bool
+	)
+	if target := c.GetHeader("X-Amz-Target"); target != "" {
+		isJSON = true
+		action = strings.TrimPrefix(target, "AmazonSQS.")
+		if err := json.NewDecoder(c.Request.Body).Decode(&input); err != nil {
+			s.fail(c, isJSON, action, &sqsError{http.StatusBadRequest, "MalformedQueryString", "InvalidParameterValue", err.Error()})
+			return
+		}
+	} else {
+		action = c.PostForm("Action")
+		if action == "" {
+			action = c.Query("Action")
+		}
+		if err := readSQSQuery(c, &input); err != nil {
+			s.fail(c, isJSON, action, err)
+			return
+		}
+	}
+	if input.QueueURL == "" && c.Param("queue") != "" {
+		input.QueueURL = c.Param("queue")
+	}
+
+	var (
+		result any
+		err    *sqsError
+	)
+	switch action {
+	case "CreateQueue":
+		result, err = s.createQueue(input)
+	case "GetQueueUrl":
+		result, err = s.getQueueURL(input)
+	case "ListQueues":
+		result, err = s.listQueues(input)
+	case "SendMessage":
+		result, err = s.sendMessage(input)
+	case "ReceiveMessage":
+		result, err = s.receiveMessage(c, input)
+	case "DeleteMessage":
+		err = s.deleteMessage(input)
+	case "ChangeMessageVisibility":
+		err = s.changeMessageVisibility(input)
+	case "PurgeQueue":
+		err = s.purgeQueue(input)
+	default:
+		err = &sqsError{http.StatusBadRequest, "InvalidAction", "InvalidAction", fmt.Sprintf("The action %s is not valid for this endpoint.", action)}
+	}
+	if err != nil {
+		s.fail(c, isJSON, action, err)
+		return
+	}
+	s.respond(c, isJSON, action, result)
+}
+
+type sqsQueueURLResult struct {
+	QueueURL string `json:"QueueUrl" xml:"QueueUrl"`
+}
+
+type sqsListQueuesResult struct {
+	QueueURLs []string `json:"QueueUrls,omitempty" xml:"QueueUrl"`
+}
+
+type sqsSendMessageResult struct {
+	MessageID              string `json:"MessageId" xml:"MessageId"`
+	MD5OfMessageBody       string `json:"MD5OfMessageBody" xml:"MD5OfMessageBody"`
+	MD5OfMessageAttributes string `json:"MD5OfMessageAttributes,omitempty" xml:"MD5OfMessageAttributes,omitempty"`
+}
+
+type sqsReceiveMessageResult struct {
+	Messages []sqsMessageOutput `json:"Messages,omitempty" xml:"Message"`
+}
+
+type sqsMessageOutput struct {
+	MessageID              string          `json:"MessageId" xml:"MessageId"`
+	ReceiptHandle          string          `json:"ReceiptHandle" xml:"ReceiptHandle"`
+	MD5OfBody              string          `json:"MD5OfBody" xml:"MD5OfBody"`
+	Body                   string          `json:"Body" xml:"Body"`
+	Attributes             sqsStringMap    `json:"Attributes,omitempty" xml:"Attribute,omitempty"`
+	MD5OfMessageAttributes string          `json:"MD5OfMessageAttributes,omitempty" xml:"MD5OfMessageAttributes,omitempty"`
+	MessageAttributes      sqsAttributeMap `json:"MessageAttributes,omitempty" xml:"MessageAttribute,omitempty"`
+}
+
+func (s *SQS) createQueue(input sqsInput) (any, *sqsError) {
+	if input.QueueName == "" {
+		return nil, sqsMissingParameter("QueueName")
+	}
+	return sqsQueueURLResult{QueueURL: s.CreateQueue(input.QueueName)}, nil
+}
+
+func (s *SQS) getQueueURL(input sqsInput) (any, *sqsError) {
+	if input.QueueName == "" {
+		return nil, sqsMissingParameter("QueueName")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.queue(input.QueueName, false) == nil {
+		return nil, sqsNonExistentQueue()
+	}
+	return sqsQueueURLResult{QueueURL: s.queueURL(input.QueueName)}, nil
+}
+
+func (s *SQS) listQueues(input sqsInput) (any, *sqsError) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var result sqsListQueuesResult
+	for name := range s.queues {
+		if strings.HasPrefix(name, input.QueueNamePrefix) {
+			result.QueueURLs = append(result.QueueURLs, s.queueURL(name))
+		}
+	}
+	sort.Strings(result.QueueURLs)
+	return result, nil
+}
+
+// lookup finds the queue a request names by its URL, or the last
+// segment of it.
+func (s *SQS) lookup(input sqsInput) (*sqsQueue, *sqsError) {
+	if input.QueueURL == "" {
+		return nil, sqsMissingParameter("QueueUrl")
+	}
+	name := input.QueueURL[strings.LastIndex(input.QueueURL, "/")+1:]
+	q := s.queue(name, false)
+	if q == nil {
+		return nil, sqsNonExistentQueue()
+	}
+	return q, nil
+}
+
+func (s *SQS) sendMessage(input sqsInput) (any, *sqsError) {
+	if input.MessageBody == "" {
+		return nil, sqsMissingParameter("MessageBody")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q, err := s.lookup(input)
+	if err != nil {
+		return nil, err
+	}
+	m := s.send(q, input.MessageBody, input.MessageAttributes, time.Duration(input.DelaySeconds)*time.Second)
+	return sqsSendMessageResult{
+		MessageID:              m.ID,
+		MD5OfMessageBody:       md5Hex([]byte(m.Body)),
+		MD5OfMessageAttributes: sqsAttributesMD5(m.Attributes),
+	}, nil
+}
+
+// sqsPollInterval is how often long polls check for messages whose
+// visibility timeout has run out.
+const sqsPollInterval = 50 * time.Millisecond
+
+func (s *SQS) receiveMessage(c *gin.Context, input sqsInput) (any, *sqsError) {
+	max := input.MaxNumberOfMessages
+	if max == 0 {
+		max = 1
+	}
+	visibility := s.VisibilityTimeout
+	if input.VisibilityTimeout != nil {
+		visibility = time.Duration(*input.VisibilityTimeout) * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(input.WaitTimeSeconds) * time.Second)
+
+	for {
+		s.mutex.Lock()
+		q, err := s.lookup(input)
+		if err != nil {
+			s.mutex.Unlock()
+			return nil, err
+		}
+		received := s.take(q, max, visibility)
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		changed := s.changed
+		s.mutex.Unlock()
+
+		remaining := time.Until(deadline)
+		if len(received) > 0 || remaining <= 0 {
+			var result sqsReceiveMessageResult
+			for _, m := range received {
+				result.Messages = append(result.Messages, m.output(input))
+			}
+			return result, nil
+		}
+		if remaining > sqsPollInterval {
+			remaining = sqsPollInterval
+		}
+		select {
+		case <-changed:
+		case <-time.After(remaining):
+		case <-c.Request.Context().Done():
+			return sqsReceiveMessageResult{}, nil
+		}
+	}
+}
+
+// take receives up to max visible messages from q, hiding them for the
+// visibility timeout. It must be called with the mutex held.
+func (s *SQS) take(q *sqsQueue, max int, visibility time.Duration) []sqsMessage {
+	now := time.Now()
+	var received []sqsMessage
+	for _, m := range q.messages {
+		if len(received) == max {
+			break
+		}
+		if now.Before(m.visibleAt) {
+			continue
+		}
+		m.receiptHandle = randomToken()
+		m.visibleAt = now.Add(visibility)
+		m.ReceiveCount++
+		if m.firstReceived.IsZero() {
+			m.firstReceived = now
+		}
+		received = append(received, *m)
+	}
+	return received
+}
+
+func (m sqsMessage) output(input sqsInput) sqsMessageOutput {
+	out := sqsMessageOutput{
+		MessageID:     m.ID,
+		ReceiptHandle: m.receiptHandle,
+		MD5OfBody:     md5Hex([]byte(m.Body)),
+		Body:          m.Body,
+	}
+	system := map[string]string{
+		"SenderId":                         sqsAccountID,
+		"SentTimestamp":                    strconv.FormatInt(m.SentAt.UnixMilli(), 10),
+		"ApproximateReceiveCount":          strconv.Itoa(m.ReceiveCount),
+		"ApproximateFirstReceiveTimestamp": strconv.FormatInt(m.firstReceived.UnixMilli(), 10),
+	}
+	for _, name := range append(input.AttributeNames, input.MessageSystemAttributeNames...) {
+		for key, value := range system {
+			if name == "All" || name == key {
+				if out.Attributes == nil {
+					out.Attributes = sqsStringMap{}
+				}
+				out.Attributes[key] = value
+			}
+		}
+	}
+	for _, name := range input.MessageAttributeNames {
+		for key, value := range m.Attributes {
+			if name == "All" || name == ".*" || name == key || (strings.HasSuffix(name, ".*") && strings.HasPrefix(key, strings.TrimSuffix(name, "*"))) {
+				if out.MessageAttributes == nil {
+					out.MessageAttributes = sqsAttributeMap{}
+				}
+				out.MessageAttributes[key] = value
+			}
+		}
+	}
+	out.MD5OfMessageAttributes = sqsAttributesMD5(out.MessageAttributes)
+	return out
+}
+
+func (s *SQS) deleteMessage(input sqsInput) *sqsError {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q, err := s.lookup(input)
+	if err != nil {
+		return err
+	}
+	for i, m := range q.messages {
+		if m.receiptHandle != "" && m.receiptHandle == input.ReceiptHandle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return nil
+		}
+	}
+	return &sqsError{http.StatusBadRequest, "ReceiptHandleIsInvalid", "ReceiptHandleIsInvalid", "The input receipt handle is invalid."}
+}
+
+func (s *SQS) changeMessageVisibility(input sqsInput) *sqsError {
+	if input.VisibilityTimeout == nil {
+		return sqsMissingParameter("VisibilityTimeout")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q, err := s.lookup(input)
+	if err != nil {
+		return err
+	}
+	for _, m := range q.messages {
+		if m.receiptHandle != "" && m.receiptHandle == input.ReceiptHandle {
+			m.visibleAt = time.Now().Add(time.Duration(*input.VisibilityTimeout) * time.Second)
+			s.notify()
+			return nil
+		}
+	}
+	return &sqsError{http.StatusBadRequest, "ReceiptHandleIsInvalid", "ReceiptHandleIsInvalid", "The input receipt handle is invalid."}
+}
+
+func (s *SQS) purgeQueue(input sqsInput) *sqsError {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q, err := s.lookup(input)
+	if err != nil {
+		return err
+	}
+	q.messages = nil
+	return nil
+}
+
+// readSQSQuery reads the parameters of a query protocol request, whose
+// lists are flattened into numbered parameters such as
+// MessageAttribute.1.Name.
+func readSQSQuery(c *gin.Context, input *sqsInput) *sqsError {
+	if err := c.Request.ParseForm(); err != nil {
+		return &sqsError{http.StatusBadRequest, "MalformedQueryString", "InvalidParameterValue", err.Error()}
+	}
+	form := c.Request.Form
+	input.QueueName = form.Get("QueueName")
+	input.QueueURL = form.Get("QueueUrl")
+	input.QueueNamePrefix = form.Get("QueueNamePrefix")
+	input.MessageBody = form.Get("MessageBody")
+	input.ReceiptHandle = form.Get("ReceiptHandle")
+
+	for name, target := range map[string]*int{
+		"DelaySeconds":        &input.DelaySeconds,
+		"MaxNumberOfMessages": &input.MaxNumberOfMessages,
+		"WaitTimeSeconds":     &input.WaitTimeSeconds,
+	} {
+		if value := form.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return &sqsError{http.StatusBadRequest, "InvalidParameterValue", "InvalidParameterValue", fmt.Sprintf("%s must be a number", name)}
+			}
+			*target = n
+		}
+	}
+	if value := form.Get("VisibilityTimeout"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return &sqsError{http.StatusBadRequest, "InvalidParameterValue", "InvalidParameterValue", "VisibilityTimeout must be a number"}
+		}
+		input.VisibilityTimeout = &n
+	}
+
+	for i := 1; ; i++ {
+		found := false
+		prefix := strconv.Itoa(i)
+		if name := form.Get("AttributeName." + prefix); name != "" {
+			input.AttributeNames = append(input.AttributeNames, name)
+			found = true
+		}
+		if name := form.Get("MessageSystemAttributeName." + prefix); name != "" {
+			input.MessageSystemAttributeNames = append(input.MessageSystemAttributeNames, name)
+			found = true
+		}
+		if name := form.Get("MessageAttributeName." + prefix); name != "" {
+			input.MessageAttributeNames = append(input.MessageAttributeNames, name)
+			found = true
+		}
+		if name := form.Get("MessageAttribute." + prefix + ".Name"); name != "" {
+			attribute := SQSAttribute{
+				DataType:    form.Get("MessageAttribute." + prefix + ".Value.DataType"),
+				StringValue: form.Get("MessageAttribute." + prefix + ".Value.StringValue"),
+			}
+			if binary := form.Get("MessageAttribute." + prefix + ".Value.BinaryValue"); binary != "" {
+				value, err := base64.StdEncoding.DecodeString(binary)
+				if err != nil {
+					return &sqsError{http.StatusBadRequest, "InvalidParameterValue", "InvalidParameterValue", "BinaryValue must be base64"}
+				}
+				attribute.BinaryValue = value
+			}
+			if input.MessageAttributes == nil {
+				input.MessageAttributes = map[string]SQSAttribute{}
+			}
+			input.MessageAttributes[name] = attribute
+			found = true
+		}
+		if !found {
+			return nil
+		}
+	}
+}
+
+func (s *SQS) respond(c *gin.Context, isJSON bool, action string, result any) {
+	if isJSON {
+		if result == nil {
+			result = struct{}{}
+		}
+		data, _ := json.Marshal(result)
+		c.Data(http.StatusOK, "application/x-amz-json-1.0", data)
+		return
+	}
+
+	var buf strings.Builder
+	enc := xml.NewEncoder(&buf)
+	response := xml.StartElement{
+		Name: xml.Name{Local: action + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "http://queue.amazonaws.com/doc/2012-11-05/"}},
+	}
+	enc.EncodeToken(response)
+	if result != nil {
+		if err := enc.EncodeElement(result, xml.StartElement{Name: xml.Name{Local: action + "Result"}}); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	enc.EncodeElement(struct {
+		RequestID string `xml:"RequestId"`
+	}{newUUID()}, xml.StartElement{Name: xml.Name{Local: "ResponseMetadata"}})
+	enc.EncodeToken(response.End())
+	enc.Flush()
+	c.Data(http.StatusOK, "text/xml", []byte(buf.String()))
+}
+
+func (s *SQS) fail(c *gin.Context, isJSON bool, action string, err *sqsError) {
+	if isJSON {
+		c.Header("X-Amzn-Query-Error", err.code+";Sender")
+		data, _ := json.Marshal(map[string]string{"__type": "com.amazonaws.sqs#" + err.name, "message": err.message})
+		c.Data(err.status, "application/x-amz-json-1.0", data)
+		return
+	}
+	var response struct {
+		XMLName xml.Name `xml:"ErrorResponse"`
+		Error   struct {
+			Type    string `xml:"Type"`
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+		RequestID string `xml:"RequestId"`
+	}
+	response.Error.Type = "Sender"
+	response.Error.Code = err.code
+	response.Error.Message = err.message
+	response.RequestID = newUUID()
+	data, _ := xml.Marshal(response)
+	c.Data(err.status, "text/xml", data)
+}
+
+// sqsStringMap holds system attributes, which the query protocol lists
+// as repeated Name and Value pairs.
+type sqsStringMap map[string]string
+
+func (m sqsStringMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	for _, name := range sortedKeys(m) {
+		pair := struct {
+			Name  string `xml:"Name"`
+			Value string `xml:"Value"`
+		}{name, m[name]}
+		if err := e.EncodeElement(pair, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqsAttributeMap holds message attributes, which the query protocol
+// lists as repeated Name and Value pairs.
+type sqsAttributeMap map[string]SQSAttribute
+
+func (m sqsAttributeMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	for _, name := range sortedKeys(m) {
+		attribute := m[name]
+		type value struct {
+			DataType    string `xml:"DataType"`
+			StringValue string `xml:"StringValue,omitempty"`
+			BinaryValue string `xml:"BinaryValue,omitempty"`
+		}
+		pair := struct {
+			Name  string `xml:"Name"`
+			Value value  `xml:"Value"`
+		}{name, value{
+			DataType:    attribute.DataType,
+			StringValue: attribute.StringValue,
+			BinaryValue: base64.StdEncoding.EncodeToString(attribute.BinaryValue),
+		}}
+		if err := e.EncodeElement(pair, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqsAttributesMD5 is the digest of message attributes SQS returns for
+// clients to check them, or empty if there are none.
+func sqsAttributesMD5(attributes map[string]SQSAttribute) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+	hash := md5.New()
+	writeField := func(b []byte) {
+		binary.Write(hash, binary.BigEndian, uint32(len(b)))
+		hash.Write(b)
+	}
+	for _, name := range sortedKeys(attributes) {
+		attribute := attributes[name]
+		writeField([]byte(name))
+		writeField([]byte(attribute.DataType))
+		if strings.HasPrefix(attribute.DataType, "Binary") {
+			hash.Write([]byte{2})
+			writeField(attribute.BinaryValue)
+		} else {
+			hash.Write([]byte{1})
+			writeField([]byte(attribute.StringValue))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// newUUID returns a random version 4 UUID, as SQS uses for message and
+// request IDs.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
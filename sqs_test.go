@@ -0,0 +1,136 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// sqsCall makes a JSON protocol call to s, decoding the response into
+// out.
+func sqsCall(t *testing.T, f *FakeService, action string, input map[string]any, out any) {
+	t.Helper()
+	body, _ := json.Marshal(input)
+	req, _ := http.NewRequest(http.MethodPost, f.BaseURL()+"/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: status %d", action, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type sqsTestMessages struct {
+	Messages []struct {
+		Body                   string
+		ReceiptHandle          string
+		MD5OfMessageAttributes string
+		Attributes             map[string]string
+	}
+}
+
+func TestSQSSendReceiveDelete(t *testing.T) {
+	s := &SQS{VisibilityTimeout: 100 * time.Millisecond}
+	f := New()
+	f.AddSQS(s)
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	url := s.CreateQueue("orders")
+	sqsCall(t, f, "SendMessage", map[string]any{"QueueUrl": url, "MessageBody": "order 1"}, nil)
+
+	receive := func() sqsTestMessages {
+		var out sqsTestMessages
+		sqsCall(t, f, "ReceiveMessage", map[string]any{"QueueUrl": url, "AttributeNames": []string{"ApproximateReceiveCount"}}, &out)
+		return out
+	}
+	first := receive()
+	if len(first.Messages) != 1 || first.Messages[0].Body != "order 1" {
+		t.Fatalf("first receive = %+v", first)
+	}
+	if got := receive(); len(got.Messages) != 0 {
+		t.Fatalf("received %d messages during the visibility timeout", len(got.Messages))
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	again := receive()
+	if len(again.Messages) != 1 {
+		t.Fatalf("message wasn't redelivered after the visibility timeout")
+	}
+	if count := again.Messages[0].Attributes["ApproximateReceiveCount"]; count != "2" {
+		t.Errorf("ApproximateReceiveCount = %q, want 2", count)
+	}
+
+	sqsCall(t, f, "DeleteMessage", map[string]any{"QueueUrl": url, "ReceiptHandle": again.Messages[0].ReceiptHandle}, nil)
+	if messages := s.Messages("orders"); len(messages) != 0 {
+		t.Errorf("%d messages left after delete", len(messages))
+	}
+	if sent := s.Sent("orders"); len(sent) != 1 {
+		t.Errorf("Sent returned %d messages, want 1", len(sent))
+	}
+}
+
+func TestSQSMessageAttributesMD5(t *testing.T) {
+	s := &SQS{}
+	f := New()
+	f.AddSQS(s)
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	// The digest covers each attribute, sorted by name, as its
+	// length-prefixed name and data type, a transport type byte and the
+	// length-prefixed value.
+	var encoded []byte
+	for _, field := range []struct {
+		name, dataType string
+		transport      byte
+		value          string
+	}{
+		{"count", "Number", 1, "3"},
+		{"id", "Binary", 2, "\x01\x02"},
+		{"kind", "String", 1, "order"},
+	} {
+		for _, v := range []string{field.name, field.dataType} {
+			encoded = append(encoded, 0, 0, 0, byte(len(v)))
+			encoded = append(encoded, v...)
+		}
+		encoded = append(encoded, field.transport, 0, 0, 0, byte(len(field.value)))
+		encoded = append(encoded, field.value...)
+	}
+	sum := md5.Sum(encoded)
+	want := hex.EncodeToString(sum[:])
+
+	url := s.CreateQueue("orders")
+	attributes := map[string]any{
+		"kind":  map[string]any{"DataType": "String", "StringValue": "order"},
+		"count": map[string]any{"DataType": "Number", "StringValue": "3"},
+		"id":    map[string]any{"DataType": "Binary", "BinaryValue": []byte{1, 2}},
+	}
+	var sent struct{ MD5OfMessageAttributes string }
+	sqsCall(t, f, "SendMessage", map[string]any{"QueueUrl": url, "MessageBody": "order 1", "MessageAttributes": attributes}, &sent)
+	if sent.MD5OfMessageAttributes != want {
+		t.Errorf("SendMessage MD5OfMessageAttributes = %s, want %s", sent.MD5OfMessageAttributes, want)
+	}
+
+	var received sqsTestMessages
+	sqsCall(t, f, "ReceiveMessage", map[string]any{"QueueUrl": url, "MessageAttributeNames": []string{"All"}}, &received)
+	if len(received.Messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(received.Messages))
+	}
+	if got := received.Messages[0].MD5OfMessageAttributes; got != want {
+		t.Errorf("ReceiveMessage MD5OfMessageAttributes = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package fake
+
+import (
+	"sort"
+	"sync"
+)
+
+// StateStore is a concurrency-safe key/value store shared between a
+// FakeService's handlers and the test driving it.
+type StateStore struct {
+	mutex  sync.RWMutex
+	values map[string]any
+}
+
+func newStateStore() *StateStore {
+	return &StateStore{values: map[string]any{}}
+}
+
+// Get returns the value stored under key, or nil if there isn't one.
+func (s *StateStore) Get(key string) any {
+	v, _ := s.Lookup(key)
+	return v
+}
+
+// Lookup returns the value stored under key and whether it was present.
+func (s *StateStore) Lookup(key string) (any, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set stores value under key, replacing any existing value.
+func (s *StateStore) Set(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.values[key] = value
+}
+
+// Delete removes key from the store.
+func (s *StateStore) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.values, key)
+}
+
+// Keys returns the stored keys in sorted order.
+func (s *StateStore) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Clear removes every key from the store.
+func (s *StateStore) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.values = map[string]any{}
+}
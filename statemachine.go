@@ -0,0 +1,148 @@
+package fake
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StateMachine models a stateful protocol, such as a provisioning
+// workflow, as a finite state machine. Requests matching a Transition
+// move the machine between states, and the response served depends on
+// the state the machine is in once any transition has been applied.
+//
+// The machine's current state is tracked as a scenario under Name, so
+// it can be read with ScenarioState and is cleared by Reset.
+type StateMachine struct {
+	Name    string
+	Initial string
+
+	// Transitions are evaluated in order and the first whose From,
+	// Method, Path and Guard all match the request is applied, so the
+	// same request can lead to different states depending on guards.
+	Transitions []Transition
+
+	// Responses are evaluated in order and the first matching the
+	// current state and request is served. If none match, a 200 with
+	// the current state as JSON is returned.
+	Responses []StateResponse
+}
+
+// Transition moves a StateMachine from one state to another. Empty
+// From, Method or Path fields match anything.
+type Transition struct {
+	From   string
+	To     string
+	Method string
+	Path   string
+	// Guard, if set, must return true for the transition to apply.
+	// The request body can be read freely within a guard.
+	Guard func(*http.Request) bool
+}
+
+// StateResponse is served while a StateMachine is in State. Empty
+// Method or Path fields match anything.
+type StateResponse struct {
+	State      string
+	Method     string
+	Path       string
+	StatusCode int
+	Response   string
+}
+
+// AddStateMachine registers an endpoint for every path referenced by
+// the machine's transitions and responses.
+func (f *FakeService) AddStateMachine(m *StateMachine) {
+	if m.Initial == "" {
+		m.Initial = ScenarioStarted
+	}
+
+	var paths []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, t := range m.Transitions {
+		add(t.Path)
+	}
+	for _, r := range m.Responses {
+		add(r.Path)
+	}
+
+	for _, path := range paths {
+		f.AddEndpoint(&Endpoint{
+			Path:    path,
+			Handler: m.handler(f, path),
+		})
+	}
+}
+
+func (m *StateMachine) handler(f *FakeService, path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Guards may need to read the body, so buffer it up front and
+		// hand each guard a fresh reader.
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+		}
+		rewind := func() {
+			rewindBody(c.Request, body)
+		}
+
+		current := f.scenarios.stateOr(m.Name, m.Initial)
+	transitions:
+		for {
+			for _, t := range m.Transitions {
+				if t.From != "" && t.From != current {
+					continue
+				}
+				if !matchesRoute(t.Method, t.Path, c.Request.Method, path) {
+					continue
+				}
+				rewind()
+				if t.Guard != nil && !t.Guard(c.Request) {
+					continue
+				}
+				if f.scenarios.moveIf(m.Name, m.Initial, current, t.To, c.Request) {
+					current = t.To
+					break transitions
+				}
+				// A concurrent call moved the machine on first, so
+				// start again from the state it is in now.
+				current = f.scenarios.stateOr(m.Name, m.Initial)
+				continue transitions
+			}
+			break
+		}
+		rewind()
+
+		for _, r := range m.Responses {
+			if r.State != current || !matchesRoute(r.Method, r.Path, c.Request.Method, path) {
+				continue
+			}
+			status := r.StatusCode
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if !bodyAllowedForStatus(status) || r.Response == "" {
+				c.Status(status)
+				return
+			}
+			c.String(status, r.Response)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"state": current})
+	}
+}
+
+func matchesRoute(method, path, reqMethod, reqPath string) bool {
+	if method != "" && !strings.EqualFold(method, reqMethod) {
+		return false
+	}
+	return path == "" || path == reqPath
+}
@@ -0,0 +1,45 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"text/template"
+)
+
+// TemplateData is made available to an endpoint's ResponseTemplate.
+type TemplateData struct {
+	// CallCount is the number of times the endpoint has been called,
+	// including the current call.
+	CallCount int
+	// State is the FakeService's shared state store.
+	State *StateStore
+	// Request is the request being responded to.
+	Request *http.Request
+	// Body is the request's body, and JSON the body decoded if it is
+	// JSON, so templates can echo fields such as {{.JSON.id}}.
+	Body string
+	JSON any
+}
+
+// newTemplateData returns the data for rendering a template in answer
+// to a call, whose body is passed separately as it may have been read.
+func newTemplateData(call int, state *StateStore, r *http.Request, body []byte) TemplateData {
+	data := TemplateData{CallCount: call, State: state, Request: r, Body: string(body)}
+	if err := json.Unmarshal(body, &data.JSON); err != nil {
+		data.JSON = nil
+	}
+	return data
+}
+
+func renderTemplate(text string, data any) (string, error) {
+	tmpl, err := template.New("response").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
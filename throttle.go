@@ -0,0 +1,54 @@
+package fake
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pacedWriter drips the response body out at a fixed rate, flushing
+// as it goes so the client sees the body arrive gradually.
+type pacedWriter struct {
+	gin.ResponseWriter
+	ctx            context.Context
+	bytesPerSecond int
+}
+
+// pacingInterval is roughly how often a pacedWriter writes a chunk.
+const pacingInterval = 100 * time.Millisecond
+
+func (w *pacedWriter) Write(b []byte) (int, error) {
+	chunk := w.bytesPerSecond * int(pacingInterval) / int(time.Second)
+	if chunk < 1 {
+		chunk = 1
+	}
+	pause := time.Duration(chunk) * time.Second / time.Duration(w.bytesPerSecond)
+
+	written := 0
+	for written < len(b) {
+		end := written + chunk
+		if end > len(b) {
+			end = len(b)
+		}
+		n, err := w.ResponseWriter.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		w.ResponseWriter.Flush()
+
+		if written < len(b) {
+			select {
+			case <-time.After(pause):
+			case <-w.ctx.Done():
+				return written, w.ctx.Err()
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *pacedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,235 @@
+package fake
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithTLS serves over HTTPS with a self-signed certificate generated
+// for the test run, valid for localhost, 127.0.0.1 and ::1. Client
+// returns an *http.Client that trusts it and CertPool a pool holding
+// it, for clients that need configuring themselves.
+func WithTLS() Option {
+	return func(f *FakeService) {
+		f.tls = true
+	}
+}
+
+// BaseURL returns the URL the service is reachable on, such as
+// https://localhost:8080, once it is running.
+func (f *FakeService) BaseURL() string {
+	scheme := "http"
+	if f.tls {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%s", scheme, f.port)
+}
+
+// Client returns an *http.Client for talking to the service once it is
+// running, which trusts the service's certificate when using TLS and
+// speaks HTTP/2 when using WithHTTP2.
+func (f *FakeService) Client() *http.Client {
+	if f.http2 && !f.tls {
+		return h2cClient()
+	}
+	return f.testserver.Client()
+}
+
+// CertPool returns a pool holding the service's certificate once it is
+// running with TLS, and nil otherwise.
+func (f *FakeService) CertPool() *x509.CertPool {
+	cert := f.testserver.Certificate()
+	if cert == nil {
+		return nil
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return pool
+}
+
+// RequireClientCert serves over HTTPS, as WithTLS does, and rejects
+// any connection that doesn't present a client certificate minted by
+// ClientCertificate.
+func RequireClientCert() Option {
+	return func(f *FakeService) {
+		f.tls = true
+		f.requireClientCert = true
+	}
+}
+
+// ClientCertificate mints a client certificate with the given common
+// name, signed by the authority the service trusts for client
+// certificates.
+func (f *FakeService) ClientCertificate(commonName string) (tls.Certificate, error) {
+	ca, err := f.clientAuthority()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"fakes"}, CommonName: commonName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+// ClientWithCertificate returns an *http.Client for talking to the
+// service that presents cert and trusts the service's certificate.
+func (f *FakeService) ClientWithCertificate(cert tls.Certificate) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      f.CertPool(),
+				Certificates: []tls.Certificate{cert},
+			},
+		},
+	}
+}
+
+// clientAuthority returns the authority behind client certificates,
+// creating it on first use.
+func (f *FakeService) clientAuthority() (*certAuthority, error) {
+	f.clientCAMutex.Lock()
+	defer f.clientCAMutex.Unlock()
+
+	if f.clientCA == nil {
+		ca, err := newCertAuthority("fakes client CA")
+		if err != nil {
+			return nil, err
+		}
+		f.clientCA = ca
+	}
+	return f.clientCA, nil
+}
+
+// serverTLSConfig returns the TLS configuration the service is served
+// with, generating its certificate.
+func (f *FakeService) serverTLSConfig() (*tls.Config, error) {
+	cert, err := generateCertificate()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if f.requireClientCert {
+		ca, err := f.clientAuthority()
+		if err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientCAs.AddCert(ca.cert)
+	}
+	return config, nil
+}
+
+// certAuthority signs certificates for tests.
+type certAuthority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCertAuthority(commonName string) (*certAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{"fakes"}, CommonName: commonName},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := createCertificate(template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &certAuthority{cert: cert, key: key}, nil
+}
+
+// issue signs a certificate for a new key from template.
+func (ca *certAuthority) issue(template *x509.Certificate) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	der, err := createCertificate(template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// generateCertificate creates a self-signed certificate for the hosts
+// the service can be reached on.
+func generateCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{"fakes"}, CommonName: "localhost"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := createCertificate(template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// createCertificate fills in template's serial number and validity,
+// for the duration of a test run, then creates the certificate.
+func createCertificate(template, parent *x509.Certificate, pub *ecdsa.PublicKey, priv *ecdsa.PrivateKey) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	return x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+}
+
+// ReceivedClientCert checks that the route was called with a client
+// certificate for commonName.
+func (c *EndpointCheck) ReceivedClientCert(commonName string) error {
+	var presented []string
+	for _, r := range c.f.requestsFor(c.path, c.method) {
+		if r.ClientCertificate == nil {
+			continue
+		}
+		if r.ClientCertificate.Subject.CommonName == commonName {
+			return nil
+		}
+		presented = append(presented, r.ClientCertificate.Subject.CommonName)
+	}
+	if len(presented) == 0 {
+		return fmt.Errorf("expected %s to be called with a client certificate for %q but none was presented", c, commonName)
+	}
+	return fmt.Errorf("expected %s to be called with a client certificate for %q, presented: %s", c, commonName, strings.Join(presented, ", "))
+}
+
+// ReceivedClientCert asserts that the route was called with a client
+// certificate for commonName.
+func (v *EndpointVerifier) ReceivedClientCert(commonName string) bool {
+	v.t.Helper()
+	return v.assert(v.check.ReceivedClientCert(commonName))
+}
@@ -0,0 +1,68 @@
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChaosTruncate cuts responses short, writing only the start of the
+// body before closing the connection, to exercise a client's handling
+// of short reads. It applies to Response and ResponseTemplate bodies.
+type ChaosTruncate struct {
+	// Percent is the chance, from 0 to 100, that a response is cut short.
+	Percent int
+	// Bytes is how many bytes of the body to send. If zero, BodyPercent
+	// of the body is sent instead.
+	Bytes       int
+	BodyPercent int
+	// PromiseFullLength sends a Content-Length for the whole body, so
+	// the client knows the response was cut short. Otherwise the body
+	// is delimited by the connection closing and looks complete.
+	PromiseFullLength bool
+}
+
+func (t *ChaosTruncate) applies(rng *rand.Rand) bool {
+	return chance(rng, t.Percent)
+}
+
+// keep returns how many bytes of a body of the given length to send.
+func (t *ChaosTruncate) keep(length int) int {
+	n := t.Bytes
+	if n == 0 {
+		n = length * t.BodyPercent / 100
+	}
+	if n > length {
+		n = length
+	}
+	return n
+}
+
+// writeTruncated writes the start of body directly to the hijacked
+// connection and then closes it.
+func writeTruncated(c *gin.Context, status int, body string, t *ChaosTruncate) {
+	conn, rw, err := c.Writer.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	defer conn.Close()
+
+	header := c.Writer.Header().Clone()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if t.PromiseFullLength {
+		header.Set("Content-Length", fmt.Sprint(len(body)))
+	} else {
+		header.Del("Content-Length")
+	}
+	header.Set("Connection", "close")
+
+	fmt.Fprintf(rw, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	header.Write(rw)
+	rw.WriteString("\r\n")
+	rw.WriteString(body[:t.keep(len(body))])
+	rw.Flush()
+}
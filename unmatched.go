@@ -0,0 +1,147 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnmatchedRequest is a request the FakeService had no endpoint for,
+// along with the registered endpoint that came closest to matching it.
+type UnmatchedRequest struct {
+	Request RecordedRequest
+	// NearMiss is the closest registered endpoint, or nil if the
+	// service has no endpoints at all.
+	NearMiss *Endpoint
+	// Reason describes which of NearMiss's matchers failed.
+	Reason string
+}
+
+// unmatched handles any request without a matching endpoint.
+func (f *FakeService) unmatched(c *gin.Context) {
+	if e := f.matchPattern(c.Request); e != nil {
+		f.serve(e, c)
+		return
+	}
+	if f.cassette != nil && f.cassette.recording {
+		f.cassette.record(c)
+		return
+	}
+	fmt.Printf("%s: %s - no matching endpoint\n", c.Request.Method, c.Request.URL)
+	recorded := recordRequest(c.Request)
+	recorded.Response = &RecordedResponse{StatusCode: http.StatusNotFound, Header: http.Header{}}
+	nearMiss, reason := f.nearMiss(c.FullPath(), c.Request)
+
+	f.unmatchedMutex.Lock()
+	f.unmatchedRequests = append(f.unmatchedRequests, UnmatchedRequest{
+		Request:  recorded,
+		NearMiss: nearMiss,
+		Reason:   reason,
+	})
+	f.unmatchedMutex.Unlock()
+
+	if f.strict && f.t != nil {
+		f.t.Errorf("FakeService received an unmatched request: %s %s\n%s", c.Request.Method, c.Request.URL, recorded.Body)
+	}
+	c.Status(http.StatusNotFound)
+}
+
+// UnmatchedRequests returns every request received that didn't match
+// a registered endpoint, oldest first.
+func (f *FakeService) UnmatchedRequests() []UnmatchedRequest {
+	f.unmatchedMutex.Lock()
+	defer f.unmatchedMutex.Unlock()
+
+	return append([]UnmatchedRequest(nil), f.unmatchedRequests...)
+}
+
+// nearMiss finds the registered endpoint closest to matching r. route
+// is the gin route r was routed to, empty if there wasn't one.
+func (f *FakeService) nearMiss(route string, r *http.Request) (*Endpoint, string) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var closest *Endpoint
+	var reasons []string
+	best := -1
+	for _, e := range f.Endpoints {
+		var failed []string
+		score := 0
+		if e.pathPattern != nil {
+			if !e.pathPattern.MatchString(r.URL.Path) {
+				failed = append(failed, fmt.Sprintf("path %s does not match pattern %s", r.URL.Path, e.PathPattern))
+				score += 100
+			}
+		} else if route == "" || route != e.Path {
+			failed = append(failed, fmt.Sprintf("path %s does not match %s", r.URL.Path, e.Path))
+			score += 100 + levenshtein(r.URL.Path, e.Path)
+		}
+		if e.Method != "" && !strings.EqualFold(e.Method, r.Method) {
+			failed = append(failed, fmt.Sprintf("method %s does not match %s", r.Method, e.Method))
+			score++
+		}
+		if !e.matchesHost(r.Host) {
+			failed = append(failed, fmt.Sprintf("host %s does not match %s", r.Host, e.Host))
+			score++
+		}
+		if !e.matches(r) {
+			failed = append(failed, "request was rejected by Matcher")
+			score++
+		}
+		if !f.scenarios.matches(e) {
+			failed = append(failed, fmt.Sprintf("scenario %s is in state %s, not %s", e.Scenario, f.scenarios.state(e.Scenario), e.RequiredState))
+			score++
+		}
+		if best == -1 || score < best {
+			best, closest, reasons = score, e, failed
+		}
+	}
+	return closest, strings.Join(reasons, "; ")
+}
+
+// reportUnmatched logs every unmatched request alongside its near miss.
+func (f *FakeService) reportUnmatched(t testing.TB) {
+	if unmatched := f.UnmatchedRequests(); len(unmatched) > 0 {
+		t.Log(f.unmatchedReport(unmatched))
+	}
+}
+
+func (f *FakeService) unmatchedReport(unmatched []UnmatchedRequest) string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "FakeService received %d unmatched requests:", len(unmatched))
+	for _, u := range unmatched {
+		fmt.Fprintf(&report, "\n  %s %s", u.Request.Method, u.Request.URL)
+		if u.NearMiss != nil {
+			method := u.NearMiss.Method
+			if method == "" {
+				method = "ANY"
+			}
+			fmt.Fprintf(&report, "\n    closest endpoint: %s %s%s (%s)", method, u.NearMiss.Host, u.NearMiss.route(), u.Reason)
+		}
+	}
+	return report.String()
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
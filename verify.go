@@ -0,0 +1,77 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Verifier makes assertions about the traffic a FakeService received.
+type Verifier struct {
+	t testing.TB
+	f *FakeService
+}
+
+// Verify returns a Verifier reporting failures against t.
+func (f *FakeService) Verify(t testing.TB) *Verifier {
+	return &Verifier{t: t, f: f}
+}
+
+// EndpointVerifier makes assertions about the calls made to a route.
+type EndpointVerifier struct {
+	t     testing.TB
+	check *EndpointCheck
+}
+
+// Endpoint verifies calls made to path with any method.
+func (v *Verifier) Endpoint(path string) *EndpointVerifier {
+	return v.Route("", path)
+}
+
+// Route verifies calls made to path with the given method.
+func (v *Verifier) Route(method, path string) *EndpointVerifier {
+	return &EndpointVerifier{t: v.t, check: v.f.CheckRoute(method, path)}
+}
+
+func (v *EndpointVerifier) String() string {
+	return v.check.String()
+}
+
+// CalledTimes asserts the route was called exactly n times.
+func (v *EndpointVerifier) CalledTimes(n int) bool {
+	v.t.Helper()
+	return v.assert(v.check.CalledTimes(n))
+}
+
+// CalledOnce asserts the route was called exactly once.
+func (v *EndpointVerifier) CalledOnce() bool {
+	v.t.Helper()
+	return v.assert(v.check.CalledOnce())
+}
+
+// NeverCalled asserts the route was not called at all.
+func (v *EndpointVerifier) NeverCalled() bool {
+	v.t.Helper()
+	return v.assert(v.check.NeverCalled())
+}
+
+// CalledAtLeast asserts the route was called n or more times.
+func (v *EndpointVerifier) CalledAtLeast(n int) bool {
+	v.t.Helper()
+	return v.assert(v.check.CalledAtLeast(n))
+}
+
+// LastJSONBody asserts that the body of the most recent call to the
+// route is JSON equal to expected, printing a structured diff if not.
+func (v *EndpointVerifier) LastJSONBody(expected string) bool {
+	v.t.Helper()
+	return v.assert(v.check.LastJSONBody(expected))
+}
+
+func (v *EndpointVerifier) assert(err error) bool {
+	v.t.Helper()
+	if err != nil {
+		return assert.Fail(v.t, err.Error())
+	}
+	return true
+}
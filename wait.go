@@ -0,0 +1,22 @@
+package fake
+
+import (
+	"testing"
+	"time"
+)
+
+// waitPollInterval is how often WaitFor checks an endpoint's calls.
+const waitPollInterval = 10 * time.Millisecond
+
+// WaitFor blocks until the endpoints registered against path have been
+// called at least n times, failing the test if that doesn't happen
+// within timeout. It's intended for code under test that calls its
+// upstreams asynchronously.
+func (f *FakeService) WaitFor(t testing.TB, path string, n int, timeout time.Duration) bool {
+	t.Helper()
+	if err := f.WaitForE(path, n, timeout); err != nil {
+		t.Error(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WebhookSigner signs a webhook delivery before it is sent, so senders
+// can speak any vendor's signature scheme. WebhookSignature and
+// StripeSignature implement it.
+type WebhookSigner interface {
+	SignWebhook(r *http.Request, payload []byte) error
+}
+
+// SignWebhook signs r as s describes.
+func (s WebhookSignature) SignWebhook(r *http.Request, payload []byte) error {
+	signature, err := s.Sign(payload)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(s.header(), signature)
+	return nil
+}
+
+// StripeSignature signs deliveries as Stripe does, with a
+// Stripe-Signature header holding a timestamp and an HMAC-SHA256 of the
+// timestamp and payload keyed by the endpoint's signing secret.
+type StripeSignature struct {
+	Secret string
+}
+
+// SignWebhook signs r as Stripe would.
+func (s StripeSignature) SignWebhook(r *http.Request, payload []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	r.Header.Set("Stripe-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// StripeEvent wraps object in a Stripe event envelope of the given
+// type, such as "invoice.paid".
+func StripeEvent(eventType string, object any) map[string]any {
+	return map[string]any{
+		"id":               "evt_" + randomToken(),
+		"object":           "event",
+		"api_version":      "2023-10-16",
+		"created":          time.Now().Unix(),
+		"type":             eventType,
+		"livemode":         false,
+		"pending_webhooks": 1,
+		"data":             map[string]any{"object": object},
+		"request":          map[string]any{"id": nil, "idempotency_key": nil},
+	}
+}
+
+// WebhookSender POSTs webhook events to the service under test, on
+// demand with Send or repeatedly with Schedule, and records the result
+// of every delivery.
+type WebhookSender struct {
+	URL string
+	// Signer, if set, signs every delivery.
+	Signer WebhookSigner
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+
+	mutex      sync.Mutex
+	deliveries []WebhookDelivery
+}
+
+// WebhookDelivery is the result of delivering a webhook event. Err is
+// set if no response was received.
+type WebhookDelivery struct {
+	Payload      []byte
+	Header       http.Header
+	StatusCode   int
+	ResponseBody []byte
+	Err          error
+	Time         time.Time
+	Duration     time.Duration
+}
+
+// Succeeded reports whether the delivery was answered with a 2xx.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Err == nil && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// Send delivers event, which is sent as is if it is a string or []byte
+// and marshalled to JSON otherwise.
+func (s *WebhookSender) Send(event any) WebhookDelivery {
+	delivery := WebhookDelivery{Time: time.Now()}
+	defer func() {
+		delivery.Duration = time.Since(delivery.Time)
+		s.mutex.Lock()
+		s.deliveries = append(s.deliveries, delivery)
+		s.mutex.Unlock()
+	}()
+
+	switch event := event.(type) {
+	case string:
+		delivery.Payload = []byte(event)
+	case []byte:
+		delivery.Payload = event
+	default:
+		if delivery.Payload, delivery.Err = json.Marshal(event); delivery.Err != nil {
+			return delivery
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		delivery.Err = err
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Signer != nil {
+		if err := s.Signer.SignWebhook(req, delivery.Payload); err != nil {
+			delivery.Err = fmt.Errorf("failed to sign webhook: %w", err)
+			return delivery
+		}
+	}
+	delivery.Header = req.Header.Clone()
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		delivery.Err = err
+		return delivery
+	}
+	defer resp.Body.Close()
+	delivery.StatusCode = resp.StatusCode
+	delivery.ResponseBody, delivery.Err = io.ReadAll(resp.Body)
+	return delivery
+}
+
+// Schedule sends the event next returns every interval until the
+// returned function is called to stop it.
+func (s *WebhookSender) Schedule(interval time.Duration, next func() any) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Send(next())
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
+// Deliveries returns the result of every delivery, oldest first.
+func (s *WebhookSender) Deliveries() []WebhookDelivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]WebhookDelivery(nil), s.deliveries...)
+}
@@ -0,0 +1,134 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// WebhookSignature describes how webhook payloads are signed with an
+// HMAC of the body, GitHub's X-Hub-Signature-256 by default. It both
+// verifies the signatures on requests the fake receives and signs the
+// payloads it sends.
+type WebhookSignature struct {
+	Secret string
+	// Header defaults to "X-Hub-Signature-256".
+	Header string
+	// Algorithm is "sha256" by default, or "sha1" or "sha512".
+	Algorithm string
+	// Encoding is "hex" by default, or "base64".
+	Encoding string
+	// The signature is prefixed with the algorithm and an equals sign,
+	// as in "sha256=...", unless NoPrefix is set.
+	NoPrefix bool
+}
+
+// RequireWebhookSignature makes the endpoint reject calls whose body
+// isn't signed as s describes with a 401. Rejected calls are still
+// recorded.
+func (e *Endpoint) RequireWebhookSignature(s WebhookSignature) *Endpoint {
+	e.WebhookSignature = &s
+	return e
+}
+
+// Sign returns the signature of payload, as sent in the header.
+func (s WebhookSignature) Sign(payload []byte) (string, error) {
+	newHash, err := s.hash()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(newHash, []byte(s.Secret))
+	mac.Write(payload)
+	sum := mac.Sum(nil)
+
+	var signature string
+	switch s.Encoding {
+	case "", "hex":
+		signature = hex.EncodeToString(sum)
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(sum)
+	default:
+		return "", fmt.Errorf("unsupported webhook signature encoding %q", s.Encoding)
+	}
+	if s.NoPrefix {
+		return signature, nil
+	}
+	return s.algorithm() + "=" + signature, nil
+}
+
+// SignRequest sets the signature header on r for its body.
+func (s WebhookSignature) SignRequest(r *http.Request) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+	}
+	rewindBody(r, body)
+	return s.SignWebhook(r, body)
+}
+
+// Verify reports whether r's body carries a valid signature. It can be
+// used as an Endpoint's Matcher.
+func (s WebhookSignature) Verify(r *http.Request) bool {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	rewindBody(r, body)
+	want, err := s.Sign(body)
+	return err == nil && hmac.Equal([]byte(r.Header.Get(s.header())), []byte(want))
+}
+
+// SendWebhook POSTs payload as JSON to url, signed as signature
+// describes unless it is nil.
+func (f *FakeService) SendWebhook(url string, payload []byte, signature *WebhookSignature) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signature != nil {
+		if err := signature.SignRequest(req); err != nil {
+			return nil, err
+		}
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (s WebhookSignature) header() string {
+	if s.Header == "" {
+		return "X-Hub-Signature-256"
+	}
+	return s.Header
+}
+
+func (s WebhookSignature) algorithm() string {
+	if s.Algorithm == "" {
+		return "sha256"
+	}
+	return s.Algorithm
+}
+
+func (s WebhookSignature) hash() (func() hash.Hash, error) {
+	switch s.algorithm() {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported webhook signature algorithm %q", s.Algorithm)
+}
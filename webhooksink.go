@@ -0,0 +1,141 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookSink accepts every webhook the service under test POSTs to it
+// and stores the deliveries, so tests can assert on what was sent and
+// wait for asynchronous deliveries to arrive.
+type WebhookSink struct {
+	// Path defaults to "/webhooks", and deliveries to any path beneath
+	// it are accepted too. A Path of "/" accepts deliveries to any path
+	// no other endpoint is registered for.
+	Path string
+	// Signature, if set, is checked on every delivery, see
+	// WebhookDelivery.SignatureValid.
+	Signature *WebhookSignature
+	// RejectInvalid answers deliveries without a valid Signature with a
+	// 401 rather than accepting them. They are stored either way.
+	RejectInvalid bool
+	// StatusCode answers accepted deliveries, and defaults to 200.
+	StatusCode int
+
+	mutex      sync.Mutex
+	deliveries []ReceivedWebhook
+}
+
+// ReceivedWebhook is a delivery a WebhookSink received.
+type ReceivedWebhook struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+	// SignatureValid reports whether the delivery was signed as the
+	// sink's Signature describes, and is false if it has none.
+	SignatureValid bool
+	Time           time.Time
+}
+
+// JSON unmarshals the delivery's body into v.
+func (d ReceivedWebhook) JSON(v any) error {
+	return json.Unmarshal(d.Body, v)
+}
+
+// AddWebhookSink registers the sink's endpoint. Tests assert on the
+// deliveries themselves, so the endpoint is Optional.
+func (f *FakeService) AddWebhookSink(s *WebhookSink) {
+	if s.Path == "" {
+		s.Path = "/webhooks"
+	}
+	if s.StatusCode == 0 {
+		s.StatusCode = http.StatusOK
+	}
+	s.Path = strings.TrimSuffix(s.Path, "/")
+	if s.Path == "" {
+		// gin won't have a catch-all at the root alongside other
+		// routes, so a pattern picks up whatever they don't match.
+		s.Path = "/"
+		f.AddEndpoint(&Endpoint{PathPattern: "/.*", Method: http.MethodPost, Handler: s.receive, Optional: true})
+		return
+	}
+	for _, path := range []string{s.Path, s.Path + "/*rest"} {
+		f.AddEndpoint(&Endpoint{Path: path, Method: http.MethodPost, Handler: s.receive, Optional: true})
+	}
+}
+
+// Deliveries returns the deliveries received so far, oldest first.
+func (s *WebhookSink) Deliveries() []ReceivedWebhook {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]ReceivedWebhook(nil), s.deliveries...)
+}
+
+// Reset forgets the deliveries received so far.
+func (s *WebhookSink) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deliveries = nil
+}
+
+// WaitForDelivery blocks until the sink has received a delivery match
+// reports true for, or any delivery if match is nil, and returns it,
+// failing the test if none arrives within timeout.
+func (s *WebhookSink) WaitForDelivery(t testing.TB, match func(ReceivedWebhook) bool, timeout time.Duration) (ReceivedWebhook, bool) {
+	t.Helper()
+	delivery, err := s.WaitForDeliveryE(match, timeout)
+	if err != nil {
+		t.Error(err)
+		return ReceivedWebhook{}, false
+	}
+	return delivery, true
+}
+
+// WaitForDeliveryE is like WaitForDelivery but returns an error on
+// timeout.
+func (s *WebhookSink) WaitForDeliveryE(match func(ReceivedWebhook) bool, timeout time.Duration) (ReceivedWebhook, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		deliveries := s.Deliveries()
+		for _, delivery := range deliveries {
+			if match == nil || match(delivery) {
+				return delivery, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return ReceivedWebhook{}, fmt.Errorf("timed out after %s waiting for a webhook delivery to %s, %d deliveries didn't match", timeout, s.Path, len(deliveries))
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+func (s *WebhookSink) receive(c *gin.Context) {
+	delivery := ReceivedWebhook{Path: c.Request.URL.Path, Header: c.Request.Header.Clone(), Time: time.Now()}
+	if s.Signature != nil {
+		delivery.SignatureValid = s.Signature.Verify(c.Request)
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	delivery.Body = body
+
+	s.mutex.Lock()
+	s.deliveries = append(s.deliveries, delivery)
+	s.mutex.Unlock()
+
+	if s.RejectInvalid && !delivery.SignatureValid {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	c.Status(s.StatusCode)
+}
@@ -0,0 +1,42 @@
+package fake
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWebhookSinkPaths(t *testing.T) {
+	tests := []struct {
+		path      string
+		delivered []string
+		ignored   []string
+	}{
+		{"", []string{"/webhooks", "/webhooks/orders"}, []string{"/other"}},
+		{"/hooks/", []string{"/hooks", "/hooks/orders"}, []string{"/other"}},
+		{"/", []string{"/", "/orders", "/orders/1"}, []string{"/pets"}},
+	}
+	for _, tt := range tests {
+		f := New()
+		f.AddEndpoint(&Endpoint{Path: "/pets", Method: http.MethodPost, Response: "pets", Optional: true})
+		sink := &WebhookSink{Path: tt.path}
+		f.AddWebhookSink(sink)
+		f.Run(t)
+
+		for _, path := range append(append([]string{}, tt.delivered...), tt.ignored...) {
+			resp, err := http.Post(f.BaseURL()+path, "application/json", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+		var got []string
+		for _, d := range sink.Deliveries() {
+			got = append(got, d.Path)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.delivered, " ") {
+			t.Errorf("sink at %q received %v, want %v", tt.path, got, tt.delivered)
+		}
+		f.TidyUp(t)
+	}
+}
@@ -0,0 +1,578 @@
+package fake
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WireMock is a set of WireMock stub mappings, as found in a WireMock
+// mappings directory, so existing stub libraries can be served by a
+// FakeService unchanged.
+type WireMock struct {
+	Mappings []WireMockMapping `json:"mappings"`
+
+	// FilesDir is where bodyFileName responses are read from, the
+	// __files directory alongside the mappings.
+	FilesDir string `json:"-"`
+}
+
+// WireMockMapping is a single stub: a request to match and the
+// response to send.
+type WireMockMapping struct {
+	ID       string           `json:"id,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Priority int              `json:"priority,omitempty"`
+	Request  WireMockRequest  `json:"request"`
+	Response WireMockResponse `json:"response"`
+
+	ScenarioName          string `json:"scenarioName,omitempty"`
+	RequiredScenarioState string `json:"requiredScenarioState,omitempty"`
+	NewScenarioState      string `json:"newScenarioState,omitempty"`
+}
+
+type WireMockRequest struct {
+	Method               string                     `json:"method,omitempty"`
+	URL                  string                     `json:"url,omitempty"`
+	URLPath              string                     `json:"urlPath,omitempty"`
+	URLPattern           string                     `json:"urlPattern,omitempty"`
+	URLPathPattern       string                     `json:"urlPathPattern,omitempty"`
+	QueryParameters      map[string]WireMockPattern `json:"queryParameters,omitempty"`
+	Headers              map[string]WireMockPattern `json:"headers,omitempty"`
+	Cookies              map[string]WireMockPattern `json:"cookies,omitempty"`
+	BodyPatterns         []WireMockPattern          `json:"bodyPatterns,omitempty"`
+	BasicAuthCredentials *WireMockCredentials       `json:"basicAuthCredentials,omitempty"`
+}
+
+// WireMockPattern matches a single value, e.g. {"equalTo": "json"} or
+// {"matches": "^[0-9]+$", "caseInsensitive": true}.
+type WireMockPattern map[string]any
+
+type WireMockCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type WireMockResponse struct {
+	Status                 int            `json:"status,omitempty"`
+	Body                   string         `json:"body,omitempty"`
+	JSONBody               any            `json:"jsonBody,omitempty"`
+	Base64Body             string         `json:"base64Body,omitempty"`
+	BodyFileName           string         `json:"bodyFileName,omitempty"`
+	Headers                map[string]any `json:"headers,omitempty"`
+	FixedDelayMilliseconds int            `json:"fixedDelayMilliseconds,omitempty"`
+	Fault                  string         `json:"fault,omitempty"`
+	Transformers           []string       `json:"transformers,omitempty"`
+}
+
+// wireMockDefaultPriority is the priority WireMock gives mappings that
+// don't set one, where lower numbers are matched first.
+const wireMockDefaultPriority = 5
+
+// FromWireMock reads stub mappings from a WireMock mapping file or a
+// directory of them. A WireMock root directory, holding mappings and
+// __files, may be given in place of the mappings directory.
+func FromWireMock(path string) (*WireMock, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WireMock mappings: %w", err)
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WireMock mappings: %w", err)
+		}
+		stubs, err := ParseWireMock(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		stubs.FilesDir = filepath.Join(filepath.Dir(filepath.Dir(path)), "__files")
+		return stubs, nil
+	}
+
+	if sub := filepath.Join(path, "mappings"); isDir(sub) {
+		path = sub
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WireMock mappings: %w", err)
+	}
+	stubs := &WireMock{FilesDir: filepath.Join(filepath.Dir(path), "__files")}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WireMock mappings: %w", err)
+		}
+		parsed, err := ParseWireMock(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		stubs.Mappings = append(stubs.Mappings, parsed.Mappings...)
+	}
+	return stubs, nil
+}
+
+// ParseWireMock parses a WireMock mapping file, holding either a single
+// mapping or a list of them under "mappings".
+func ParseWireMock(data []byte) (*WireMock, error) {
+	var stubs WireMock
+	if err := json.Unmarshal(data, &stubs); err != nil {
+		return nil, fmt.Errorf("failed to parse WireMock mappings: %w", err)
+	}
+	if stubs.Mappings != nil {
+		return &stubs, nil
+	}
+	var mapping WireMockMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse WireMock mapping: %w", err)
+	}
+	return &WireMock{Mappings: []WireMockMapping{mapping}}, nil
+}
+
+// AddWireMock registers an Optional endpoint for every mapping, in
+// priority order, which holds between mappings matching URLs exactly
+// and by pattern alike. Mappings that use WireMock features the FakeService
+// can't reproduce, such as response templating or XML matchers, are
+// reported as errors and nothing is registered.
+func (f *FakeService) AddWireMock(stubs *WireMock) error {
+	mappings := make([]WireMockMapping, len(stubs.Mappings))
+	copy(mappings, stubs.Mappings)
+	sort.SliceStable(mappings, func(i, j int) bool {
+		return mappings[i].priority() < mappings[j].priority()
+	})
+
+	var endpoints []*Endpoint
+	for _, mapping := range mappings {
+		e, err := mapping.endpoint(stubs.FilesDir)
+		if err != nil {
+			return fmt.Errorf("WireMock mapping %s: %w", mapping.describe(), err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	for _, e := range endpoints {
+		f.wireMockRank++
+		e.rank = f.wireMockRank
+		f.AddEndpoint(e)
+	}
+	return nil
+}
+
+func (m WireMockMapping) priority() int {
+	if m.Priority == 0 {
+		return wireMockDefaultPriority
+	}
+	return m.Priority
+}
+
+func (m WireMockMapping) describe() string {
+	for _, name := range []string{m.Name, m.ID} {
+		if name != "" {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return fmt.Sprintf("%s %s", m.Request.Method, m.Request.url())
+}
+
+func (m WireMockMapping) endpoint(filesDir string) (*Endpoint, error) {
+	e := &Endpoint{
+		Method:        m.Request.Method,
+		Scenario:      m.ScenarioName,
+		RequiredState: m.RequiredScenarioState,
+		NewState:      m.NewScenarioState,
+		Optional:      true,
+	}
+	if strings.EqualFold(e.Method, "ANY") {
+		e.Method = ""
+	}
+
+	var matchers []func(*http.Request) bool
+	if err := m.Request.route(e, &matchers); err != nil {
+		return nil, err
+	}
+	if err := m.Request.matchers(&matchers); err != nil {
+		return nil, err
+	}
+	if len(matchers) > 0 {
+		e.Matcher = func(r *http.Request) bool {
+			for _, matches := range matchers {
+				if !matches(r) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	if err := m.Response.apply(e, filesDir); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r WireMockRequest) url() string {
+	for _, url := range []string{r.URL, r.URLPath, r.URLPattern, r.URLPathPattern} {
+		if url != "" {
+			return url
+		}
+	}
+	return "/"
+}
+
+// route sets the endpoint's Path or PathPattern from the mapping's URL,
+// adding matchers for the parts of it a path can't express.
+func (r WireMockRequest) route(e *Endpoint, matchers *[]func(*http.Request) bool) error {
+	switch {
+	case r.URL != "":
+		path, query, _ := strings.Cut(r.URL, "?")
+		setLiteralPath(e, path)
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			return req.URL.RawQuery == query
+		})
+	case r.URLPath != "":
+		setLiteralPath(e, r.URLPath)
+	case r.URLPathPattern != "":
+		if _, err := regexp.Compile(r.URLPathPattern); err != nil {
+			return fmt.Errorf("invalid urlPathPattern: %w", err)
+		}
+		e.PathPattern = r.URLPathPattern
+	case r.URLPattern != "":
+		// urlPattern matches the path and query together, so the path
+		// is matched by whatever comes before the query, if that is a
+		// pattern on its own, and the whole URL by a matcher.
+		whole, err := regexp.Compile("^(?:" + r.URLPattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid urlPattern: %w", err)
+		}
+		e.PathPattern = ".*"
+		if path, _, _ := strings.Cut(r.URLPattern, `\?`); path != "" {
+			if _, err := regexp.Compile(path); err == nil {
+				e.PathPattern = path
+			}
+		}
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			return whole.MatchString(req.URL.RequestURI())
+		})
+	default:
+		e.PathPattern = ".*"
+	}
+	return nil
+}
+
+// setLiteralPath matches path exactly, falling back to a pattern when
+// it holds characters a route would treat as parameters.
+func setLiteralPath(e *Endpoint, path string) {
+	if strings.ContainsAny(path, ":*") {
+		e.PathPattern = regexp.QuoteMeta(path)
+		return
+	}
+	e.Path = path
+}
+
+// matchers adds a matcher for each of the mapping's query parameter,
+// header, cookie, body and basic auth conditions.
+func (r WireMockRequest) matchers(matchers *[]func(*http.Request) bool) error {
+	for _, name := range sortedPatternNames(r.QueryParameters) {
+		name := name
+		matches, err := r.QueryParameters[name].compile()
+		if err != nil {
+			return fmt.Errorf("query parameter %s: %w", name, err)
+		}
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			return matchesAny(matches, req.URL.Query()[name])
+		})
+	}
+	for _, name := range sortedPatternNames(r.Headers) {
+		name := name
+		matches, err := r.Headers[name].compile()
+		if err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			return matchesAny(matches, req.Header.Values(name))
+		})
+	}
+	for _, name := range sortedPatternNames(r.Cookies) {
+		name := name
+		matches, err := r.Cookies[name].compile()
+		if err != nil {
+			return fmt.Errorf("cookie %s: %w", name, err)
+		}
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			cookie, err := req.Cookie(name)
+			if err != nil {
+				return matches("", false)
+			}
+			return matches(cookie.Value, true)
+		})
+	}
+	for i, pattern := range r.BodyPatterns {
+		matches, err := pattern.compile()
+		if err != nil {
+			return fmt.Errorf("body pattern %d: %w", i+1, err)
+		}
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			body, _ := io.ReadAll(req.Body)
+			rewindBody(req, body)
+			return matches(string(body), true)
+		})
+	}
+	if credentials := r.BasicAuthCredentials; credentials != nil {
+		*matchers = append(*matchers, func(req *http.Request) bool {
+			username, password, ok := req.BasicAuth()
+			return ok && username == credentials.Username && password == credentials.Password
+		})
+	}
+	return nil
+}
+
+func sortedPatternNames(patterns map[string]WireMockPattern) []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchesAny reports whether any of values satisfies matches, or, if
+// there are none, whether matches accepts an absent value.
+func matchesAny(matches func(string, bool) bool, values []string) bool {
+	if len(values) == 0 {
+		return matches("", false)
+	}
+	for _, value := range values {
+		if matches(value, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile turns the pattern into a function reporting whether a value,
+// which may be absent, satisfies it.
+func (p WireMockPattern) compile() (func(value string, present bool) bool, error) {
+	caseInsensitive, _ := p["caseInsensitive"].(bool)
+	ignoreArrayOrder, _ := p["ignoreArrayOrder"].(bool)
+	ignoreExtraElements, _ := p["ignoreExtraElements"].(bool)
+
+	var checks []func(string) bool
+	for _, key := range sortedKeys(p) {
+		operand := p[key]
+		switch key {
+		case "caseInsensitive", "ignoreArrayOrder", "ignoreExtraElements":
+		case "absent":
+			absent, _ := operand.(bool)
+			return func(_ string, present bool) bool { return present != absent }, nil
+		case "equalTo", "contains", "doesNotContain":
+			want, ok := operand.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string", key)
+			}
+			checks = append(checks, stringCheck(key, want, caseInsensitive))
+		case "matches", "doesNotMatch":
+			expr, ok := operand.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string", key)
+			}
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			negate := key == "doesNotMatch"
+			checks = append(checks, func(value string) bool { return re.MatchString(value) != negate })
+		case "equalToJson":
+			want := operand
+			if text, ok := operand.(string); ok {
+				if err := json.Unmarshal([]byte(text), &want); err != nil {
+					return nil, fmt.Errorf("equalToJson is not valid JSON: %w", err)
+				}
+			}
+			checks = append(checks, func(value string) bool {
+				var got any
+				if err := json.Unmarshal([]byte(value), &got); err != nil {
+					return false
+				}
+				return jsonMatches(want, got, ignoreArrayOrder, ignoreExtraElements)
+			})
+		default:
+			return nil, fmt.Errorf("unsupported matcher %q", key)
+		}
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("no matcher given")
+	}
+	return func(value string, present bool) bool {
+		if !present {
+			return false
+		}
+		for _, check := range checks {
+			if !check(value) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func stringCheck(key, want string, caseInsensitive bool) func(string) bool {
+	if caseInsensitive {
+		want = strings.ToLower(want)
+	}
+	return func(value string) bool {
+		if caseInsensitive {
+			value = strings.ToLower(value)
+		}
+		switch key {
+		case "contains":
+			return strings.Contains(value, want)
+		case "doesNotContain":
+			return !strings.Contains(value, want)
+		}
+		return value == want
+	}
+}
+
+// jsonMatches compares two values decoded from JSON, optionally
+// ignoring the order of arrays and elements got has that want doesn't.
+func jsonMatches(want, got any, ignoreArrayOrder, ignoreExtraElements bool) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok || (!ignoreExtraElements && len(g) != len(w)) {
+			return false
+		}
+		for k, wv := range w {
+			gv, ok := g[k]
+			if !ok || !jsonMatches(wv, gv, ignoreArrayOrder, ignoreExtraElements) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || (!ignoreExtraElements && len(g) != len(w)) || len(g) < len(w) {
+			return false
+		}
+		if !ignoreArrayOrder {
+			for i := range w {
+				if !jsonMatches(w[i], g[i], ignoreArrayOrder, ignoreExtraElements) {
+					return false
+				}
+			}
+			return true
+		}
+		used := make([]bool, len(g))
+	next:
+		for _, wv := range w {
+			for i, gv := range g {
+				if !used[i] && jsonMatches(wv, gv, ignoreArrayOrder, ignoreExtraElements) {
+					used[i] = true
+					continue next
+				}
+			}
+			return false
+		}
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// apply sets the endpoint's response from the mapping's.
+func (r WireMockResponse) apply(e *Endpoint, filesDir string) error {
+	if len(r.Transformers) > 0 {
+		return fmt.Errorf("response transformers %v are not supported", r.Transformers)
+	}
+
+	e.StatusCode = r.Status
+	if e.StatusCode == 0 {
+		e.StatusCode = http.StatusOK
+	}
+	switch {
+	case r.Body != "":
+		e.Response = r.Body
+	case r.JSONBody != nil:
+		body, err := json.Marshal(r.JSONBody)
+		if err != nil {
+			return fmt.Errorf("failed to encode jsonBody: %w", err)
+		}
+		e.Response = string(body)
+	case r.Base64Body != "":
+		body, err := base64.StdEncoding.DecodeString(r.Base64Body)
+		if err != nil {
+			return fmt.Errorf("invalid base64Body: %w", err)
+		}
+		e.Response = string(body)
+	case r.BodyFileName != "":
+		body, err := os.ReadFile(filepath.Join(filesDir, r.BodyFileName))
+		if err != nil {
+			return fmt.Errorf("failed to read bodyFileName: %w", err)
+		}
+		e.Response = string(body)
+	}
+
+	if len(r.Headers) > 0 {
+		e.ResponseHeaders = http.Header{}
+		for name, value := range r.Headers {
+			switch v := value.(type) {
+			case string:
+				e.ResponseHeaders.Add(name, v)
+			case []any:
+				for _, item := range v {
+					e.ResponseHeaders.Add(name, fmt.Sprint(item))
+				}
+			default:
+				e.ResponseHeaders.Add(name, fmt.Sprint(v))
+			}
+		}
+	}
+
+	if r.FixedDelayMilliseconds > 0 {
+		e.LatencyRamp = &LatencyRamp{Start: time.Duration(r.FixedDelayMilliseconds) * time.Millisecond}
+	}
+
+	switch r.Fault {
+	case "":
+	case "CONNECTION_RESET_BY_PEER":
+		e.Handler = Hijack(func(conn net.Conn, _ *bufio.ReadWriter, _ *http.Request) {
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.SetLinger(0)
+			}
+		})
+	case "EMPTY_RESPONSE":
+		e.Handler = Hijack(func(net.Conn, *bufio.ReadWriter, *http.Request) {})
+	case "RANDOM_DATA_THEN_CLOSE":
+		e.Handler = Hijack(func(_ net.Conn, rw *bufio.ReadWriter, _ *http.Request) {
+			garbage := make([]byte, 64)
+			rand.Read(garbage)
+			rw.Write(garbage)
+			rw.Flush()
+		})
+	case "MALFORMED_RESPONSE_CHUNK":
+		status := e.StatusCode
+		e.Handler = Hijack(func(_ net.Conn, rw *bufio.ReadWriter, _ *http.Request) {
+			fmt.Fprintf(rw, "HTTP/1.1 %d %s\r\nTransfer-Encoding: chunked\r\n\r\n", status, http.StatusText(status))
+			rw.WriteString("lskdu018973t09sylgasjkfg1][]'./.sdlv")
+			rw.Flush()
+		})
+	default:
+		return fmt.Errorf("unsupported fault %q", r.Fault)
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
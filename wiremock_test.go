@@ -0,0 +1,68 @@
+package fake
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestWireMockPriorityAcrossPatternsAndPaths(t *testing.T) {
+	stubs, err := ParseWireMock([]byte(`{"mappings": [
+		{"priority": 5, "request": {"method": "GET", "url": "/items/1"}, "response": {"status": 200, "body": "exact"}},
+		{"priority": 1, "request": {"method": "GET", "urlPattern": "/items/.*"}, "response": {"status": 200, "body": "pattern"}},
+		{"priority": 9, "request": {"method": "GET", "urlPattern": "/other/.*"}, "response": {"status": 200, "body": "other pattern"}},
+		{"priority": 2, "request": {"method": "GET", "url": "/other/1"}, "response": {"status": 200, "body": "other exact"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New()
+	if err := f.AddWireMock(stubs); err != nil {
+		t.Fatal(err)
+	}
+	f.Run(t)
+	defer f.TidyUp(t)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/items/1", "pattern"},
+		{"/items/2", "pattern"},
+		{"/other/1", "other exact"},
+		{"/other/2", "other pattern"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(f.BaseURL() + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.path, body, tt.want)
+		}
+	}
+}
+
+func TestWireMockConnectionResetIgnoresChaosControl(t *testing.T) {
+	stubs, err := ParseWireMock([]byte(`{"request": {"method": "GET", "url": "/reset"}, "response": {"fault": "CONNECTION_RESET_BY_PEER"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New()
+	if err := f.AddWireMock(stubs); err != nil {
+		t.Fatal(err)
+	}
+	f.Run(t)
+	defer f.TidyUp(t)
+	f.Chaos().Disable()
+
+	if resp, err := http.Get(f.BaseURL() + "/reset"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the connection to be reset, got %d", resp.StatusCode)
+	}
+	if events := f.ChaosReport(); len(events) != 0 {
+		t.Errorf("stub fault was reported as chaos: %v", events)
+	}
+}